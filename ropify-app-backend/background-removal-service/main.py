@@ -13,4 +13,8 @@ async def remove_background(file: UploadFile = File(...)):
     output = remove(image_bytes)
     print(f"Output size: {len(output)} bytes")
 
-    return Response(content=output, media_type="image/png")
\ No newline at end of file
+    return Response(content=output, media_type="image/png")
+
+@app.get("/health")
+async def health():
+    return {"status": "ok"}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gaelzamora/ropify-app/config"
 	"github.com/gaelzamora/ropify-app/db"
 	"github.com/gaelzamora/ropify-app/handlers"
 	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
 	"github.com/gaelzamora/ropify-app/repositories"
 	"github.com/gaelzamora/ropify-app/services"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 )
 
@@ -26,7 +31,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:8081,http://192.168.1.68:8081",
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowCredentials: true,
 	}))
 
@@ -34,10 +39,82 @@ func main() {
 	garmentRepository := repositories.NewGarmentRepository(db)
 	outfitRepository := repositories.NewOutfitRepository(db)
 	authRepository := repositories.NewAuthRepository(db)
+	identityRepository := repositories.NewIdentityRepository(db)
+	usernameRepository := repositories.NewUsernameRepository(db)
+	profileRepository := repositories.NewProfileRepository(db)
+	accountRepository := repositories.NewAccountRepository(db)
+	adminRepository := repositories.NewAdminRepository(db)
+	tokenRepository := repositories.NewTokenRepository(db)
+	uploadRepository := repositories.NewUploadRepository(db)
+	imageReferenceRepository := repositories.NewImageReferenceRepository(db)
+	duplicateRepository := repositories.NewDuplicateRepository(db)
+	shareRepository := repositories.NewShareRepository(db)
+	tripRepository := repositories.NewTripRepository(db)
+	wishlistRepository := repositories.NewWishlistRepository(db)
+	garmentStateRepository := repositories.NewGarmentStateRepository(db)
+
+	// Storage
+	storage, err := services.NewObjectStorage(envConfig.StorageDriver, envConfig.LocalStorageDir, envConfig.BaseURL())
+	if err != nil {
+		log.Fatalf("Unable to configure storage: %v", err)
+	}
+	if localStorage, ok := storage.(*services.LocalStorage); ok {
+		handlers.NewLocalStorageHandler(app.Group("/storage"), localStorage)
+	}
+
+	// Background removal, photos are stored as they are without the service
+	var backgroundRemover services.BackgroundRemover = services.NoopBackgroundRemover{}
+	if envConfig.BackgroundRemovalEnabled {
+		httpRemover, err := services.NewHTTPBackgroundRemover(services.BackgroundRemoverConfig{
+			URL:              envConfig.BackgroundRemovalURL,
+			Timeout:          envConfig.BackgroundRemovalTimeout,
+			Retries:          envConfig.BackgroundRemovalRetries,
+			Backoff:          500 * time.Millisecond,
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+		}, nil)
+		if err != nil {
+			log.Fatalf("Unable to configure background removal: %v", err)
+		}
+		backgroundRemover = httpRemover
+	}
 
 	// Service
-	authService := services.NewAuthService(authRepository)
-	oauthService := services.NewOAuthService(authRepository)
+	authService := services.NewAuthService(authRepository, usernameRepository)
+	googleAudiences := append(envConfig.GoogleClientIDs, config.GoogleOAuthConfig.ClientID)
+	identityService := services.NewIdentityService(identityRepository, authRepository)
+	usernameService := services.NewUsernameService(usernameRepository, authRepository)
+	profileService := services.NewProfileService(profileRepository, authRepository, usernameService, storage)
+	accountService := services.NewAccountService(accountRepository, authRepository, storage, envConfig.AccountDeletionGrace)
+	authUsers := middlewares.NewUserCache(db)
+	adminService := services.NewAdminService(adminRepository, authUsers)
+	tokenService := services.NewTokenService(tokenRepository)
+	garmentImageService := services.NewGarmentImageService(storage)
+	duplicateService := services.NewDuplicateService(duplicateRepository, storage)
+	imageGC := services.NewImageGC(imageReferenceRepository, storage, envConfig.ImageGCGrace)
+	remoteImages := services.NewRemoteImageFetcher(30*time.Second, envConfig.MaxUploadSize)
+	garmentImportService := services.NewGarmentImportService(garmentRepository, garmentImageService, remoteImages)
+	shareService := services.NewShareService(shareRepository, outfitRepository, garmentRepository, authRepository, profileService, envConfig.BaseURL())
+	outfitArchiver := services.NewOutfitArchiver(outfitRepository, envConfig.OutfitStaleAfter)
+	outfitCollageService := services.NewOutfitCollageService(outfitRepository, garmentRepository, storage)
+	capsuleService := services.NewCapsuleService(garmentRepository, outfitRepository, outfitCollageService)
+	wishlistTarget, err := services.ParseTargetDistribution(envConfig.WishlistTargetDistribution)
+	if err != nil {
+		log.Fatalf("Invalid WISHLIST_TARGET_DISTRIBUTION: %v", err)
+	}
+	wishlistService := services.NewWishlistService(wishlistRepository, garmentImageService, remoteImages, wishlistTarget)
+	tripService := services.NewTripService(tripRepository, outfitRepository, garmentRepository)
+	closetExportService := services.NewClosetExportService(garmentRepository, outfitRepository, storage)
+	uploadService := services.NewUploadService(uploadRepository, garmentRepository, garmentImageService, storage, envConfig.UploadURLExpiry, envConfig.MaxUploadSize)
+	oauthService := services.NewOAuthService(
+		authRepository,
+		identityRepository,
+		usernameService,
+		services.NewGoogleIDTokenVerifier(googleAudiences, "", "", nil),
+		services.NewGoogleProvider(config.GoogleOAuthConfig, "", nil),
+		services.NewFacebookProvider(config.FacebookOAuthConfig, "", nil),
+		services.NewTwitterProvider(config.TwitterOAuthConfig, "", nil),
+	)
 
 	server := app.Group("/api")
 
@@ -45,11 +122,67 @@ func main() {
 	handlers.NewAuthHandler(server.Group("/auth"), authService)
 	handlers.NewOAuthHandler(server.Group("/oauth"), oauthService)
 
+	// Public profiles
+	handlers.NewPublicProfileHandler(server.Group("/users"), profileService)
+
+	// Share links, JSON for the app and an HTML page for browsers and previews
+	handlers.NewPublicShareHandler(server.Group("/share"), app.Group(strings.TrimSuffix(services.ShareLinkPath, "/")), shareService)
+
 	// Private route to verify if user is authenticated
-	privateRoutes := server.Use(middlewares.AuthProtected(db))
+	privateRoutes := server.Use(middlewares.AuthProtected(db, authUsers))
+
+	// Personal access tokens only reach the routes of their scopes.
+	// Uploads, imports, exports, states and capsules go first so /garment/:id and
+	// /outfit/:id do not catch them
+	handlers.NewUploadHandler(privateRoutes.Group("/garment/uploads", middlewares.RequireScope("garments")), uploadService)
+	handlers.NewGarmentImportHandler(privateRoutes.Group("/garment/import", middlewares.RequireScope("garments")), garmentImportService)
+	handlers.NewClosetExportHandler(privateRoutes.Group("/garment/export", middlewares.RequireScope("garments")), closetExportService)
+	handlers.NewGarmentStateHandler(privateRoutes.Group("/garment/state", middlewares.RequireScope("garments")), garmentStateRepository, garmentRepository)
+	handlers.NewGarmentHandler(privateRoutes.Group("/garment", middlewares.RequireScope("garments")), garmentRepository, garmentImageService, duplicateService, backgroundRemover)
+	handlers.NewCapsuleHandler(privateRoutes.Group("/outfit/capsule", middlewares.RequireScope("outfits")), capsuleService)
+	handlers.NewOutfitHandler(privateRoutes.Group("/outfit", middlewares.RequireScope("outfits")), outfitRepository, garmentRepository, outfitCollageService)
+	// Trips plan outfits, so they share their scope
+	handlers.NewTripHandler(privateRoutes.Group("/trip", middlewares.RequireScope("outfits")), tripService)
+	// Wishlist items become garments
+	handlers.NewWishlistHandler(privateRoutes.Group("/wishlist", middlewares.RequireScope("garments")), wishlistService)
+
+	sessionRoutes := privateRoutes.Group("", middlewares.SessionOnly())
+
+	handlers.NewProfileHandler(sessionRoutes.Group("/me"), profileService)
+	handlers.NewAccountHandler(sessionRoutes.Group("/me"), accountService)
+	handlers.NewIdentityHandler(sessionRoutes.Group("/me/identities"), identityService)
+	handlers.NewTokenHandler(sessionRoutes.Group("/me/tokens"), tokenService)
+	handlers.NewShareHandler(sessionRoutes.Group("/me/shares"), shareService)
+	handlers.NewUsernameHandler(sessionRoutes.Group("/username"), usernameService)
+
+	// Admin routes, only for managers
+	handlers.NewAdminHandler(sessionRoutes.Group("/admin", middlewares.RequireRole(models.Manager)), adminService)
+	handlers.NewHealthHandler(sessionRoutes.Group("/admin/health", middlewares.RequireRole(models.Manager)), backgroundRemover)
+	handlers.NewImageGCHandler(sessionRoutes.Group("/admin/storage/gc", middlewares.RequireRole(models.Manager)), imageGC)
 
-	handlers.NewGarmentHandler(privateRoutes.Group("/garment"), garmentRepository)
-	handlers.NewOutfitHandler(privateRoutes.Group("/outfit"), outfitRepository)
+	// Background jobs
+	go services.RunDeletionWorker(context.Background(), accountService, time.Minute)
+	go services.RunUploadCleanup(context.Background(), uploadService, 10*time.Minute)
+	go func() {
+		if hashed, err := duplicateService.BackfillHashes(context.Background()); err != nil {
+			log.Errorf("hash backfill: %v", err)
+		} else if hashed > 0 {
+			log.Infof("hash backfill: %d garments hashed", hashed)
+		}
+	}()
+	go func() {
+		if rendered, err := outfitCollageService.BackfillCollages(context.Background()); err != nil {
+			log.Errorf("collage backfill: %v", err)
+		} else if rendered > 0 {
+			log.Infof("collage backfill: %d outfits rendered", rendered)
+		}
+	}()
+	if envConfig.OutfitAutoArchiveInterval > 0 {
+		go services.RunOutfitAutoArchive(context.Background(), outfitArchiver, envConfig.OutfitAutoArchiveInterval)
+	}
+	if envConfig.ImageGCInterval > 0 {
+		go services.RunImageGC(context.Background(), imageGC, envConfig.ImageGCInterval)
+	}
 
-	app.Listen(fmt.Sprintf("0.0.0.0:" + envConfig.ServerPort))
+	app.Listen(fmt.Sprintf("0.0.0.0:%s", envConfig.ServerPort))
 }
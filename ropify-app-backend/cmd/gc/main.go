@@ -0,0 +1,48 @@
+// Command gc deletes the stored images no garment, outfit, avatar or pending
+// upload points to anymore. Run it with -dry-run first to see what would go.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/gaelzamora/ropify-app/config"
+	"github.com/gaelzamora/ropify-app/db"
+	"github.com/gaelzamora/ropify-app/repositories"
+	"github.com/gaelzamora/ropify-app/services"
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the orphaned images without deleting them")
+	grace := flag.Duration("grace", 0, "only delete images older than this (default IMAGE_GC_GRACE)")
+	flag.Parse()
+
+	envConfig := config.NewEnvConfig()
+	if *grace <= 0 {
+		*grace = envConfig.ImageGCGrace
+	}
+
+	// The API owns the migrations
+	database := db.Init(envConfig, func(*gorm.DB) error { return nil })
+
+	storage, err := services.NewObjectStorage(envConfig.StorageDriver, envConfig.LocalStorageDir, envConfig.BaseURL())
+	if err != nil {
+		log.Fatalf("Unable to configure storage: %v", err)
+	}
+
+	gc := services.NewImageGC(repositories.NewImageReferenceRepository(database), storage, *grace)
+
+	report, err := gc.Run(context.Background(), *dryRun)
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if err != nil {
+		log.Fatalf("Image gc failed: %v", err)
+	}
+}
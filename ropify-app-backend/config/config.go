@@ -1,6 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/caarlos0/env"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/joho/godotenv"
@@ -13,6 +17,53 @@ type EnvConfig struct {
 	DBUser     string `env:"DB_USER,required"`
 	DBPassword string `env:"DB_PASSWORD,required"`
 	DBSSLMode  string `env:"DB_SSLMODE,required"`
+
+	// Client IDs (web, iOS, Android) accepted as audience of Google ID tokens
+	GoogleClientIDs []string `env:"GOOGLE_CLIENT_IDS" envSeparator:","`
+
+	// Tiempo para cancelar el borrado de una cuenta antes de ejecutarlo
+	AccountDeletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE" envDefault:"168h"`
+
+	// Limpieza de imágenes huérfanas: solo borra objetos más viejos que la
+	// gracia, cada intervalo. Viene desactivada (0), se activa después de
+	// revisar un dry run
+	ImageGCGrace    time.Duration `env:"IMAGE_GC_GRACE" envDefault:"24h"`
+	ImageGCInterval time.Duration `env:"IMAGE_GC_INTERVAL" envDefault:"0"`
+
+	// Outfits archivados automáticamente: los que tienen prendas borradas y,
+	// si se configura OUTFIT_STALE_AFTER (por defecto 0, desactivado), los que
+	// no se editan hace más de ese tiempo, cada intervalo (0 desactiva la
+	// política)
+	OutfitStaleAfter          time.Duration `env:"OUTFIT_STALE_AFTER" envDefault:"0"`
+	OutfitAutoArchiveInterval time.Duration `env:"OUTFIT_AUTO_ARCHIVE_INTERVAL" envDefault:"24h"`
+
+	// Distribución objetivo del closet para el análisis de huecos de la
+	// lista de deseos, en pesos categoría:peso
+	WishlistTargetDistribution []string `env:"WISHLIST_TARGET_DISTRIBUTION" envSeparator:"," envDefault:"top:30,bottom:25,snearkers:15,dress:10,accesories:15,backpack:5"`
+
+	// Storage de imágenes: "s3" o "local" para trabajar sin AWS
+	StorageDriver   string `env:"STORAGE_DRIVER" envDefault:"s3"`
+	LocalStorageDir string `env:"LOCAL_STORAGE_DIR" envDefault:"./storage"`
+	// URL pública de la API, usada en las URLs del storage local
+	PublicURL string `env:"PUBLIC_URL"`
+
+	// Servicio de quitar fondos, sin él las fotos se guardan tal cual
+	BackgroundRemovalEnabled bool          `env:"BACKGROUND_REMOVAL_ENABLED" envDefault:"false"`
+	BackgroundRemovalURL     string        `env:"BACKGROUND_REMOVAL_URL" envDefault:"http://background-removal-service:8000/remove-background"`
+	BackgroundRemovalTimeout time.Duration `env:"BACKGROUND_REMOVAL_TIMEOUT" envDefault:"30s"`
+	BackgroundRemovalRetries int           `env:"BACKGROUND_REMOVAL_RETRIES" envDefault:"2"`
+
+	// Subidas directas al storage con URLs firmadas
+	UploadURLExpiry time.Duration `env:"UPLOAD_URL_EXPIRY" envDefault:"15m"`
+	MaxUploadSize   int64         `env:"MAX_UPLOAD_SIZE" envDefault:"20971520"`
+}
+
+// BaseURL is the public URL of the API, PUBLIC_URL or localhost when unset.
+func (c *EnvConfig) BaseURL() string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/")
+	}
+	return fmt.Sprintf("http://localhost:%s", c.ServerPort)
 }
 
 func NewEnvConfig() *EnvConfig {
@@ -8,6 +8,14 @@ import (
 	"golang.org/x/oauth2/google"
 )
 
+// TwitterEndpoint is the OAuth 2.0 endpoint of X (Twitter). X only issues
+// tokens to public clients through the authorization code flow with PKCE.
+var TwitterEndpoint = oauth2.Endpoint{
+	AuthURL:   "https://twitter.com/i/oauth2/authorize",
+	TokenURL:  "https://api.twitter.com/2/oauth2/token",
+	AuthStyle: oauth2.AuthStyleInHeader,
+}
+
 var (
 	GoogleOAuthConfig = &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
@@ -25,13 +33,11 @@ var (
 		Endpoint:     facebook.Endpoint,
 	}
 
-	// Para Twitter, necesitarás usar OAuth1 o su API OAuth2 más reciente
-	// La implementación difiere un poco, aquí un placeholder:
 	TwitterOAuthConfig = &oauth2.Config{
 		ClientID:     os.Getenv("TWITTER_CLIENT_ID"),
 		ClientSecret: os.Getenv("TWITTER_CLIENT_SECRET"),
 		RedirectURL:  os.Getenv("TWITTER_REDIRECT_URL"),
 		Scopes:       []string{"tweet.read", "users.read", "offline.access"},
-		// Tendrías que implementar el endpoint para Twitter
+		Endpoint:     TwitterEndpoint,
 	}
 )
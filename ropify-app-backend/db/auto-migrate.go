@@ -13,9 +13,71 @@ func DBMigrator(db *gorm.DB) error {
 		log.Fatalf("Error creating uuid-ossp extension: %v", err)
 	}
 
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&models.User{},
+		&models.UserIdentity{},
+		&models.UsernameRedirect{},
 		&models.Garment{},
+		&models.GarmentStateChange{},
 		&models.Outfit{},
-	)
+		&models.OutfitRevision{},
+		&models.AccountDeletion{},
+		&models.PersonalAccessToken{},
+		&models.UploadIntent{},
+		&models.ShareLink{},
+		&models.Trip{},
+		&models.TripOutfit{},
+		&models.TripPackingCheck{},
+		&models.WishlistItem{},
+	); err != nil {
+		return err
+	}
+
+	// Copiar las identidades sociales antiguas de users a user_identities
+	for provider, column := range map[string]string{"google": "google_id", "facebook": "facebook_id", "twitter": "twitter_id"} {
+		err := db.Exec(`
+			INSERT INTO user_identities (id, user_id, provider, subject, email, linked_at)
+			SELECT uuid_generate_v4(), id, ?, `+column+`, email, created_at FROM users
+			WHERE `+column+` IS NOT NULL
+			ON CONFLICT (provider, subject) DO NOTHING`, provider).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	// Las importaciones se repiten sin duplicar prendas gracias al external_id
+	if err := db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_garments_user_external_id
+		ON garments (user_id, external_id) WHERE external_id IS NOT NULL`).Error; err != nil {
+		return err
+	}
+
+	// Filtros de outfits por tags y por prenda
+	for _, index := range []string{
+		"CREATE INDEX IF NOT EXISTS idx_outfits_tags ON outfits USING GIN (tags)",
+		"CREATE INDEX IF NOT EXISTS idx_outfits_garment_ids ON outfits USING GIN (garment_ids)",
+	} {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+
+	// El índice del hash no sirve para buscar por distancia de Hamming
+	if err := db.Exec("DROP INDEX IF EXISTS idx_garments_image_hash").Error; err != nil {
+		return err
+	}
+
+	// Los usuarios OAuth antiguos tenían el email como username. No quedan
+	// redirecciones desde el email, que volverían a exponerlo en el perfil
+	// público
+	if err := db.Exec(`DELETE FROM username_redirects WHERE old_username LIKE '%@%'`).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`
+		UPDATE users SET username = 'user_' || substr(replace(id::text, '-', ''), 1, 12)
+		WHERE username LIKE '%@%'`).Error; err != nil {
+		return err
+	}
+
+	return nil
 }
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"gorm.io/gorm"
+)
+
+type AccountHandler struct {
+	service models.AccountService
+}
+
+// Solicitar el borrado de la cuenta, se ejecuta tras el periodo de gracia
+func (h *AccountHandler) DeleteAccount(ctx *fiber.Ctx) error {
+	var payload struct {
+		Password string `json:"password"`
+	}
+
+	// El body es opcional para cuentas sin password
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deletion, err := h.service.RequestDeletion(context, userId, payload.Password)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account scheduled for deletion",
+		"data":    deletion,
+	})
+}
+
+// Cancelar un borrado pendiente durante el periodo de gracia
+func (h *AccountHandler) CancelDeletion(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := h.service.CancelDeletion(context, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "No pending deletion",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Account deletion cancelled",
+	})
+}
+
+// Exportar todos los datos del usuario en un ZIP
+func (h *AccountHandler) ExportAccount(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	prepareCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	export, err := h.service.PrepareExport(prepareCtx, userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("ropify-export-%s.zip", time.Now().Format("2006-01-02"))
+	ctx.Set(fiber.HeaderContentType, "application/zip")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// Las imágenes se envían a medida que se descargan del storage
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		if err := h.service.WriteExport(streamCtx, export, w); err != nil {
+			log.Errorf("export of user %s failed: %v", userId, err)
+		}
+		w.Flush()
+	})
+
+	return nil
+}
+
+func NewAccountHandler(router fiber.Router, service models.AccountService) {
+	handler := &AccountHandler{
+		service: service,
+	}
+
+	router.Delete("/", handler.DeleteAccount)
+	router.Post("/deletion/cancel", handler.CancelDeletion)
+	router.Get("/export", handler.ExportAccount)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	service models.AdminService
+}
+
+// Listar usuarios, filtros: q, role, suspended
+func (h *AdminHandler) ListUsers(ctx *fiber.Ctx) error {
+	pageParam := ctx.Query("page", "1")
+	limitParam := ctx.Query("limit", "20")
+
+	page, _ := strconv.Atoi(pageParam)
+	limit, _ := strconv.Atoi(limitParam)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	filter := &models.AdminUserFilter{
+		Search: ctx.Query("q", ""),
+		Role:   models.UserRole(ctx.Query("role", "")),
+	}
+	if suspendedParam := ctx.Query("suspended", ""); suspendedParam != "" {
+		suspended, err := strconv.ParseBool(suspendedParam)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "suspended must be true or false",
+			})
+		}
+		filter.Suspended = &suspended
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	users, total, err := h.service.ListUsers(context, filter, limit, offset)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   users,
+		"total":  total,
+	})
+}
+
+// Suspender un usuario
+func (h *AdminHandler) SuspendUser(ctx *fiber.Ctx) error {
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	return h.updateUser(ctx, func(c context.Context, actorID, userID uuid.UUID) (*models.AdminUser, error) {
+		return h.service.SuspendUser(c, actorID, userID, payload.Reason)
+	})
+}
+
+// Levantar la suspensión de un usuario
+func (h *AdminHandler) ReinstateUser(ctx *fiber.Ctx) error {
+	return h.updateUser(ctx, func(c context.Context, actorID, userID uuid.UUID) (*models.AdminUser, error) {
+		return h.service.ReinstateUser(c, userID)
+	})
+}
+
+// Cambiar el rol de un usuario
+func (h *AdminHandler) ChangeRole(ctx *fiber.Ctx) error {
+	var payload struct {
+		Role models.UserRole `json:"role" validate:"required"`
+	}
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	return h.updateUser(ctx, func(c context.Context, actorID, userID uuid.UUID) (*models.AdminUser, error) {
+		return h.service.ChangeRole(c, actorID, userID, payload.Role)
+	})
+}
+
+// updateUser resuelve el usuario de la ruta y el que hace la petición y
+// traduce los errores comunes a respuestas
+func (h *AdminHandler) updateUser(ctx *fiber.Ctx, update func(context.Context, uuid.UUID, uuid.UUID) (*models.AdminUser, error)) error {
+	userID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid user ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	actorID := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := update(context, actorID, userID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "User not found",
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user,
+	})
+}
+
+func NewAdminHandler(router fiber.Router, service models.AdminService) {
+	handler := &AdminHandler{
+		service: service,
+	}
+
+	router.Get("/users", handler.ListUsers)
+	router.Patch("/users/:id/suspend", handler.SuspendUser)
+	router.Patch("/users/:id/reinstate", handler.ReinstateUser)
+	router.Patch("/users/:id/role", handler.ChangeRole)
+}
@@ -50,7 +50,7 @@ func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 		"message": "Successfully logged in",
 		"data": &fiber.Map{
 			"token": token,
-			"user":  user,
+			"user":  user.Profile(),
 		},
 	})
 }
@@ -89,7 +89,7 @@ func (h *AuthHandler) Register(ctx *fiber.Ctx) error {
 		"message": "Successfully registered",
 		"data": &fiber.Map{
 			"token": token,
-			"user":  user,
+			"user":  user.Profile(),
 		},
 	})
 }
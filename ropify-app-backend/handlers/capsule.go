@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CapsuleHandler struct {
+	service models.CapsuleService
+}
+
+// Generar una cápsula de N prendas con el máximo de combinaciones, con
+// "save": true se guardan como outfits
+func (h *CapsuleHandler) GenerateCapsule(ctx *fiber.Ctx) error {
+	var payload models.CapsuleRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "size must be between 3 and 30 and max_outfits between 1 and 50",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	// La cápsula se arma con las prendas del closet
+	if !principal.HasScope(models.ScopeGarmentsRead) && !principal.HasScope(models.ScopeGarmentsWrite) {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Insufficient token scope",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	capsule, err := h.service.GenerateCapsule(context, principal.UserID, &payload)
+	if errors.Is(err, models.ErrInvalidCapsule) {
+		return ctx.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	status := fiber.StatusOK
+	if payload.Save {
+		status = fiber.StatusCreated
+	}
+	return ctx.Status(status).JSON(fiber.Map{
+		"status": "success",
+		"data":   capsule,
+	})
+}
+
+func NewCapsuleHandler(router fiber.Router, service models.CapsuleService) {
+	handler := &CapsuleHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.GenerateCapsule)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+var exportContentTypes = map[string]string{
+	models.ExportCSV:  "text/csv; charset=utf-8",
+	models.ExportJSON: fiber.MIMEApplicationJSONCharsetUTF8,
+	models.ExportPDF:  "application/pdf",
+}
+
+type ClosetExportHandler struct {
+	service models.ClosetExportService
+}
+
+// Exportar el closet en CSV, JSON o un lookbook en PDF, con ?outfits=true
+// se incluyen los outfits
+func (h *ClosetExportHandler) ExportCloset(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	format := ctx.Query("format", models.ExportJSON)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Format must be csv, json or pdf",
+		})
+	}
+
+	includeOutfits := ctx.QueryBool("outfits")
+	if includeOutfits && !principal.HasScope("outfits:read") && !principal.HasScope("outfits:write") {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Insufficient token scope",
+		})
+	}
+
+	prepareCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	export, err := h.service.PrepareExport(prepareCtx, userId, includeOutfits)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("ropify-closet-%s.%s", time.Now().Format("2006-01-02"), format)
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		streamCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		if err := h.service.WriteExport(streamCtx, export, format, w); err != nil {
+			log.Errorf("closet export of user %s failed: %v", userId, err)
+		}
+		w.Flush()
+	})
+
+	return nil
+}
+
+func NewClosetExportHandler(router fiber.Router, service models.ClosetExportService) {
+	handler := &ClosetExportHandler{
+		service: service,
+	}
+
+	router.Get("/", handler.ExportCloset)
+}
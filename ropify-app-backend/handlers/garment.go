@@ -2,19 +2,53 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"time"
 
+	"github.com/gaelzamora/ropify-app/middlewares"
 	"github.com/gaelzamora/ropify-app/models"
 	"github.com/gaelzamora/ropify-app/services"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type GarmentHandler struct {
 	repository models.GarmentRepository
+	images     models.GarmentImageService
+	duplicates models.DuplicateService
+	remover    services.BackgroundRemover
+}
+
+// findDuplicates busca prendas con una foto parecida, un fallo no impide
+// crear la prenda
+func (h *GarmentHandler) findDuplicates(ctx context.Context, garment *models.Garment) []*models.GarmentDuplicate {
+	duplicates, err := h.duplicates.FindDuplicates(ctx, garment)
+	if err != nil {
+		log.Warnf("failed to look for duplicates of garment %s: %v", garment.ID, err)
+		return []*models.GarmentDuplicate{}
+	}
+	return duplicates
+}
+
+// imageUploadError responde 400 cuando la imagen subida no es válida y 500
+// cuando falla el almacenamiento
+func imageUploadError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrInvalidImage) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "fail",
+		"message": "Failed to upload image: " + err.Error(),
+	})
 }
 
 func (h *GarmentHandler) AddGarment(ctx *fiber.Ctx) error {
@@ -26,24 +60,24 @@ func (h *GarmentHandler) AddGarment(ctx *fiber.Ctx) error {
 		})
 	}
 
-	userIdStr := ctx.Locals("userId").(string)
-	userId, err := uuid.Parse(userIdStr)
-	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Invalid user ID",
+			"message": "Unauthorized",
 		})
 	}
+	userId := principal.UserID
 	garment.UserID = userId
 
+	// Las prendas nuevas empiezan limpias, el estado solo cambia con transiciones
+	garment.State = models.StateClean
+	garment.StateNote = ""
+	garment.StateChangedAt = nil
+
 	// Procesar imagen si existe
 	file, err := ctx.FormFile("garment_image")
-	var imageURL string
-
 	if err == nil && file != nil {
-		// Si hay una imagen, subirla a S3
-		key := fmt.Sprintf("garments/users/%s", userId.String())
-		// Si quieres procesar la imagen antes de subirla, lee los bytes:
 		src, err := file.Open()
 		if err != nil {
 			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -59,16 +93,16 @@ func (h *GarmentHandler) AddGarment(ctx *fiber.Ctx) error {
 				"message": "Failed to read file: " + err.Error(),
 			})
 		}
-		// Aquí podrías llamar a RemoveBackground si lo deseas
-		// imageBytes, _ = services.RemoveBackground(imageBytes)
-		imageURL, err = services.UploadToS3Bytes(imageBytes, key, file.Filename)
+
+		// Se guardan las versiones thumb, medium y full sin metadatos
+		uploadCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		images, err := h.images.StoreGarmentImage(uploadCtx, userId, imageBytes)
 		if err != nil {
-			return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"status":  "fail",
-				"message": "Failed to upload image: " + err.Error(),
-			})
+			return imageUploadError(ctx, err)
 		}
-		garment.ImageURL = imageURL
+		garment.SetImages(images)
 	}
 
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -83,17 +117,27 @@ func (h *GarmentHandler) AddGarment(ctx *fiber.Ctx) error {
 	}
 
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"status": "success",
-		"data":   newGarment,
+		"status":     "success",
+		"data":       newGarment,
+		"duplicates": h.findDuplicates(context, newGarment),
 	})
 }
 
 func (h *GarmentHandler) FindByBarcode(ctx *fiber.Ctx) error {
 	barcode := ctx.Params("barcode")
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	garment, err := h.repository.FindByBarcode(context, barcode)
+	garment, err := h.repository.FindByBarcode(context, principal.UserID, barcode)
 	if err != nil {
 		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"status":  "fail",
@@ -129,6 +173,14 @@ func (h *GarmentHandler) DeleteMultipleGarments(ctx *fiber.Ctx) error {
 		})
 	}
 
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	// Crear contexto con timeout
 	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -146,6 +198,12 @@ func (h *GarmentHandler) DeleteMultipleGarments(ctx *fiber.Ctx) error {
 			continue
 		}
 
+		// Solo se eliminan prendas propias
+		if _, err := h.repository.GetGarmentByID(context, principal.UserID, garmentID); err != nil {
+			failedIDs[idStr] = "Garment not found"
+			continue
+		}
+
 		// Intentar eliminar la prenda
 		err = h.repository.DeleteGarment(context, garmentID)
 		if err != nil {
@@ -181,9 +239,41 @@ func (h *GarmentHandler) UpdateGarment(ctx *fiber.Ctx) error {
 			"message": err.Error()})
 	}
 
+	// El estado solo cambia con transiciones válidas
+	for _, field := range []string{"state", "state_note", "state_changed_at"} {
+		if _, ok := updateData[field]; ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Use /garment/state/:id to change the state of a garment",
+			})
+		}
+	}
+
+	// El dueño y el ID no se cambian
+	delete(updateData, "id")
+	delete(updateData, "user_id")
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if _, err := h.repository.GetGarmentByID(context, principal.UserID, garmentID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Garment not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "fail", "message": err.Error()})
+	}
+
 	updatedGarment, err := h.repository.UpdateGarment(context, garmentID, updateData)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "fail", "message": err.Error()})
@@ -200,7 +290,6 @@ func (h *GarmentHandler) FilterGarments(ctx *fiber.Ctx) error {
 	color := ctx.Query("color", "")
 	brand := ctx.Query("brand", "")
 	category := ctx.Query("category", "")
-	userIDParam := ctx.Query("user_id", "")
 
 	page, _ := strconv.Atoi(pageParam)
 	limit, _ := strconv.Atoi(limitParam)
@@ -217,17 +306,39 @@ func (h *GarmentHandler) FilterGarments(ctx *fiber.Ctx) error {
 		filters["category"] = category
 	}
 
-	var userID uuid.UUID
-	if userIDParam != "" {
-		userID, _ = uuid.Parse(userIDParam)
-	} else {
-		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+	// Por estado exacto o por disponibilidad (limpias o usadas, en el closet)
+	if state := models.GarmentState(ctx.Query("state")); state != "" {
+		if !state.Valid() {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "state must be clean, worn, in_laundry, at_tailor, lent_out or stored",
+			})
+		}
+		filters["state"] = state
+	} else if available := ctx.Query("available"); available != "" {
+		switch available {
+		case "true":
+			filters["state"] = models.AvailableStates
+		case "false":
+			filters["state"] = models.UnavailableStates
+		default:
+			return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "available must be true or false",
+			})
+		}
+	}
+
+	// Solo el closet propio, los de otros usuarios se comparten con enlaces
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "user_id is required",
+			"message": "Unauthorized",
 		})
 	}
 
-	garments, err := h.repository.FilterGarments(context, userID, filters, "created_at", limit, offset)
+	garments, err := h.repository.FilterGarments(context, principal.UserID, filters, "created_at", limit, offset)
 	if err != nil {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
 			"status":  "fail",
@@ -261,18 +372,15 @@ func (h *GarmentHandler) UploadGarmentImage(ctx *fiber.Ctx) error {
 		})
 	}
 
-	userIdStr := ctx.Locals("userId").(string)
-	userId, err := uuid.Parse(userIdStr)
-	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(&fiber.Map{
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
 			"status":  "fail",
-			"message": "Invalid user ID",
+			"message": "Unauthorized",
 		})
 	}
+	userId := principal.UserID
 
-	key := fmt.Sprintf("garments/%s/%s", userId.String(), file.Filename)
-
-	// Leer los bytes del archivo para poder procesar si es necesario
 	src, err := file.Open()
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
@@ -289,17 +397,15 @@ func (h *GarmentHandler) UploadGarmentImage(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Aquí podrías llamar a RemoveBackground si lo deseas
-	// imageBytes, _ = services.RemoveBackground(imageBytes)
-	imageURL, err := services.UploadToS3Bytes(imageBytes, key, file.Filename)
+	uploadCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	images, err := h.images.StoreGarmentImage(uploadCtx, userId, imageBytes)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
-			"status":  "fail",
-			"message": "Failed to upload file to S3",
-		})
+		return imageUploadError(ctx, err)
 	}
 
-	if err := h.repository.UpdateGarmentImage(userId, imageURL, garmentId); err != nil {
+	if err := h.repository.UpdateGarmentImage(userId, images, garmentId); err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&fiber.Map{
 			"status":  "fail",
 			"message": "Failed to update garment image",
@@ -309,7 +415,7 @@ func (h *GarmentHandler) UploadGarmentImage(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(&fiber.Map{
 		"status":  "success",
 		"message": "Garment image uploaded successfully",
-		"data":    imageURL,
+		"data":    images,
 	})
 }
 
@@ -332,34 +438,17 @@ func (h *GarmentHandler) LookupByBarcode(ctx *fiber.Ctx) error {
 		})
 	}
 
-	userIdStr := ctx.Locals("userId").(string)
-	userId, err := uuid.Parse(userIdStr)
-
-	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Invalid user ID",
+			"message": "Unauthorized",
 		})
 	}
+	userId := principal.UserID
 
 	// Mapear la categoría de la API a tu enum de GarmentCategory
-	category := models.Unknown // Valor predeterminado
-	switch productData.Category {
-	case "Tops", "Shirt", "T-Shirt":
-		category = models.Top
-	case "Bottoms", "Pants", "Jeans", "Shorts":
-		category = models.Bottoms
-	case "Dress", "Dresses":
-		category = models.Dress
-	case "Sneakers", "Shoes":
-		category = models.Sneakers
-	case "Accessories", "Jewelry", "Watches":
-		category = models.Accesories
-	case "Backpack", "Bag":
-		category = models.Backpack
-	default:
-		category = models.Unknown // Por defecto
-	}
+	category, _ := services.NormalizeCategory(productData.Category)
 
 	garment := models.Garment{
 		ID:         uuid.New(),
@@ -390,15 +479,14 @@ func (h *GarmentHandler) LookupByBarcode(ctx *fiber.Ctx) error {
 }
 
 func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
-	userIdStr := ctx.Locals("userId").(string)
-	userId, err := uuid.Parse(userIdStr)
-
-	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Invalid user ID",
+			"message": "Unauthorized",
 		})
 	}
+	userId := principal.UserID
 
 	file, err := ctx.FormFile("image")
 	if err != nil {
@@ -425,6 +513,12 @@ func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// Orientar y quitar metadatos antes de mandar la foto a otros servicios
+	imageBytes, err = services.NormalizeImage(imageBytes)
+	if err != nil {
+		return imageUploadError(ctx, err)
+	}
+
 	visionResult, err := services.AnalyzeGarmentImage(imageBytes)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -433,7 +527,10 @@ func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
 		})
 	}
 
-	imageBytesNoBg, err := services.RemoveBackground(imageBytes)
+	removeCtx, cancelRemove := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancelRemove()
+
+	imageBytesNoBg, err := h.remover.RemoveBackground(removeCtx, imageBytes)
 	if err != nil {
 		fmt.Println("Failed to remove background, Original image will be used: ", err)
 		imageBytesNoBg = imageBytes
@@ -441,13 +538,12 @@ func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
 		fmt.Println("Imagen received from RemoveBackground: ", len(imageBytesNoBg))
 	}
 
-	key := fmt.Sprintf("garments/users/%s", userId.String())
-	imageURL, err := services.UploadToS3Bytes(imageBytesNoBg, key, file.Filename)
+	uploadCtx, cancelUpload := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelUpload()
+
+	images, err := h.images.StoreGarmentImage(uploadCtx, userId, imageBytesNoBg)
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"status":  "fail",
-			"message": "Error uploading image to S3",
-		})
+		return imageUploadError(ctx, err)
 	}
 
 	var category models.GarmentCategory
@@ -478,10 +574,10 @@ func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
 		Category:   category,
 		Color:      color,
 		Labels:     visionResult.Labels,
-		ImageURL:   imageURL,
 		IsVerified: true,
 		CreatedAt:  time.Now(),
 	}
+	garment.SetImages(images)
 
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -497,15 +593,54 @@ func (h *GarmentHandler) AnalyzeAndCreateGarment(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"status": "success",
 		"data": fiber.Map{
-			"garment":  newGarment,
-			"analysis": visionResult,
+			"garment":    newGarment,
+			"analysis":   visionResult,
+			"duplicates": h.findDuplicates(context, newGarment),
 		},
 	})
 }
 
-func NewGarmentHandler(router fiber.Router, repository models.GarmentRepository) {
+// Grupos de prendas con fotos parecidas, para limpiar duplicados
+func (h *GarmentHandler) GetDuplicates(ctx *fiber.Ctx) error {
+	maxDistance, err := strconv.Atoi(ctx.Query("max_distance", strconv.Itoa(services.DuplicateHashDistance)))
+	if err != nil || maxDistance < 0 || maxDistance > 20 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "max_distance must be between 0 and 20",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	clusters, err := h.duplicates.ListClusters(context, principal.UserID, maxDistance)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   clusters,
+	})
+}
+
+func NewGarmentHandler(router fiber.Router, repository models.GarmentRepository, images models.GarmentImageService, duplicates models.DuplicateService, remover services.BackgroundRemover) {
 	handler := &GarmentHandler{
 		repository: repository,
+		images:     images,
+		duplicates: duplicates,
+		remover:    remover,
 	}
 
 	router.Post("/", handler.AddGarment)
@@ -514,6 +649,7 @@ func NewGarmentHandler(router fiber.Router, repository models.GarmentRepository)
 	router.Post("/:id", handler.UploadGarmentImage)
 
 	router.Get("/", handler.FilterGarments)
+	router.Get("/duplicates", handler.GetDuplicates)
 	router.Get("/:barcode", handler.FindByBarcode)
 
 	router.Patch("/:id", handler.UpdateGarment)
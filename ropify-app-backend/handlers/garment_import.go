@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type GarmentImportHandler struct {
+	service models.GarmentImportService
+}
+
+// Importar prendas desde un CSV o JSON, con ?dry_run=true solo se valida
+func (h *GarmentImportHandler) Import(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	format := strings.ToLower(ctx.Query("format"))
+	var file io.Reader
+
+	// Se acepta un multipart con el campo "file" o el archivo como body
+	if fileHeader, err := ctx.FormFile("file"); err == nil {
+		f, err := fileHeader.Open()
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Failed to open file",
+			})
+		}
+		defer f.Close()
+		file = f
+
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+		}
+	} else {
+		file = bytes.NewReader(ctx.Body())
+
+		if format == "" {
+			contentType := strings.ToLower(string(ctx.Request().Header.ContentType()))
+			switch {
+			case strings.Contains(contentType, "json"):
+				format = "json"
+			case strings.Contains(contentType, "csv"):
+				format = "csv"
+			}
+		}
+	}
+
+	if format != "csv" && format != "json" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Format must be csv or json",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	report, err := h.service.Import(context, principal.UserID, format, file, ctx.QueryBool("dry_run"))
+	switch {
+	case errors.Is(err, models.ErrInvalidImport):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	status := fiber.StatusOK
+	if !report.DryRun && report.Created > 0 {
+		status = fiber.StatusCreated
+	}
+
+	return ctx.Status(status).JSON(fiber.Map{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+func NewGarmentImportHandler(router fiber.Router, service models.GarmentImportService) {
+	handler := &GarmentImportHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.Import)
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GarmentStateHandler struct {
+	repository models.GarmentStateRepository
+	garments   models.GarmentRepository
+}
+
+// Cambiar el estado de una prenda (lavandería, sastre, prestada...)
+func (h *GarmentStateHandler) ChangeState(ctx *fiber.Ctx) error {
+	garmentID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid garment ID",
+		})
+	}
+
+	var payload models.GarmentStateRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil || !payload.State.Valid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "state must be clean, worn, in_laundry, at_tailor, lent_out or stored",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.repository.ChangeStates(context, principal.UserID, []uuid.UUID{garmentID}, payload.State, strings.TrimSpace(payload.Note))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if len(result.Rejected) > 0 {
+		rejected := result.Rejected[0]
+		if rejected.State == "" {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Garment not found",
+			})
+		}
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": models.ErrInvalidTransition.Error() + ": " + rejected.Reason,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   result.Changed[0],
+	})
+}
+
+// Cambiar el estado de varias prendas, las que no pueden cambiar se
+// informan sin detener al resto
+func (h *GarmentStateHandler) ChangeStates(ctx *fiber.Ctx) error {
+	var payload models.BulkGarmentStateRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil || !payload.State.Valid() {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "garment_ids (1 to 200) and a valid state are required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := h.repository.ChangeStates(context, principal.UserID, payload.GarmentIDs, payload.State, strings.TrimSpace(payload.Note))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// Día de lavado: lo que está en la lavandería vuelve limpio, con
+// ?include_worn=true también lo usado
+func (h *GarmentStateHandler) LaundryDay(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	from := []models.GarmentState{models.StateInLaundry}
+	if ctx.QueryBool("include_worn") {
+		from = append(from, models.StateWorn)
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cleaned, err := h.repository.ResetStates(context, principal.UserID, from, models.StateClean)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   fiber.Map{"cleaned": cleaned},
+	})
+}
+
+// Historial de estados de una prenda
+func (h *GarmentStateHandler) GetStateHistory(ctx *fiber.Ctx) error {
+	garmentID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid garment ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.garments.GetGarmentByID(context, principal.UserID, garmentID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Garment not found",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	changes, err := h.repository.ListStateChanges(context, principal.UserID, garmentID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   changes,
+	})
+}
+
+func NewGarmentStateHandler(router fiber.Router, repository models.GarmentStateRepository, garments models.GarmentRepository) {
+	handler := &GarmentStateHandler{
+		repository: repository,
+		garments:   garments,
+	}
+
+	router.Post("/", handler.ChangeStates)
+	router.Post("/laundry-day", handler.LaundryDay)
+	router.Post("/:id", handler.ChangeState)
+	router.Get("/:id/history", handler.GetStateHistory)
+}
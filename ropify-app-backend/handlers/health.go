@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHandler struct {
+	remover services.BackgroundRemover
+}
+
+// Estado de los servicios externos
+func (h *HealthHandler) GetHealth(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status := fiber.StatusOK
+	backgroundRemoval := "ok"
+	if err := h.remover.Health(context); err != nil {
+		status = fiber.StatusServiceUnavailable
+		backgroundRemoval = err.Error()
+	}
+
+	return ctx.Status(status).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"background_removal": backgroundRemoval,
+		},
+	})
+}
+
+func NewHealthHandler(router fiber.Router, remover services.BackgroundRemover) {
+	handler := &HealthHandler{
+		remover: remover,
+	}
+
+	router.Get("/", handler.GetHealth)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImageGCHandler struct {
+	service models.ImageGCService
+}
+
+// Métricas de la limpieza de imágenes y el último reporte
+func (h *ImageGCHandler) GetMetrics(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   h.service.Metrics(),
+	})
+}
+
+// Lanzar la limpieza, por defecto en dry run (?dry_run=false para borrar)
+func (h *ImageGCHandler) RunGC(ctx *fiber.Ctx) error {
+	dryRun, err := strconv.ParseBool(ctx.Query("dry_run", "true"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "dry_run must be true or false",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	report, err := h.service.Run(context, dryRun)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+			"data":    report,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   report,
+	})
+}
+
+func NewImageGCHandler(router fiber.Router, service models.ImageGCService) {
+	handler := &ImageGCHandler{
+		service: service,
+	}
+
+	router.Get("/", handler.GetMetrics)
+	router.Post("/", handler.RunGC)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/gaelzamora/ropify-app/services"
@@ -14,6 +15,7 @@ type OAuthHandler struct {
 
 func (h *OAuthHandler) GoogleToken(ctx *fiber.Ctx) error {
 	var request struct {
+		IDToken     string `json:"id_token"`
 		AccessToken string `json:"access_token"`
 	}
 
@@ -24,13 +26,23 @@ func (h *OAuthHandler) GoogleToken(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Usar el access_token para obtener información del usuario
+	if request.IDToken == "" && request.AccessToken == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "id_token or access_token is required",
+		})
+	}
+
+	// El id_token se verifica localmente, el access_token solo como respaldo
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	token, user, err := h.service.HandleGoogleToken(context, request.AccessToken)
+	token, user, err := h.service.HandleGoogleToken(context, request.IDToken, request.AccessToken)
+	if linkErr := (*services.LinkRequiredError)(nil); errors.As(err, &linkErr) {
+		return linkRequired(ctx, linkErr)
+	}
 	if err != nil {
-		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
 		})
@@ -41,12 +53,78 @@ func (h *OAuthHandler) GoogleToken(ctx *fiber.Ctx) error {
 		"message": "Successfully authenticated with Google",
 		"data": fiber.Map{
 			"token": token,
-			"user":  user,
+			"user":  user.Profile(),
 		},
 	})
 }
 
-// Implementar métodos similares para Facebook y Twitter
+// Redirige al consentimiento del proveedor (google, facebook o twitter)
+func (h *OAuthHandler) Login(ctx *fiber.Ctx) error {
+	authURL, err := h.service.BeginLogin(ctx.Params("provider"))
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Redirect(authURL, fiber.StatusTemporaryRedirect)
+}
+
+func (h *OAuthHandler) Callback(ctx *fiber.Ctx) error {
+	if errParam := ctx.Query("error"); errParam != "" {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Authorization denied: " + errParam,
+		})
+	}
+
+	code := ctx.Query("code")
+	state := ctx.Query("state")
+	if code == "" || state == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "code and state are required",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	token, user, err := h.service.HandleCallback(context, ctx.Params("provider"), code, state)
+	if linkErr := (*services.LinkRequiredError)(nil); errors.As(err, &linkErr) {
+		return linkRequired(ctx, linkErr)
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Successfully authenticated",
+		"data": fiber.Map{
+			"token": token,
+			"user":  user.Profile(),
+		},
+	})
+}
+
+// linkRequired responde cuando el email ya pertenece a otra cuenta. El
+// link_token se confirma en POST /api/me/identities/link una vez autenticado.
+func linkRequired(ctx *fiber.Ctx, err *services.LinkRequiredError) error {
+	return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+		"status":  "fail",
+		"message": err.Error(),
+		"data": fiber.Map{
+			"provider":   err.Provider,
+			"email":      err.Email,
+			"link_token": err.LinkToken,
+		},
+	})
+}
 
 func NewOAuthHandler(route fiber.Router, service *services.OAuthService) {
 	handler := &OAuthHandler{
@@ -56,11 +134,7 @@ func NewOAuthHandler(route fiber.Router, service *services.OAuthService) {
 	// Rutas para Google
 	route.Post("/google/token", handler.GoogleToken)
 
-	// Rutas para Facebook
-	//route.Get("/facebook/login", handler.FacebookLogin)
-	//route.Get("/facebook/callback", handler.FacebookCallback)
-
-	// Rutas para Twitter
-	//route.Get("/twitter/login", handler.TwitterLogin)
-	//route.Get("/twitter/callback", handler.TwitterCallback)
+	// Rutas para Google, Facebook y Twitter (X)
+	route.Get("/:provider/login", handler.Login)
+	route.Get("/:provider/callback", handler.Callback)
 }
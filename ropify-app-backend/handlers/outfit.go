@@ -2,17 +2,25 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gaelzamora/ropify-app/middlewares"
 	"github.com/gaelzamora/ropify-app/models"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"gorm.io/gorm"
 )
 
 type OutfitHandler struct {
 	repository models.OutfitRepository
+	garments   models.GarmentRepository
+	collages   models.OutfitCollageService
 }
 
 // Crear outfit
@@ -24,9 +32,32 @@ func (h *OutfitHandler) CreateOutfit(ctx *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
+
+	if err := validateOutfitEnums(&outfit.Occasion, &outfit.Season); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	// El dueño es siempre quien lo crea, no el user_id del body
+	outfit.UserID = principal.UserID
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if err := h.ownGarments(context, principal.UserID, outfit.GarmentIDs); err != nil {
+		return outfitGarmentsError(ctx, err)
+	}
+
 	newOutfit, err := h.repository.AddOutfit(context, &outfit)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -34,6 +65,11 @@ func (h *OutfitHandler) CreateOutfit(ctx *fiber.Ctx) error {
 			"message": err.Error(),
 		})
 	}
+
+	if len(newOutfit.GarmentIDs) > 0 {
+		h.renderCollage(newOutfit)
+	}
+
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"status": "success",
 		"data":   newOutfit,
@@ -59,44 +95,351 @@ func (h *OutfitHandler) UpdateOutfit(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// Convierte tags a pq.StringArray si existe
-	if tags, ok := updateData["tags"]; ok {
-		if arr, ok := tags.([]interface{}); ok {
-			strArr := make(pq.StringArray, len(arr))
-			for i, v := range arr {
-				strArr[i] = v.(string)
+	// Campos que no se editan desde el body, el archivo tiene sus rutas
+	for _, key := range []string{"id", "user_id", "image_url", "created_at", "updated_at", "archived", "archived_at", "unarchived_at", "cloned_from_id"} {
+		delete(updateData, key)
+	}
+
+	// Convierte tags y garment_ids a pq.StringArray si existen
+	for _, key := range []string{"tags", "garment_ids"} {
+		if value, ok := updateData[key]; ok {
+			strArr, err := stringArray(value)
+			if err != nil {
+				return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "fail",
+					"message": fmt.Sprintf("%s %s", key, err.Error()),
+				})
 			}
-			updateData["tags"] = strArr
+			updateData[key] = strArr
 		}
 	}
 
-	// Convierte garment_ids a pq.StringArray si existe
-	if garmentIDs, ok := updateData["garment_ids"]; ok {
-		if arr, ok := garmentIDs.([]interface{}); ok {
-			strArr := make(pq.StringArray, len(arr))
-			for i, v := range arr {
-				strArr[i] = v.(string)
-			}
-			updateData["garment_ids"] = strArr
+	// Valida occasion y season solo si vienen en el body
+	_, hasOccasion := updateData["occasion"]
+	_, hasSeason := updateData["season"]
+	if hasOccasion || hasSeason {
+		occasion, _ := updateData["occasion"].(string)
+		season, _ := updateData["season"].(string)
+		if err := validateOutfitEnums(&occasion, &season); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		if hasOccasion {
+			updateData["occasion"] = occasion
+		}
+		if hasSeason {
+			updateData["season"] = season
+		}
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.ownOutfit(context, outfitID, principal.UserID); err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	if _, ok := updateData["garment_ids"]; ok {
+		if err := h.ownGarments(context, principal.UserID, updateData["garment_ids"].(pq.StringArray)); err != nil {
+			return outfitGarmentsError(ctx, err)
+		}
+	}
+
+	updatedOutfit, err := h.repository.UpdateOutfit(context, outfitID, principal.UserID, updateData)
+	if err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	if _, ok := updateData["garment_ids"]; ok {
+		h.renderCollage(updatedOutfit)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   updatedOutfit,
+	})
+}
+
+// Duplicar un outfit propio, opcionalmente con otro nombre
+func (h *OutfitHandler) CloneOutfit(ctx *fiber.Ctx) error {
+	outfitID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid outfit ID",
+		})
+	}
+
+	var payload struct {
+		Name string `json:"name"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid request body",
+			})
 		}
 	}
 
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	source, err := h.ownOutfit(context, outfitID, principal.UserID)
+	if err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	name := strings.TrimSpace(payload.Name)
+	if name == "" {
+		name = source.Name + " (copy)"
+	}
+
+	clone := &models.Outfit{
+		UserID:       principal.UserID,
+		Name:         name,
+		GarmentIDs:   append(pq.StringArray{}, source.GarmentIDs...),
+		Tags:         append(pq.StringArray{}, source.Tags...),
+		Occasion:     source.Occasion,
+		Season:       source.Season,
+		ClonedFromID: &source.ID,
+	}
+
+	newOutfit, err := h.repository.AddOutfit(context, clone)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	// El clon tiene su propio collage, el del original se borra al cambiarlo
+	if len(newOutfit.GarmentIDs) > 0 {
+		h.renderCollage(newOutfit)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   newOutfit,
+	})
+}
+
+// Historial de cambios de un outfit, el más reciente primero
+func (h *OutfitHandler) ListRevisions(ctx *fiber.Ctx) error {
+	outfitID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid outfit ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	updatedOutfit, err := h.repository.UpdateOutfit(context, outfitID, updateData)
+	if _, err := h.ownOutfit(context, outfitID, principal.UserID); err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	revisions, err := h.repository.ListRevisions(context, outfitID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "fail",
 			"message": err.Error(),
 		})
 	}
+
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
-		"data":   updatedOutfit,
+		"data":   revisions,
+	})
+}
+
+// Volver a una versión anterior, queda registrado como una revisión nueva
+func (h *OutfitHandler) RestoreRevision(ctx *fiber.Ctx) error {
+	outfitID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid outfit ID",
+		})
+	}
+
+	version, err := strconv.Atoi(ctx.Params("version"))
+	if err != nil || version < 1 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid version",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := h.ownOutfit(context, outfitID, principal.UserID)
+	if err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	restored, err := h.repository.RestoreRevision(context, outfitID, principal.UserID, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Revision not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	if strings.Join(current.GarmentIDs, ",") != strings.Join(restored.GarmentIDs, ",") {
+		h.renderCollage(restored)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   restored,
+	})
+}
+
+// ownOutfit returns the outfit if it belongs to the user, outfits of other
+// users are reported as not found.
+func (h *OutfitHandler) ownOutfit(ctx context.Context, outfitID, userID uuid.UUID) (*models.Outfit, error) {
+	outfit, err := h.repository.GetOutfitByID(ctx, outfitID)
+	if err != nil {
+		return nil, err
+	}
+	if outfit.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return outfit, nil
+}
+
+// stringArray converts a JSON array of strings from the body.
+func stringArray(value interface{}) (pq.StringArray, error) {
+	arr, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+
+	strArr := make(pq.StringArray, len(arr))
+	for i, v := range arr {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		strArr[i] = str
+	}
+	return strArr, nil
+}
+
+// ownGarments checks that every garment of an outfit is in the closet of the
+// user, so outfits and their collages never show garments of someone else.
+func (h *OutfitHandler) ownGarments(ctx context.Context, userID uuid.UUID, garmentIDs []string) error {
+	if len(garmentIDs) == 0 {
+		return nil
+	}
+
+	for _, garmentID := range garmentIDs {
+		if _, err := uuid.Parse(garmentID); err != nil {
+			return fmt.Errorf("%w: invalid garment ID %q", models.ErrForeignGarment, garmentID)
+		}
+	}
+
+	found, err := h.garments.GetGarmentsByIDs(ctx, userID, garmentIDs)
+	if err != nil {
+		return err
+	}
+	owned := make(map[string]bool, len(found))
+	for _, garment := range found {
+		owned[garment.ID.String()] = true
+	}
+
+	for _, garmentID := range garmentIDs {
+		if !owned[strings.ToLower(garmentID)] {
+			return fmt.Errorf("%w: %s", models.ErrForeignGarment, garmentID)
+		}
+	}
+	return nil
+}
+
+func outfitGarmentsError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, models.ErrForeignGarment) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "fail",
+		"message": err.Error(),
+	})
+}
+
+func outfitLookupError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Outfit not found",
+		})
+	}
+	return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "fail",
+		"message": err.Error(),
 	})
 }
 
+// El collage se genera de nuevo cuando cambian las prendas, si falla el
+// outfit se guarda igual
+func (h *OutfitHandler) renderCollage(outfit *models.Outfit) {
+	context, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	imageURL, err := h.collages.RenderCollage(context, outfit.ID)
+	if err != nil {
+		log.Warnf("collage of outfit %s failed: %v", outfit.ID, err)
+		return
+	}
+	outfit.ImageURL = imageURL
+}
+
 // Eliminar outfit
 func (h *OutfitHandler) DeleteOutfit(ctx *fiber.Ctx) error {
 	idParam := ctx.Params("id")
@@ -108,9 +451,21 @@ func (h *OutfitHandler) DeleteOutfit(ctx *fiber.Ctx) error {
 		})
 	}
 
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if _, err := h.ownOutfit(context, outfitID, principal.UserID); err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
 	err = h.repository.DeleteOutfit(context, outfitID)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -135,10 +490,94 @@ func (h *OutfitHandler) ArchiveOutfit(ctx *fiber.Ctx) error {
 		})
 	}
 
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.ownOutfit(context, outfitID, principal.UserID); err != nil {
+		return outfitLookupError(ctx, err)
+	}
+
+	err = h.repository.ArchiveOutfit(context, principal.UserID, outfitID)
+	if err != nil {
+		return outfitLookupError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Outfit archived",
+	})
+}
+
+// Desarchivar outfit
+func (h *OutfitHandler) UnarchiveOutfit(ctx *fiber.Ctx) error {
+	outfitID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid outfit ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.repository.UnarchiveOutfit(context, principal.UserID, outfitID)
+	if err != nil {
+		return outfitLookupError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Outfit unarchived",
+	})
+}
+
+// Archivar varios outfits a la vez
+func (h *OutfitHandler) ArchiveOutfits(ctx *fiber.Ctx) error {
+	var payload struct {
+		IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=100"`
+	}
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "ids must have between 1 and 100 outfit IDs",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = h.repository.ArchiveOutfit(context, outfitID)
+	archived, err := h.repository.ArchiveOutfits(context, principal.UserID, payload.IDs)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "fail",
@@ -147,7 +586,8 @@ func (h *OutfitHandler) ArchiveOutfit(ctx *fiber.Ctx) error {
 	}
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Outfit archived",
+		"message": "Outfits archived",
+		"data":    fiber.Map{"archived": archived},
 	})
 }
 
@@ -162,15 +602,21 @@ func (h *OutfitHandler) GetOutfit(ctx *fiber.Ctx) error {
 		})
 	}
 
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	outfit, err := h.repository.GetOutfitByID(context, outfitID)
+	// Los outfits de otros usuarios se comparten con enlaces
+	outfit, err := h.ownOutfit(context, outfitID, principal.UserID)
 	if err != nil {
-		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"status":  "fail",
-			"message": "Outfit not found",
-		})
+		return outfitLookupError(ctx, err)
 	}
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
@@ -178,27 +624,35 @@ func (h *OutfitHandler) GetOutfit(ctx *fiber.Ctx) error {
 	})
 }
 
-// Listar outfits de un usuario
+// Listar los outfits propios, los de otros usuarios se comparten con enlaces
 func (h *OutfitHandler) GetOutfitsByUser(ctx *fiber.Ctx) error {
-	userIDParam := ctx.Query("user_id", "")
 	limitParam := ctx.Query("limit", "10")
 	pageParam := ctx.Query("page", "1")
 
-	userID, err := uuid.Parse(userIDParam)
-	if err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "fail",
-			"message": "Invalid user ID",
+			"message": "Unauthorized",
 		})
 	}
+
 	limit, _ := strconv.Atoi(limitParam)
 	page, _ := strconv.Atoi(pageParam)
 	offset := (page - 1) * limit
 
+	filters, err := outfitFiltersFromQuery(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
 	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	outfits, err := h.repository.GetOutfitsByUser(context, userID, limit, offset)
+	outfits, err := h.repository.GetOutfitsByUser(context, principal.UserID, filters, limit, offset)
 	if err != nil {
 		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "fail",
@@ -211,14 +665,89 @@ func (h *OutfitHandler) GetOutfitsByUser(ctx *fiber.Ctx) error {
 	})
 }
 
-func NewOutfitHandler(router fiber.Router, repository models.OutfitRepository) {
+// outfitFiltersFromQuery reads the filters of a listing, tags are comma
+// separated.
+func outfitFiltersFromQuery(ctx *fiber.Ctx) (models.OutfitFilters, error) {
+	// Por defecto no se listan los archivados
+	filters := models.OutfitFilters{
+		Archived: ctx.Query("archived", models.ArchivedExclude),
+		AnyTags:  splitTags(ctx.Query("tags_any")),
+		AllTags:  splitTags(ctx.Query("tags_all")),
+		Occasion: strings.ToLower(strings.TrimSpace(ctx.Query("occasion"))),
+		Season:   strings.ToLower(strings.TrimSpace(ctx.Query("season"))),
+		Sort:     ctx.Query("sort", models.OutfitSortNewest),
+	}
+
+	switch filters.Archived {
+	case models.ArchivedExclude, models.ArchivedOnly, models.ArchivedInclude:
+	default:
+		return filters, errors.New("archived must be exclude, only or include")
+	}
+
+	if filters.Occasion != "" && !models.ValidOccasion(filters.Occasion) {
+		return filters, fmt.Errorf("occasion must be one of %s", strings.Join(models.OutfitOccasions, ", "))
+	}
+	if filters.Season != "" && !models.ValidSeason(filters.Season) {
+		return filters, fmt.Errorf("season must be one of %s", strings.Join(models.OutfitSeasons, ", "))
+	}
+
+	if garmentParam := ctx.Query("garment_id"); garmentParam != "" {
+		garmentID, err := uuid.Parse(garmentParam)
+		if err != nil {
+			return filters, errors.New("Invalid garment ID")
+		}
+		filters.GarmentID = &garmentID
+	}
+
+	switch filters.Sort {
+	case models.OutfitSortNewest, models.OutfitSortOldest, models.OutfitSortName, models.OutfitSortGarmentCount:
+	default:
+		return filters, errors.New("sort must be newest, oldest, name or garment_count")
+	}
+
+	return filters, nil
+}
+
+func splitTags(param string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(param, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// validateOutfitEnums normalizes occasion and season, which can be empty.
+func validateOutfitEnums(occasion, season *string) error {
+	*occasion = strings.ToLower(strings.TrimSpace(*occasion))
+	*season = strings.ToLower(strings.TrimSpace(*season))
+
+	if *occasion != "" && !models.ValidOccasion(*occasion) {
+		return fmt.Errorf("occasion must be one of %s", strings.Join(models.OutfitOccasions, ", "))
+	}
+	if *season != "" && !models.ValidSeason(*season) {
+		return fmt.Errorf("season must be one of %s", strings.Join(models.OutfitSeasons, ", "))
+	}
+	return nil
+}
+
+func NewOutfitHandler(router fiber.Router, repository models.OutfitRepository, garments models.GarmentRepository, collages models.OutfitCollageService) {
 	handler := &OutfitHandler{
 		repository: repository,
+		garments:   garments,
+		collages:   collages,
 	}
 
 	router.Post("/", handler.CreateOutfit)
+	// Antes de /:id para que "archive" no se tome como un ID
+	router.Patch("/archive", handler.ArchiveOutfits)
 	router.Patch("/:id", handler.UpdateOutfit)
 	router.Patch("/:id/archive", handler.ArchiveOutfit)
+	router.Patch("/:id/unarchive", handler.UnarchiveOutfit)
+	router.Post("/:id/clone", handler.CloneOutfit)
+	router.Get("/:id/revisions", handler.ListRevisions)
+	router.Post("/:id/revisions/:version/restore", handler.RestoreRevision)
 	router.Get("/", handler.GetOutfitsByUser)
 	router.Get("/:id", handler.GetOutfit)
 	router.Delete("/:id", handler.DeleteOutfit)	
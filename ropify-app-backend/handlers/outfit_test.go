@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// queryFilters runs outfitFiltersFromQuery on the query of a request.
+func queryFilters(t *testing.T, query string) (models.OutfitFilters, error) {
+	t.Helper()
+
+	var filters models.OutfitFilters
+	var filtersErr error
+	app := fiber.New()
+	app.Get("/", func(ctx *fiber.Ctx) error {
+		filters, filtersErr = outfitFiltersFromQuery(ctx)
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/?"+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return filters, filtersErr
+}
+
+func TestOutfitFiltersTags(t *testing.T) {
+	tests := []struct {
+		query   string
+		anyTags []string
+		allTags []string
+	}{
+		{"", []string{}, []string{}},
+		{"tags_any=work", []string{"work"}, []string{}},
+		{"tags_any=work,%20summer,,&tags_all=casual", []string{"work", "summer"}, []string{"casual"}},
+		{"tags_all=%20,", []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		filters, err := queryFilters(t, tt.query)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(filters.AnyTags, tt.anyTags) || !reflect.DeepEqual(filters.AllTags, tt.allTags) {
+			t.Errorf("%q: tags any %q all %q, want any %q all %q", tt.query, filters.AnyTags, filters.AllTags, tt.anyTags, tt.allTags)
+		}
+	}
+}
+
+func TestOutfitFiltersValidation(t *testing.T) {
+	tests := []struct {
+		query   string
+		sort    string
+		wantErr bool
+	}{
+		{"", models.OutfitSortNewest, false},
+		{"sort=oldest", models.OutfitSortOldest, false},
+		{"sort=name", models.OutfitSortName, false},
+		{"sort=garment_count", models.OutfitSortGarmentCount, false},
+		{"sort=created_at", "", true},
+		{"sort=name;DROP", "", true},
+		{"archived=all", "", true},
+		{"occasion=wedding", "", true},
+		{"garment_id=nope", "", true},
+	}
+
+	for _, tt := range tests {
+		filters, err := queryFilters(t, tt.query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && filters.Sort != tt.sort {
+			t.Errorf("%q: sort = %q, want %q", tt.query, filters.Sort, tt.sort)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxAvatarSize = 5 * 1024 * 1024
+
+type ProfileHandler struct {
+	service models.ProfileService
+}
+
+// Perfil del usuario autenticado
+func (h *ProfileHandler) GetMe(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, err := h.service.GetProfile(context, userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "User not found",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   profile,
+	})
+}
+
+// Editar nombre, apellido y bio
+func (h *ProfileHandler) UpdateMe(ctx *fiber.Ctx) error {
+	var update models.ProfileUpdate
+	if err := ctx.BodyParser(&update); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(update); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, err := h.service.UpdateProfile(context, userId, &update)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   profile,
+	})
+}
+
+// Subir avatar (campo "avatar"), se recorta a un cuadrado en el servidor
+func (h *ProfileHandler) UploadAvatar(ctx *fiber.Ctx) error {
+	file, err := ctx.FormFile("avatar")
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "No avatar provided",
+		})
+	}
+
+	if file.Size > maxAvatarSize {
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Avatar must be smaller than 5MB",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	src, err := file.Open()
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Failed to open file",
+		})
+	}
+	defer src.Close()
+
+	imageBytes, err := io.ReadAll(io.LimitReader(src, maxAvatarSize))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Failed to read file",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	profile, err := h.service.UpdateAvatar(context, userId, imageBytes)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   profile,
+	})
+}
+
+// Perfil público, solo campos públicos y conteos
+func (h *ProfileHandler) GetPublicProfile(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	profile, redirected, err := h.service.GetPublicProfile(context, ctx.Params("username"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "User not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	// Username antiguo: el cliente debe usar el actual
+	if redirected {
+		ctx.Set(fiber.HeaderLocation, "/api/users/"+profile.Username)
+		return ctx.Status(fiber.StatusMovedPermanently).JSON(fiber.Map{
+			"status": "success",
+			"data":   profile,
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   profile,
+	})
+}
+
+func NewProfileHandler(router fiber.Router, service models.ProfileService) {
+	handler := &ProfileHandler{
+		service: service,
+	}
+
+	router.Get("/", handler.GetMe)
+	router.Patch("/", handler.UpdateMe)
+	router.Post("/avatar", handler.UploadAvatar)
+}
+
+func NewPublicProfileHandler(router fiber.Router, service models.ProfileService) {
+	handler := &ProfileHandler{
+		service: service,
+	}
+
+	router.Get("/:username", handler.GetPublicProfile)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sharePage is the page opened from a share link, the Open Graph tags give
+// the preview shown by chat apps and social networks.
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - Ropify</title>
+<meta name="description" content="{{.Description}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="Ropify">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.Image}}">{{else}}<meta name="twitter:card" content="summary">{{end}}
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="robots" content="noindex">
+<style>
+body{font-family:-apple-system,Helvetica,Arial,sans-serif;margin:0 auto;max-width:960px;padding:24px;color:#222}
+.cover{width:100%;max-width:540px;display:block;margin:0 auto 24px}
+.grid{display:grid;grid-template-columns:repeat(auto-fill,minmax(160px,1fr));gap:16px}
+.grid figure{margin:0}.grid img{width:100%;aspect-ratio:1;object-fit:contain;background:#f4f4f4}
+figcaption{font-size:13px;margin-top:4px}small{color:#777}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Description}}</p>
+{{if .Cover}}<img class="cover" src="{{.Cover}}" alt="{{.Title}}">{{end}}
+<div class="grid">
+{{range .Garments}}<figure>{{if .ThumbnailURL}}<img src="{{.ThumbnailURL}}" alt="{{.Color}}" loading="lazy">{{else if .ImageURL}}<img src="{{.ImageURL}}" alt="{{.Color}}" loading="lazy">{{end}}
+<figcaption>{{.Color}}{{if .Brand}} <small>{{.Brand}}</small>{{end}}</figcaption></figure>
+{{end}}</div>
+</body>
+</html>
+`))
+
+var shareErrorPage = template.Must(template.New("share-error").Parse(`<!DOCTYPE html>
+<html lang="es">
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Ropify</title></head>
+<body style="font-family:Helvetica,Arial,sans-serif;text-align:center;padding:48px">
+<h1>{{.}}</h1>
+</body>
+</html>
+`))
+
+type ShareHandler struct {
+	service models.ShareService
+}
+
+// Crear un link público para un outfit o el closet (filtrado)
+func (h *ShareHandler) CreateShare(ctx *fiber.Ctx) error {
+	var payload models.ShareRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "target must be outfit or closet",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := h.service.CreateShare(context, userId, &payload)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Outfit not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   link,
+	})
+}
+
+// Listar los links del usuario con sus visitas
+func (h *ShareHandler) ListShares(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	links, err := h.service.ListShares(context, userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   links,
+	})
+}
+
+// Revocar un link, deja de funcionar de inmediato
+func (h *ShareHandler) RevokeShare(ctx *fiber.Ctx) error {
+	linkID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid share link ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.service.RevokeShare(context, userId, linkID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Share link not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Share link revoked",
+	})
+}
+
+// Contenido de un link público en JSON, sin autenticación. Las visitas solo
+// se cuentan en la página HTML
+func (h *ShareHandler) GetShare(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	content, err := h.service.ViewShare(context, ctx.Params("token"), false)
+	switch {
+	case errors.Is(err, models.ErrShareNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Share link not found",
+		})
+	case errors.Is(err, models.ErrShareExpired):
+		return ctx.Status(fiber.StatusGone).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   content,
+	})
+}
+
+// Página HTML de un link público, con las etiquetas Open Graph para la vista previa
+func (h *ShareHandler) GetSharePage(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+
+	content, err := h.service.ViewShare(context, ctx.Params("token"), true)
+	switch {
+	case errors.Is(err, models.ErrShareNotFound):
+		return renderShareError(ctx, fiber.StatusNotFound, "Este link no existe")
+	case errors.Is(err, models.ErrShareExpired):
+		return renderShareError(ctx, fiber.StatusGone, "Este link expiró o fue revocado")
+	case err != nil:
+		return renderShareError(ctx, fiber.StatusInternalServerError, "No pudimos cargar este link")
+	}
+
+	page := struct {
+		Title       string
+		Description string
+		URL         string
+		Image       string
+		Cover       string
+		Garments    []*models.SharedGarment
+	}{URL: content.URL}
+
+	owner := "@" + content.Owner.Username
+	if content.Outfit != nil {
+		page.Title = content.Outfit.Name
+		page.Description = fmt.Sprintf("Outfit de %s, %d prendas", owner, len(content.Outfit.Garments))
+		page.Cover = content.Outfit.ImageURL
+		page.Garments = content.Outfit.Garments
+	} else {
+		page.Title = "Closet de " + owner
+		page.Description = fmt.Sprintf("%d prendas", len(content.Garments))
+		if content.Filters != nil && content.Filters.Category != "" {
+			page.Description += " - " + string(content.Filters.Category)
+		}
+		if content.Filters != nil && content.Filters.Color != "" {
+			page.Description += " - " + content.Filters.Color
+		}
+		page.Garments = content.Garments
+	}
+
+	page.Image = page.Cover
+	for _, garment := range page.Garments {
+		if page.Image != "" {
+			break
+		}
+		if garment.MediumURL != "" {
+			page.Image = garment.MediumURL
+		} else {
+			page.Image = garment.ImageURL
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := sharePage.Execute(&buf, page); err != nil {
+		return renderShareError(ctx, fiber.StatusInternalServerError, "No pudimos cargar este link")
+	}
+
+	return ctx.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+func renderShareError(ctx *fiber.Ctx, status int, message string) error {
+	var buf bytes.Buffer
+	if err := shareErrorPage.Execute(&buf, message); err != nil {
+		return err
+	}
+	return ctx.Status(status).Send(buf.Bytes())
+}
+
+func NewShareHandler(router fiber.Router, service models.ShareService) {
+	handler := &ShareHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.CreateShare)
+	router.Get("/", handler.ListShares)
+	router.Delete("/:id", handler.RevokeShare)
+}
+
+// NewPublicShareHandler serves the JSON of a share link under api and its
+// HTML page under page.
+func NewPublicShareHandler(api fiber.Router, page fiber.Router, service models.ShareService) {
+	handler := &ShareHandler{
+		service: service,
+	}
+
+	api.Get("/:token", handler.GetShare)
+	page.Get("/:token", handler.GetSharePage)
+}
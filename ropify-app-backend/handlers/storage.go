@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalStorageHandler serves a services.LocalStorage the way S3 would: public
+// reads and uploads only through presigned URLs.
+type LocalStorageHandler struct {
+	storage *services.LocalStorage
+}
+
+func (h *LocalStorageHandler) GetObject(ctx *fiber.Ctx) error {
+	key := ctx.Params("*")
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := h.storage.Get(context, key)
+	if err != nil {
+		return ctx.SendStatus(fiber.StatusNotFound)
+	}
+
+	ctx.Set(fiber.HeaderContentType, http.DetectContentType(data))
+	return ctx.Send(data)
+}
+
+func (h *LocalStorageHandler) PutObject(ctx *fiber.Ctx) error {
+	key := ctx.Params("*")
+	contentType := ctx.Get(fiber.HeaderContentType)
+
+	if err := h.storage.VerifyPut(key, contentType, ctx.Query("expires"), ctx.Query("signature")); err != nil {
+		return ctx.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := h.storage.Put(context, key, ctx.Body(), contentType); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func NewLocalStorageHandler(router fiber.Router, storage *services.LocalStorage) {
+	handler := &LocalStorageHandler{
+		storage: storage,
+	}
+
+	router.Get("/*", handler.GetObject)
+	router.Put("/*", handler.PutObject)
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var checklistContentTypes = map[string]string{
+	models.ChecklistText: fiber.MIMETextPlainCharsetUTF8,
+	models.ChecklistJSON: fiber.MIMEApplicationJSONCharsetUTF8,
+}
+
+type TripHandler struct {
+	service models.TripService
+}
+
+func tripError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Trip not found",
+		})
+	case errors.Is(err, models.ErrTripOutfitMissing):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Outfit not found",
+		})
+	case errors.Is(err, models.ErrTripOutfitPlanned):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+}
+
+// Crear un viaje con fechas, destino y actividades
+func (h *TripHandler) CreateTrip(ctx *fiber.Ctx) error {
+	var payload models.TripRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "name, destination, start_date and end_date (YYYY-MM-DD) are required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trip, err := h.service.CreateTrip(context, principal.UserID, &payload)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   trip,
+	})
+}
+
+// Listar los viajes del usuario
+func (h *TripHandler) ListTrips(ctx *fiber.Ctx) error {
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trips, err := h.service.ListTrips(context, principal.UserID)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   trips,
+	})
+}
+
+// Visualizar un viaje con los outfits de cada día
+func (h *TripHandler) GetTrip(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trip, err := h.service.GetTrip(context, principal.UserID, tripID)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   trip,
+	})
+}
+
+// Editar un viaje
+func (h *TripHandler) UpdateTrip(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	var payload models.TripRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "name, destination, start_date and end_date (YYYY-MM-DD) are required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trip, err := h.service.UpdateTrip(context, principal.UserID, tripID, &payload)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   trip,
+	})
+}
+
+// Eliminar un viaje con sus outfits y su checklist
+func (h *TripHandler) DeleteTrip(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.DeleteTrip(context, principal.UserID, tripID); err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Trip deleted",
+	})
+}
+
+// Planear un outfit para un día del viaje
+func (h *TripHandler) AddOutfit(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	var payload models.TripOutfitRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "date (YYYY-MM-DD) and outfit_id are required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tripOutfit, err := h.service.AddOutfit(context, principal.UserID, tripID, &payload)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   tripOutfit,
+	})
+}
+
+// Quitar un outfit planeado
+func (h *TripHandler) RemoveOutfit(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	tripOutfitID, err := uuid.Parse(ctx.Params("tripOutfitId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip outfit ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.RemoveOutfit(context, principal.UserID, tripID, tripOutfitID); err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Outfit removed from the trip",
+	})
+}
+
+// Lista de equipaje del viaje
+func (h *TripHandler) GetPackingList(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	list, err := h.service.PackingList(context, principal.UserID, tripID)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   list,
+	})
+}
+
+// Exportar la lista de equipaje como checklist en texto o JSON
+func (h *TripHandler) ExportChecklist(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	format := ctx.Query("format", models.ChecklistText)
+	contentType, ok := checklistContentTypes[format]
+	if !ok {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Format must be text or json",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	list, err := h.service.PackingList(context, principal.UserID, tripID)
+	if err != nil {
+		return tripError(ctx, err)
+	}
+
+	var buf bytes.Buffer
+	if err := h.service.WriteChecklist(list, format, &buf); err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	extension := "txt"
+	if format == models.ChecklistJSON {
+		extension = "json"
+	}
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="ropify-trip-%s.%s"`, list.Trip.StartDate.Format(models.TripDateLayout), extension))
+
+	return ctx.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// Marcar o desmarcar una prenda como empacada
+func (h *TripHandler) CheckItem(ctx *fiber.Ctx) error {
+	tripID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid trip ID",
+		})
+	}
+
+	garmentID, err := uuid.Parse(ctx.Params("garmentId"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid garment ID",
+		})
+	}
+
+	var payload struct {
+		Checked *bool `json:"checked" validate:"required"`
+	}
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "checked is required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = h.service.CheckItem(context, principal.UserID, tripID, garmentID, *payload.Checked)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Garment not in the packing list",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   fiber.Map{"garment_id": garmentID, "checked": *payload.Checked},
+	})
+}
+
+func NewTripHandler(router fiber.Router, service models.TripService) {
+	handler := &TripHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.CreateTrip)
+	router.Get("/", handler.ListTrips)
+	router.Get("/:id", handler.GetTrip)
+	router.Patch("/:id", handler.UpdateTrip)
+	router.Delete("/:id", handler.DeleteTrip)
+	router.Post("/:id/outfits", handler.AddOutfit)
+	router.Delete("/:id/outfits/:tripOutfitId", handler.RemoveOutfit)
+	router.Get("/:id/packing-list", handler.GetPackingList)
+	router.Get("/:id/packing-list/export", handler.ExportChecklist)
+	router.Put("/:id/packing-list/:garmentId", handler.CheckItem)
+}
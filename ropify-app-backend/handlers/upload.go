@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gaelzamora/ropify-app/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UploadHandler struct {
+	service models.UploadService
+}
+
+// Pedir una URL firmada para subir la foto de una prenda directamente al storage
+func (h *UploadHandler) CreateUpload(ctx *fiber.Ctx) error {
+	var payload struct {
+		GarmentID   uuid.UUID `json:"garment_id" validate:"required"`
+		ContentType string    `json:"content_type" validate:"required"`
+		Size        int64     `json:"size" validate:"required,gt=0"`
+	}
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "garment_id, content_type and size are required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	upload, err := h.service.CreateUpload(context, principal.UserID, payload.GarmentID, payload.ContentType, payload.Size)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Garment not found",
+		})
+	case errors.Is(err, services.ErrInvalidImage), errors.Is(err, models.ErrUploadTooLarge):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   upload,
+	})
+}
+
+// Confirmar la subida, verifica el archivo y lo asigna a la prenda
+func (h *UploadHandler) CompleteUpload(ctx *fiber.Ctx) error {
+	uploadID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid upload ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	images, err := h.service.CompleteUpload(context, principal.UserID, uploadID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Upload not found",
+		})
+	case errors.Is(err, models.ErrUploadDone):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrUploadExpired):
+		return ctx.Status(fiber.StatusGone).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrUploadMissing), errors.Is(err, models.ErrUploadTooLarge), errors.Is(err, services.ErrInvalidImage):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Garment image uploaded successfully",
+		"data":    images,
+	})
+}
+
+func NewUploadHandler(router fiber.Router, service models.UploadService) {
+	handler := &UploadHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.CreateUpload)
+	router.Post("/:id/complete", handler.CompleteUpload)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type UsernameHandler struct {
+	service models.UsernameService
+}
+
+// Consultar si un username está disponible
+func (h *UsernameHandler) CheckAvailability(ctx *fiber.Ctx) error {
+	username := ctx.Query("username", "")
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	available, err := h.service.IsAvailable(context, username, userId)
+	if err != nil {
+		return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data": fiber.Map{
+				"username":  username,
+				"available": false,
+				"reason":    err.Error(),
+			},
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"username":  username,
+			"available": available,
+		},
+	})
+}
+
+// Cambiar el username del usuario autenticado
+func (h *UsernameHandler) ChangeUsername(ctx *fiber.Ctx) error {
+	var payload struct {
+		Username string `json:"username" validate:"required"`
+	}
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "username is required",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+	userId := principal.UserID
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := h.service.ChangeUsername(context, userId, payload.Username)
+	switch {
+	case errors.Is(err, models.ErrUsernameTaken):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrUsernameCooldown):
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case err != nil:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user.Profile(),
+	})
+}
+
+// Resolver un username, incluidos los anteriores (redirected = true)
+func (h *UsernameHandler) ResolveUsername(ctx *fiber.Ctx) error {
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, redirected, err := h.service.Resolve(context, ctx.Params("username"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "User not found",
+		})
+	}
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"user_id":    user.ID,
+			"username":   user.Username,
+			"redirected": redirected,
+		},
+	})
+}
+
+func NewUsernameHandler(router fiber.Router, service models.UsernameService) {
+	handler := &UsernameHandler{
+		service: service,
+	}
+
+	router.Get("/available", handler.CheckAvailability)
+	router.Get("/:username", handler.ResolveUsername)
+	router.Put("/", handler.ChangeUsername)
+}
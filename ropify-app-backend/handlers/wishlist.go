@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/middlewares"
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gaelzamora/ropify-app/services"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WishlistHandler struct {
+	service models.WishlistService
+}
+
+func wishlistError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Wishlist item not found",
+		})
+	case errors.Is(err, models.ErrInvalidWishlistItem):
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrWishlistItemPurchased):
+		return ctx.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	default:
+		return ctx.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "fail",
+			"message": err.Error(),
+		})
+	}
+}
+
+// Agregar un artículo a la lista de deseos
+func (h *WishlistHandler) CreateItem(ctx *fiber.Ctx) error {
+	var payload models.WishlistItemRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "name and category are required, link and image_url must be URLs",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item, err := h.service.CreateItem(context, principal.UserID, &payload)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   item,
+	})
+}
+
+// Listar la lista de deseos, ?purchased=true|false filtra los comprados
+func (h *WishlistHandler) ListItems(ctx *fiber.Ctx) error {
+	var purchased *bool
+	switch ctx.Query("purchased") {
+	case "":
+	case "true", "false":
+		value := ctx.QueryBool("purchased")
+		purchased = &value
+	default:
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "purchased must be true or false",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	items, err := h.service.ListItems(context, principal.UserID, purchased)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   items,
+	})
+}
+
+// Visualizar un artículo
+func (h *WishlistHandler) GetItem(ctx *fiber.Ctx) error {
+	itemID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid wishlist item ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item, err := h.service.GetItem(context, principal.UserID, itemID)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   item,
+	})
+}
+
+// Editar un artículo
+func (h *WishlistHandler) UpdateItem(ctx *fiber.Ctx) error {
+	itemID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid wishlist item ID",
+		})
+	}
+
+	var payload models.WishlistItemRequest
+
+	if err := ctx.BodyParser(&payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := validate.Struct(payload); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "name and category are required, link and image_url must be URLs",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	item, err := h.service.UpdateItem(context, principal.UserID, itemID, &payload)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   item,
+	})
+}
+
+// Eliminar un artículo
+func (h *WishlistHandler) DeleteItem(ctx *fiber.Ctx) error {
+	itemID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid wishlist item ID",
+		})
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.service.DeleteItem(context, principal.UserID, itemID); err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Wishlist item deleted",
+	})
+}
+
+// Marcar un artículo como comprado, se agrega al closet como prenda
+func (h *WishlistHandler) PurchaseItem(ctx *fiber.Ctx) error {
+	itemID, err := uuid.Parse(ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Invalid wishlist item ID",
+		})
+	}
+
+	var payload models.PurchaseRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&payload); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	// Incluye la descarga de la foto del artículo
+	context, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+
+	garment, err := h.service.Purchase(context, principal.UserID, itemID, &payload)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data":   garment,
+	})
+}
+
+// Categorías con menos prendas que la distribución objetivo, que se puede
+// cambiar con ?target=top:30,bottom:25
+func (h *WishlistHandler) GapAnalysis(ctx *fiber.Ctx) error {
+	var target map[models.GarmentCategory]float64
+	if param := ctx.Query("target"); param != "" {
+		parsed, err := services.ParseTargetDistribution(strings.Split(param, ","))
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "fail",
+				"message": err.Error(),
+			})
+		}
+		target = parsed
+	}
+
+	principal, ok := middlewares.CurrentPrincipal(ctx)
+	if !ok {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	analysis, err := h.service.GapAnalysis(context, principal.UserID, target)
+	if err != nil {
+		return wishlistError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   analysis,
+	})
+}
+
+func NewWishlistHandler(router fiber.Router, service models.WishlistService) {
+	handler := &WishlistHandler{
+		service: service,
+	}
+
+	router.Post("/", handler.CreateItem)
+	router.Get("/", handler.ListItems)
+	router.Get("/gaps", handler.GapAnalysis)
+	router.Get("/:id", handler.GetItem)
+	router.Patch("/:id", handler.UpdateItem)
+	router.Delete("/:id", handler.DeleteItem)
+	router.Post("/:id/purchase", handler.PurchaseItem)
+}
@@ -1,29 +1,84 @@
 package middlewares
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gaelzamora/ropify-app/models"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/log"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-func AuthProtected(db *gorm.DB) fiber.Handler {
+// findPersonalAccessToken loads an active token and records when it was used
+// (at most once a minute to spare writes).
+func findPersonalAccessToken(db *gorm.DB, tokenStr string) (*models.PersonalAccessToken, error) {
+	var pat models.PersonalAccessToken
+	if err := db.Where("token_hash = ?", models.HashToken(tokenStr)).First(&pat).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !pat.IsActive(now) {
+		return nil, fmt.Errorf("token %s is revoked or expired", pat.Prefix)
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > time.Minute {
+		if err := db.Model(&pat).Update("last_used_at", now).Error; err != nil {
+			log.Warnf("failed to update last use of token %s: %v", pat.Prefix, err)
+		}
+	}
+
+	return &pat, nil
+}
+
+// parseSessionToken validates a login JWT and returns the user it belongs to.
+func parseSessionToken(tokenStr string) (uuid.UUID, error) {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.GetSigningMethod("HS256").Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil || !token.Valid {
+		return uuid.Nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	id, ok := claims["id"].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("token without user id")
+	}
+
+	return uuid.Parse(id)
+}
+
+// AuthProtected accepts session JWTs and personal access tokens, loads the
+// user and stores the caller as a models.Principal (see CurrentPrincipal).
+// The role always comes from the db, not from the token claims. Users are
+// loaded through the users cache.
+func AuthProtected(db *gorm.DB, users *UserCache) fiber.Handler {
+
+	unauthorized := func(ctx *fiber.Ctx) error {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Unauthorized",
+		})
+	}
+
 	return func(ctx *fiber.Ctx) error {
 		authHeader := ctx.Get("Authorization")
 
 		if authHeader == "" {
 			log.Warnf("empty authorization header")
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return unauthorized(ctx)
 		}
 
 		tokenParts := strings.Split(authHeader, " ")
@@ -31,43 +86,56 @@ func AuthProtected(db *gorm.DB) fiber.Handler {
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
 			log.Warnf("invalid token parts")
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+			return unauthorized(ctx)
 		}
 
 		tokenStr := tokenParts[1]
-		secret := []byte(os.Getenv("JWT_SECRET"))
+		principal := &models.Principal{}
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if token.Method.Alg() != jwt.GetSigningMethod("HS256").Alg() {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		// Tokens personales para scripts e integraciones
+		if strings.HasPrefix(tokenStr, models.PersonalAccessTokenPrefix) {
+			pat, err := findPersonalAccessToken(db, tokenStr)
+			if err != nil {
+				log.Warnf("invalid personal access token: %v", err)
+
+				return unauthorized(ctx)
 			}
-			return secret, nil
-		})
 
-		if err != nil || !token.Valid {
-			log.Warnf("invalid token")
+			principal.UserID = pat.UserID
+			principal.Scopes = append([]string{}, pat.Scopes...)
+		} else {
+			userID, err := parseSessionToken(tokenStr)
+			if err != nil {
+				log.Warnf("%v", err)
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
-				"status":  "fail",
-				"message": "Unauthorized",
-			})
+				return unauthorized(ctx)
+			}
+
+			principal.UserID = userID
 		}
 
-		userId := token.Claims.(jwt.MapClaims)["id"]
+		user, err := users.Get(principal.UserID)
+		if err != nil {
+			log.Warnf("user %s not found in the db: %v", principal.UserID, err)
 
-		if err := db.Model(&models.User{}).Where("id = ?", userId).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Warnf("user not found in the db")
+			return unauthorized(ctx)
+		}
+
+		if user.IsSuspended() {
+			log.Warnf("suspended user %s", user.ID)
 
-			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
 				"status":  "fail",
-				"message": "Unauthorized",
+				"message": models.ErrUserSuspended.Error(),
 			})
 		}
 
-		ctx.Locals("userId", userId)
+		principal.Role = user.Role
+		if principal.Role == "" {
+			principal.Role = models.Attendee
+		}
+
+		ctx.Locals(principalKey, principal)
 
 		return ctx.Next()
 	}
@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+const principalKey = "principal"
+
+// CurrentPrincipal returns the caller stored by AuthProtected, false on
+// routes that are not protected.
+func CurrentPrincipal(ctx *fiber.Ctx) (*models.Principal, bool) {
+	principal, ok := ctx.Locals(principalKey).(*models.Principal)
+	return principal, ok && principal != nil
+}
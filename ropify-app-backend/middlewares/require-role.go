@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// RequireRole only lets through users with one of the given roles. It must be
+// layered after AuthProtected, which stores the caller.
+func RequireRole(roles ...models.UserRole) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		var role models.UserRole
+		if principal, ok := CurrentPrincipal(ctx); ok {
+			role = principal.Role
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				return ctx.Next()
+			}
+		}
+
+		log.Warnf("role %q not allowed to access %s", role, ctx.Path())
+
+		return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Forbidden",
+		})
+	}
+}
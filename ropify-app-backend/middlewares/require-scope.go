@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// RequireScope limits what personal access tokens can do on a resource:
+// reads need "<resource>:read" (or :write), anything else "<resource>:write".
+// Session tokens are not restricted.
+func RequireScope(resource string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		principal, ok := CurrentPrincipal(ctx)
+		if !ok {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "Unauthorized",
+			})
+		}
+
+		write := resource + ":write"
+		if principal.HasScope(write) {
+			return ctx.Next()
+		}
+
+		read := ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead
+		if read && principal.HasScope(resource+":read") {
+			return ctx.Next()
+		}
+
+		log.Warnf("token without scope on %s for %s %s", resource, ctx.Method(), ctx.Path())
+
+		return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+			"status":  "fail",
+			"message": "Insufficient token scope",
+		})
+	}
+}
+
+// SessionOnly rejects personal access tokens, for account management routes
+// that scripts must never reach.
+func SessionOnly() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if principal, ok := CurrentPrincipal(ctx); !ok || !principal.IsSession() {
+			return ctx.Status(fiber.StatusForbidden).JSON(&fiber.Map{
+				"status":  "fail",
+				"message": "This endpoint requires a session token",
+			})
+		}
+
+		return ctx.Next()
+	}
+}
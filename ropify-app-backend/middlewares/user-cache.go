@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userCacheTTL bounds how long a deletion can take to reach requests already
+// authenticated. Suspensions and role changes evict the user right away.
+const (
+	userCacheTTL     = 30 * time.Second
+	userCacheMaxSize = 10_000
+)
+
+type cachedUser struct {
+	user      *models.User
+	expiresAt time.Time
+}
+
+// UserCache keeps recently loaded users so AuthProtected does not query the
+// db on every request.
+type UserCache struct {
+	db      *gorm.DB
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[uuid.UUID]cachedUser
+}
+
+// Get returns the user from the cache or the db. Missing users are never
+// cached, but a user deleted while cached keeps passing until the entry
+// expires, up to the TTL. Changes made through the admin API call Invalidate.
+func (c *UserCache) Get(userID uuid.UUID) (*models.User, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.user, nil
+	}
+
+	var user models.User
+	if err := c.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= userCacheMaxSize {
+		c.prune(now)
+	}
+	c.entries[userID] = cachedUser{user: &user, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return &user, nil
+}
+
+// Invalidate drops the user, the next request loads it again from the db.
+func (c *UserCache) Invalidate(userID uuid.UUID) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// prune drops expired entries, or everything if the cache is still full.
+// Must be called with the lock held.
+func (c *UserCache) prune(now time.Time) {
+	for id, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	if len(c.entries) >= userCacheMaxSize {
+		c.entries = map[uuid.UUID]cachedUser{}
+	}
+}
+
+func NewUserCache(db *gorm.DB) *UserCache {
+	return newUserCache(db, userCacheTTL)
+}
+
+func newUserCache(db *gorm.DB, ttl time.Duration) *UserCache {
+	return &UserCache{
+		db:      db,
+		ttl:     ttl,
+		entries: map[uuid.UUID]cachedUser{},
+	}
+}
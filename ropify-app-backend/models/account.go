@@ -0,0 +1,83 @@
+package models
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AccountDeletionStatus string
+
+const (
+	DeletionPending   AccountDeletionStatus = "pending"
+	DeletionCompleted AccountDeletionStatus = "completed"
+	DeletionCancelled AccountDeletionStatus = "cancelled"
+)
+
+// Pasos del borrado, se guardan para poder reanudar un job interrumpido
+const (
+	DeletionStepRecords = "records"
+	DeletionStepStorage = "storage"
+)
+
+// AccountDeletion is a scheduled account deletion. It runs once the grace
+// period is over and records the step it reached so it can be resumed.
+type AccountDeletion struct {
+	ID           uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID       uuid.UUID             `json:"user_id" gorm:"type:uuid;not null;index"`
+	Status       AccountDeletionStatus `json:"status" gorm:"not null;index"`
+	Step         string                `json:"step" gorm:"not null"`
+	ScheduledFor time.Time             `json:"scheduled_for" gorm:"not null;index"`
+	LockedUntil  *time.Time            `json:"-"`
+	Attempts     int                   `json:"attempts"`
+	LastError    string                `json:"last_error,omitempty"`
+	CompletedAt  *time.Time            `json:"completed_at"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+// AccountExport holds every record of a user for a data export.
+type AccountExport struct {
+	User       *User
+	Identities []*UserIdentity
+	// Previous usernames still redirecting to the user
+	UsernameRedirects []*UsernameRedirect
+	Garments          []*Garment
+	Outfits           []*Outfit
+	Followers         []uuid.UUID
+	Following         []uuid.UUID
+	// Only the metadata, the hash of the tokens is not exported
+	AccessTokens        []*PersonalAccessToken
+	ShareLinks          []*ShareLink
+	OutfitRevisions     []*OutfitRevision
+	Trips               []*Trip
+	TripOutfits         []*TripOutfit
+	PackingChecks       []*TripPackingCheck
+	WishlistItems       []*WishlistItem
+	GarmentStateChanges []*GarmentStateChange
+}
+
+type AccountRepository interface {
+	ScheduleDeletion(ctx context.Context, userID uuid.UUID, scheduledFor time.Time) (*AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	GetPendingDeletion(ctx context.Context, userID uuid.UUID) (*AccountDeletion, error)
+	ClaimDueDeletion(ctx context.Context, now time.Time, lease time.Duration) (*AccountDeletion, error)
+	DeleteUserRecords(ctx context.Context, deletion *AccountDeletion) error
+	UpdateDeletion(ctx context.Context, deletion *AccountDeletion) error
+	ExportData(ctx context.Context, userID uuid.UUID) (*AccountExport, error)
+}
+
+type AccountService interface {
+	RequestDeletion(ctx context.Context, userID uuid.UUID, password string) (*AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID uuid.UUID) error
+	ProcessDueDeletions(ctx context.Context) (int, error)
+	PrepareExport(ctx context.Context, userID uuid.UUID) (*AccountExport, error)
+	WriteExport(ctx context.Context, export *AccountExport, w io.Writer) error
+}
+
+func (d *AccountDeletion) BeforeCreate(tx *gorm.DB) (err error) {
+	d.ID = uuid.New()
+	return
+}
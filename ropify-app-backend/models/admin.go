@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrCannotChangeSelf = errors.New("managers cannot suspend or change the role of their own account")
+
+// AdminUser is the view of a user for the support team.
+type AdminUser struct {
+	Profile
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	SuspendedReason string     `json:"suspended_reason"`
+}
+
+type AdminUserFilter struct {
+	Search    string
+	Role      UserRole
+	Suspended *bool
+}
+
+type AdminRepository interface {
+	ListUsers(ctx context.Context, filter *AdminUserFilter, limit, offset int) ([]*User, int64, error)
+	UpdateUserFields(ctx context.Context, userID uuid.UUID, updateData map[string]interface{}) (*User, error)
+}
+
+// UserInvalidator drops cached copies of a user after an admin changes it.
+type UserInvalidator interface {
+	Invalidate(userID uuid.UUID)
+}
+
+type AdminService interface {
+	ListUsers(ctx context.Context, filter *AdminUserFilter, limit, offset int) ([]*AdminUser, int64, error)
+	SuspendUser(ctx context.Context, actorID, userID uuid.UUID, reason string) (*AdminUser, error)
+	ReinstateUser(ctx context.Context, userID uuid.UUID) (*AdminUser, error)
+	ChangeRole(ctx context.Context, actorID, userID uuid.UUID, role UserRole) (*AdminUser, error)
+}
+
+func (u *User) AdminView() *AdminUser {
+	return &AdminUser{
+		Profile:         *u.Profile(),
+		SuspendedAt:     u.SuspendedAt,
+		SuspendedReason: u.SuspendedReason,
+	}
+}
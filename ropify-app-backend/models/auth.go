@@ -2,11 +2,14 @@ package models
 
 import (
 	"context"
+	"errors"
 	"net/mail"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrUserSuspended = errors.New("this account has been suspended")
+
 type AuthCredentials struct {
 	FirstName string `json:"firstName"`
 	LastName  string `json:"lastName"`
@@ -18,7 +21,7 @@ type AuthCredentials struct {
 type AuthRepository interface {
 	RegisterUser(ctx context.Context, registerData *AuthCredentials) (*User, error)
 	GetUser(ctx context.Context, query interface{}, args ...interface{}) (*User, error)
-	RegisterOAuthUser(ctx context.Context, user *User) (*User, error)
+	RegisterOAuthUser(ctx context.Context, user *User, identity *UserIdentity) (*User, error)
 	UpdateUser(ctx context.Context, user *User) error
 }
 
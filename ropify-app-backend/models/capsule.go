@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Límites de una cápsula
+const (
+	MinCapsuleSize = 3
+	MaxCapsuleSize = 30
+	// Combinations returned by the endpoint, the count is always exact
+	MaxCapsuleCombinations = 500
+	// Outfits created at most when saving a capsule
+	MaxCapsuleOutfits = 50
+)
+
+var ErrInvalidCapsule = errors.New("can't build a capsule")
+
+// CapsuleTag is added to the outfits saved from a capsule.
+const CapsuleTag = "capsule"
+
+type CapsuleRequest struct {
+	Size int `json:"size" validate:"required,min=3,max=30"`
+	// Same seed, same closet, same capsule
+	Seed int64 `json:"seed"`
+	// Save the first MaxOutfits combinations as outfits
+	Save       bool `json:"save"`
+	MaxOutfits int  `json:"max_outfits" validate:"omitempty,min=1,max=50"`
+}
+
+// CapsuleCombination is an outfit that can be made with the capsule: a top,
+// a bottom and shoes, or a dress and shoes. Shoes are left out when the
+// closet has none.
+type CapsuleCombination struct {
+	GarmentIDs []uuid.UUID `json:"garment_ids"`
+}
+
+type Capsule struct {
+	Seed     int64      `json:"seed"`
+	Garments []*Garment `json:"garments"`
+	// Total number of valid combinations of the capsule
+	CombinationCount int                   `json:"combination_count"`
+	Combinations     []*CapsuleCombination `json:"combinations"`
+	// Garments considered, after leaving out categories that are not part of
+	// combinations and trimming large closets
+	Candidates int `json:"candidates"`
+	// Filled when the capsule was saved
+	Outfits []*Outfit `json:"outfits,omitempty"`
+}
+
+type CapsuleService interface {
+	// GenerateCapsule picks request.Size garments of the closet of the user
+	// with as many combinations as it can find, and saves them as outfits if
+	// requested.
+	GenerateCapsule(ctx context.Context, userID uuid.UUID, request *CapsuleRequest) (*Capsule, error)
+}
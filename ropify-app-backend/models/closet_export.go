@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+// Formatos de exportación del closet
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+	ExportPDF  = "pdf"
+)
+
+// GarmentCategories lists the categories in the order they are shown.
+var GarmentCategories = []GarmentCategory{Top, Bottoms, Dress, Sneakers, Accesories, Backpack, Unknown}
+
+// ClosetExport holds the garments, and optionally the outfits, of a user.
+type ClosetExport struct {
+	UserID   uuid.UUID
+	Garments []*Garment
+	// Nil when the outfits were not requested
+	Outfits []*Outfit
+}
+
+type ClosetExportService interface {
+	PrepareExport(ctx context.Context, userID uuid.UUID, includeOutfits bool) (*ClosetExport, error)
+	// WriteExport writes the export in the given format, it can take a while
+	// for PDFs since the garment photos are embedded.
+	WriteExport(ctx context.Context, export *ClosetExport, format string, w io.Writer) error
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// GarmentDuplicate is a garment whose photo looks like the one of another.
+type GarmentDuplicate struct {
+	Garment  *Garment `json:"garment"`
+	Distance int      `json:"distance"`
+}
+
+// DuplicateCluster groups garments whose photos look alike, for cleanup.
+type DuplicateCluster struct {
+	Garments    []*Garment `json:"garments"`
+	MaxDistance int        `json:"max_distance"`
+}
+
+type DuplicateRepository interface {
+	ListHashedGarments(ctx context.Context, userID uuid.UUID) ([]*Garment, error)
+	// ListGarmentsWithoutHash pages by id the garments with a photo but no hash.
+	ListGarmentsWithoutHash(ctx context.Context, afterID uuid.UUID, limit int) ([]*Garment, error)
+	SetImageHash(ctx context.Context, garmentID uuid.UUID, hash int64) error
+}
+
+type DuplicateService interface {
+	// FindDuplicates returns the other garments of the owner whose photo is
+	// within the distance threshold of the garment one.
+	FindDuplicates(ctx context.Context, garment *Garment) ([]*GarmentDuplicate, error)
+	ListClusters(ctx context.Context, userID uuid.UUID, maxDistance int) ([]*DuplicateCluster, error)
+	// BackfillHashes hashes the photos stored before hashes existed.
+	BackfillHashes(ctx context.Context) (int, error)
+}
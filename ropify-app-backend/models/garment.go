@@ -46,18 +46,55 @@ func (sa StringArray) Value() (driver.Value, error) {
 
 type Garment struct {
 	ID         uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	UserID     uuid.UUID       `json:"user_id" gorm:"type:uuid;not null"`
+	UserID     uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index"`
 	Category   GarmentCategory `json:"category" gorm:"not null"`
 	Color      string          `json:"color" gorm:"not null"`
 	Labels     StringArray     `json:"labels" gorm:"type:jsonb"`
 	ImageURL   string          `json:"image_url"`
 	IsVerified bool            `json:"is_verified"`
 	CreatedAt  time.Time       `json:"created_at"`
+
+	// Renditions of ImageURL for grids and detail views
+	ThumbnailURL string `json:"thumbnail_url"`
+	MediumURL    string `json:"medium_url"`
+	// Perceptual hash (dHash) of the photo, to find duplicates. Not indexed,
+	// a btree can't search by Hamming distance
+	ImageHash *int64 `json:"-"`
+
+	Brand string `json:"brand"`
+	// ID of the item in the app or spreadsheet it was imported from, unique
+	// per user so imports can be re-run
+	ExternalID *string `json:"external_id"`
+
+	// Laundry state, changed only through the transitions of GarmentState
+	State          GarmentState `json:"state" gorm:"not null;default:clean;index"`
+	StateNote      string       `json:"state_note,omitempty"`
+	StateChangedAt *time.Time   `json:"state_changed_at"`
+}
+
+// GarmentImages are the URLs of the renditions of a garment photo.
+type GarmentImages struct {
+	ThumbnailURL string `json:"thumbnail_url"`
+	MediumURL    string `json:"medium_url"`
+	ImageURL     string `json:"image_url"`
+	ImageHash    *int64 `json:"-"`
+}
+
+// SetImages stores the renditions on the garment.
+func (g *Garment) SetImages(images *GarmentImages) {
+	g.ThumbnailURL = images.ThumbnailURL
+	g.MediumURL = images.MediumURL
+	g.ImageURL = images.ImageURL
+	g.ImageHash = images.ImageHash
 }
 
 type GarmentRepository interface {
 	AddGarment(ctx context.Context, garment *Garment) (*Garment, error)
-	FindByBarcode(ctx context.Context, barcode string) (*Garment, error)
+	FindByBarcode(ctx context.Context, userID uuid.UUID, barcode string) (*Garment, error)
+	GetGarmentByID(ctx context.Context, userID, garmentID uuid.UUID) (*Garment, error)
+	FindByExternalIDs(ctx context.Context, userID uuid.UUID, externalIDs []string) ([]*Garment, error)
+	GetAllGarmentsByUser(ctx context.Context, userID uuid.UUID) ([]*Garment, error)
+	GetGarmentsByIDs(ctx context.Context, userID uuid.UUID, garmentIDs []string) ([]*Garment, error)
 
 	UpdateGarment(ctx context.Context, garmentID uuid.UUID, updatedData map[string]interface{}) (*Garment, error)
 
@@ -65,7 +102,13 @@ type GarmentRepository interface {
 
 	FilterGarments(ctx context.Context, userID uuid.UUID, filters map[string]interface{}, sortBy string, limit, offset int) ([]*Garment, error)
 
-	UpdateGarmentImage(userId uuid.UUID, imageURL string, garmentId uuid.UUID) error
+	UpdateGarmentImage(userId uuid.UUID, images *GarmentImages, garmentId uuid.UUID) error
+}
+
+type GarmentImageService interface {
+	// StoreGarmentImage validates and normalizes the photo and uploads its
+	// renditions.
+	StoreGarmentImage(ctx context.Context, userID uuid.UUID, data []byte) (*GarmentImages, error)
 }
 
 func (g *Garment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidImport = errors.New("invalid import file")
+
+// Lo que se hará (o se hizo) con cada fila
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportError  = "error"
+)
+
+// GarmentImportRow is an item of the CSV or JSON file.
+type GarmentImportRow struct {
+	ExternalID string   `json:"external_id"`
+	Category   string   `json:"category"`
+	Color      string   `json:"color"`
+	Labels     []string `json:"labels"`
+	Brand      string   `json:"brand"`
+	ImageURL   string   `json:"image_url"`
+}
+
+type GarmentImportResult struct {
+	// Row number, 1 is the first item (the CSV header is not counted)
+	Row        int             `json:"row"`
+	ExternalID string          `json:"external_id,omitempty"`
+	Action     string          `json:"action"`
+	Category   GarmentCategory `json:"category,omitempty"`
+	GarmentID  *uuid.UUID      `json:"garment_id,omitempty"`
+	Errors     []string        `json:"errors,omitempty"`
+	Warnings   []string        `json:"warnings,omitempty"`
+}
+
+type GarmentImportReport struct {
+	DryRun  bool                   `json:"dry_run"`
+	Total   int                    `json:"total"`
+	Created int                    `json:"created"`
+	Updated int                    `json:"updated"`
+	Failed  int                    `json:"failed"`
+	Rows    []*GarmentImportResult `json:"rows"`
+}
+
+type GarmentImportService interface {
+	// Import reads a "csv" or "json" file. In dry run mode the rows are only
+	// validated and the report says what would happen.
+	Import(ctx context.Context, userID uuid.UUID, format string, file io.Reader, dryRun bool) (*GarmentImportReport, error)
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type GarmentState string
+
+const (
+	StateClean     GarmentState = "clean"
+	StateWorn      GarmentState = "worn"
+	StateInLaundry GarmentState = "in_laundry"
+	StateAtTailor  GarmentState = "at_tailor"
+	StateLentOut   GarmentState = "lent_out"
+	StateStored    GarmentState = "stored"
+)
+
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// garmentTransitions lists the states a garment can go to from each state.
+var garmentTransitions = map[GarmentState][]GarmentState{
+	StateClean:     {StateWorn, StateInLaundry, StateAtTailor, StateLentOut, StateStored},
+	StateWorn:      {StateClean, StateInLaundry, StateAtTailor},
+	StateInLaundry: {StateClean, StateAtTailor},
+	StateAtTailor:  {StateClean, StateInLaundry},
+	StateLentOut:   {StateClean, StateWorn, StateInLaundry},
+	StateStored:    {StateClean, StateInLaundry},
+}
+
+// AvailableStates are the states of garments in the closet, ready to be part
+// of an outfit.
+var AvailableStates = []GarmentState{StateClean, StateWorn}
+
+// UnavailableStates are the states of garments that can't be worn now.
+var UnavailableStates = []GarmentState{StateInLaundry, StateAtTailor, StateLentOut, StateStored}
+
+// Valid reports whether s is one of the garment states.
+func (s GarmentState) Valid() bool {
+	_, ok := garmentTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a garment can go from s to next.
+func (s GarmentState) CanTransition(next GarmentState) bool {
+	for _, state := range garmentTransitions[s] {
+		if state == next {
+			return true
+		}
+	}
+	return false
+}
+
+// GarmentStateChange is an entry of the state history of a garment.
+type GarmentStateChange struct {
+	ID        uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	GarmentID uuid.UUID    `json:"garment_id" gorm:"type:uuid;not null;index"`
+	UserID    uuid.UUID    `json:"user_id" gorm:"type:uuid;not null;index"`
+	From      GarmentState `json:"from" gorm:"column:from_state;not null"`
+	To        GarmentState `json:"to" gorm:"column:to_state;not null"`
+	Note      string       `json:"note"`
+	ChangedAt time.Time    `json:"changed_at" gorm:"not null"`
+}
+
+func (c *GarmentStateChange) BeforeCreate(tx *gorm.DB) (err error) {
+	c.ID = uuid.New()
+	return
+}
+
+type GarmentStateRequest struct {
+	State GarmentState `json:"state" validate:"required"`
+	// Who the garment was lent to, what the tailor is doing...
+	Note string `json:"note" validate:"max=200"`
+}
+
+type BulkGarmentStateRequest struct {
+	GarmentIDs []uuid.UUID  `json:"garment_ids" validate:"required,min=1,max=200"`
+	State      GarmentState `json:"state" validate:"required"`
+	Note       string       `json:"note" validate:"max=200"`
+}
+
+// RejectedStateChange is a garment of a bulk change left as it was.
+type RejectedStateChange struct {
+	GarmentID uuid.UUID    `json:"garment_id"`
+	State     GarmentState `json:"state,omitempty"`
+	Reason    string       `json:"reason"`
+}
+
+type GarmentStateResult struct {
+	Changed  []*GarmentStateChange  `json:"changed"`
+	Rejected []*RejectedStateChange `json:"rejected"`
+}
+
+// PlanStateChanges decides which of garmentIDs move to state. garments are
+// the ones of the user found among garmentIDs, the rest are rejected as not
+// found. Repeated IDs count once.
+func PlanStateChanges(userID uuid.UUID, garments []*Garment, garmentIDs []uuid.UUID, state GarmentState, note string, now time.Time) *GarmentStateResult {
+	result := &GarmentStateResult{
+		Changed:  []*GarmentStateChange{},
+		Rejected: []*RejectedStateChange{},
+	}
+
+	byID := make(map[uuid.UUID]*Garment, len(garments))
+	for _, garment := range garments {
+		byID[garment.ID] = garment
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, garmentID := range garmentIDs {
+		if seen[garmentID] {
+			continue
+		}
+		seen[garmentID] = true
+
+		garment, ok := byID[garmentID]
+		if !ok {
+			result.Rejected = append(result.Rejected, &RejectedStateChange{GarmentID: garmentID, Reason: "garment not found"})
+			continue
+		}
+		if !garment.State.CanTransition(state) {
+			result.Rejected = append(result.Rejected, &RejectedStateChange{
+				GarmentID: garmentID,
+				State:     garment.State,
+				Reason:    fmt.Sprintf("can't go from %s to %s", garment.State, state),
+			})
+			continue
+		}
+
+		result.Changed = append(result.Changed, &GarmentStateChange{
+			GarmentID: garmentID,
+			UserID:    userID,
+			From:      garment.State,
+			To:        state,
+			Note:      note,
+			ChangedAt: now,
+		})
+	}
+
+	return result
+}
+
+type GarmentStateRepository interface {
+	// ChangeStates moves the garments of the user to state, garments that
+	// can't go there are rejected and left as they were.
+	ChangeStates(ctx context.Context, userID uuid.UUID, garmentIDs []uuid.UUID, state GarmentState, note string) (*GarmentStateResult, error)
+	// ResetStates moves every garment of the user in one of from to state,
+	// like the laundry day, and returns how many moved.
+	ResetStates(ctx context.Context, userID uuid.UUID, from []GarmentState, state GarmentState) (int64, error)
+	ListStateChanges(ctx context.Context, userID, garmentID uuid.UUID) ([]*GarmentStateChange, error)
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestGarmentStateValid(t *testing.T) {
+	tests := []struct {
+		state GarmentState
+		valid bool
+	}{
+		{StateClean, true},
+		{StateWorn, true},
+		{StateInLaundry, true},
+		{StateAtTailor, true},
+		{StateLentOut, true},
+		{StateStored, true},
+		{"", false},
+		{"dirty", false},
+		{"Clean", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.state.Valid(); got != tt.valid {
+			t.Errorf("GarmentState(%q).Valid() = %v, want %v", tt.state, got, tt.valid)
+		}
+	}
+}
+
+func TestGarmentStateCanTransition(t *testing.T) {
+	tests := []struct {
+		from GarmentState
+		to   GarmentState
+		can  bool
+	}{
+		{StateClean, StateWorn, true},
+		{StateClean, StateLentOut, true},
+		{StateClean, StateStored, true},
+		{StateWorn, StateInLaundry, true},
+		{StateWorn, StateClean, true},
+		{StateInLaundry, StateClean, true},
+		{StateAtTailor, StateClean, true},
+		{StateLentOut, StateWorn, true},
+		{StateStored, StateClean, true},
+		{StateClean, StateClean, false},
+		{StateInLaundry, StateWorn, false},
+		{StateInLaundry, StateLentOut, false},
+		{StateWorn, StateLentOut, false},
+		{StateWorn, StateStored, false},
+		{StateLentOut, StateStored, false},
+		{StateStored, StateWorn, false},
+		{StateClean, "dirty", false},
+		{"dirty", StateClean, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.can {
+			t.Errorf("%q.CanTransition(%q) = %v, want %v", tt.from, tt.to, got, tt.can)
+		}
+	}
+}
+
+func TestGarmentStatesAreReachable(t *testing.T) {
+	for state := range garmentTransitions {
+		if state != StateClean && !StateClean.CanTransition(state) && !StateWorn.CanTransition(state) {
+			t.Errorf("no garment in the closet can go to %q", state)
+		}
+		if state != StateClean && !state.CanTransition(StateClean) {
+			t.Errorf("a garment in %q can never be clean again", state)
+		}
+	}
+}
+
+func TestPlanStateChanges(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+
+	clean := &Garment{ID: uuid.New(), State: StateClean}
+	worn := &Garment{ID: uuid.New(), State: StateWorn}
+	inLaundry := &Garment{ID: uuid.New(), State: StateInLaundry}
+	lentOut := &Garment{ID: uuid.New(), State: StateLentOut}
+	missing := uuid.New()
+
+	garments := []*Garment{clean, worn, inLaundry, lentOut}
+
+	tests := []struct {
+		name         string
+		garmentIDs   []uuid.UUID
+		state        GarmentState
+		wantChanged  []uuid.UUID
+		wantRejected map[uuid.UUID]GarmentState
+	}{
+		{
+			name:        "every garment can move",
+			garmentIDs:  []uuid.UUID{clean.ID, worn.ID, lentOut.ID},
+			state:       StateInLaundry,
+			wantChanged: []uuid.UUID{clean.ID, worn.ID, lentOut.ID},
+		},
+		{
+			name:         "invalid transitions are rejected",
+			garmentIDs:   []uuid.UUID{clean.ID, inLaundry.ID, lentOut.ID},
+			state:        StateLentOut,
+			wantChanged:  []uuid.UUID{clean.ID},
+			wantRejected: map[uuid.UUID]GarmentState{inLaundry.ID: StateInLaundry, lentOut.ID: StateLentOut},
+		},
+		{
+			name:         "garments of other users are not found",
+			garmentIDs:   []uuid.UUID{missing, worn.ID},
+			state:        StateClean,
+			wantChanged:  []uuid.UUID{worn.ID},
+			wantRejected: map[uuid.UUID]GarmentState{missing: ""},
+		},
+		{
+			name:        "repeated IDs count once",
+			garmentIDs:  []uuid.UUID{worn.ID, worn.ID, worn.ID},
+			state:       StateInLaundry,
+			wantChanged: []uuid.UUID{worn.ID},
+		},
+		{
+			name:         "unknown state",
+			garmentIDs:   []uuid.UUID{clean.ID},
+			state:        "dirty",
+			wantRejected: map[uuid.UUID]GarmentState{clean.ID: StateClean},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := PlanStateChanges(userID, garments, tt.garmentIDs, tt.state, "note", now)
+
+			if len(result.Changed) != len(tt.wantChanged) {
+				t.Fatalf("changed %d garments, want %d", len(result.Changed), len(tt.wantChanged))
+			}
+			for i, change := range result.Changed {
+				if change.GarmentID != tt.wantChanged[i] || change.To != tt.state || change.UserID != userID ||
+					change.Note != "note" || !change.ChangedAt.Equal(now) {
+					t.Errorf("change %d = %+v, want %s to %s", i, change, tt.wantChanged[i], tt.state)
+				}
+			}
+
+			if len(result.Rejected) != len(tt.wantRejected) {
+				t.Fatalf("rejected %d garments, want %d", len(result.Rejected), len(tt.wantRejected))
+			}
+			for _, rejected := range result.Rejected {
+				state, ok := tt.wantRejected[rejected.GarmentID]
+				if !ok || rejected.State != state || rejected.Reason == "" {
+					t.Errorf("rejected %+v, want state %q", rejected, state)
+				}
+			}
+		})
+	}
+}
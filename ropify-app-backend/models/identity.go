@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrLastLoginMethod = errors.New("cannot unlink the last login method, set a password first")
+
+// UserIdentity links a social login (provider + subject) to a user.
+type UserIdentity struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Provider string    `json:"provider" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject  string    `json:"subject" gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+type IdentityRepository interface {
+	FindIdentity(ctx context.Context, provider, subject string) (*UserIdentity, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]*UserIdentity, error)
+	AddIdentity(ctx context.Context, identity *UserIdentity) (*UserIdentity, error)
+	DeleteIdentity(ctx context.Context, userID, identityID uuid.UUID) error
+}
+
+type IdentityService interface {
+	List(ctx context.Context, userID uuid.UUID) ([]*UserIdentity, error)
+	Link(ctx context.Context, userID uuid.UUID, linkToken, password string) (*UserIdentity, error)
+	Unlink(ctx context.Context, userID, identityID uuid.UUID) error
+}
+
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) (err error) {
+	i.ID = uuid.New()
+	if i.LinkedAt.IsZero() {
+		i.LinkedAt = time.Now()
+	}
+	return
+}
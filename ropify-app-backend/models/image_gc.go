@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// ImageGCReport is the result of a garbage collection run.
+type ImageGCReport struct {
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Objects listed in the storage
+	Scanned int `json:"scanned"`
+	// Objects still used by a garment, avatar, outfit or pending upload
+	Referenced int `json:"referenced"`
+	// Unreferenced objects newer than the grace window, kept for now
+	Recent       int      `json:"recent"`
+	Orphaned     int      `json:"orphaned"`
+	Bytes        int64    `json:"bytes"`
+	Deleted      int      `json:"deleted"`
+	BytesDeleted int64    `json:"bytes_deleted"`
+	Keys         []string `json:"keys,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// ImageGCMetrics are the totals since the API started.
+type ImageGCMetrics struct {
+	Runs         int64          `json:"runs"`
+	Failures     int64          `json:"failures"`
+	Deleted      int64          `json:"deleted"`
+	BytesFreed   int64          `json:"bytes_freed"`
+	LastRunAt    *time.Time     `json:"last_run_at"`
+	LastDuration time.Duration  `json:"last_duration_ns"`
+	LastReport   *ImageGCReport `json:"last_report"`
+}
+
+type ImageReferenceRepository interface {
+	// ReferencedImageURLs returns every image URL stored in the db.
+	ReferencedImageURLs(ctx context.Context) ([]string, error)
+	// PendingUploadKeys returns the storage keys of uploads not completed yet.
+	PendingUploadKeys(ctx context.Context) ([]string, error)
+}
+
+type ImageGCService interface {
+	Run(ctx context.Context, dryRun bool) (*ImageGCReport, error)
+	Metrics() ImageGCMetrics
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +10,10 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrForeignGarment is returned when an outfit uses garments that are not in
+// the closet of its owner.
+var ErrForeignGarment = errors.New("the outfit can only have garments of your closet")
+
 type Outfit struct {
 	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	UserID     uuid.UUID      `json:"user_id" gorm:"type:uuid;not null"`
@@ -20,15 +25,130 @@ type Outfit struct {
 	Archived   bool           `json:"archived" gorm:"default:false"`
 	ImageURL   string         `json:"image_url"`
 	CreatedAt  time.Time      `json:"created_at"`
+
+	// Outfit this one was cloned from
+	ClonedFromID *uuid.UUID `json:"cloned_from_id" gorm:"type:uuid"`
+
+	ArchivedAt     *time.Time `json:"archived_at"`
+	ArchivedReason string     `json:"archived_reason,omitempty"`
+	// The auto-archive policy leaves alone outfits the user unarchived
+	// during the last period
+	UnarchivedAt *time.Time `json:"unarchived_at"`
+}
+
+// Filtro de archivados en los listados
+const (
+	ArchivedExclude = "exclude"
+	ArchivedOnly    = "only"
+	ArchivedInclude = "include"
+)
+
+// Por qué se archivó un outfit
+const (
+	ArchiveManual          = "manual"
+	ArchiveMissingGarments = "missing_garments"
+	ArchiveStale           = "stale"
+)
+
+// Ocasiones y temporadas válidas de un outfit
+var (
+	OutfitOccasions = []string{"casual", "work", "formal", "party", "sport", "date", "travel", "home"}
+	OutfitSeasons   = []string{"spring", "summer", "autumn", "winter", "all"}
+)
+
+// Orden de los listados de outfits
+const (
+	OutfitSortNewest       = "newest"
+	OutfitSortOldest       = "oldest"
+	OutfitSortName         = "name"
+	OutfitSortGarmentCount = "garment_count"
+)
+
+// ValidOccasion reports whether occasion is one of OutfitOccasions.
+func ValidOccasion(occasion string) bool {
+	return containsString(OutfitOccasions, occasion)
+}
+
+// ValidSeason reports whether season is one of OutfitSeasons.
+func ValidSeason(season string) bool {
+	return containsString(OutfitSeasons, season)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// OutfitFilters narrow down the outfits of a listing. Empty fields don't
+// filter.
+type OutfitFilters struct {
+	// ArchivedExclude (the default), ArchivedOnly or ArchivedInclude
+	Archived string
+	// Outfits with at least one of AnyTags and with all of AllTags
+	AnyTags  []string
+	AllTags  []string
+	Occasion string
+	Season   string
+	// Outfits using this garment
+	GarmentID *uuid.UUID
+	// OutfitSortNewest (the default), OutfitSortOldest, OutfitSortName or
+	// OutfitSortGarmentCount
+	Sort string
+}
+
+// AutoArchiveReport counts the outfits archived by each rule of the policy.
+type AutoArchiveReport struct {
+	MissingGarments int64 `json:"missing_garments"`
+	Stale           int64 `json:"stale"`
 }
 
 type OutfitRepository interface {
+	// AddOutfit and UpdateOutfit append a revision to the history of the
+	// outfit, editorID is who made the change. UpdateOutfit and
+	// RestoreRevision only find outfits of editorID.
 	AddOutfit(ctx context.Context, outfit *Outfit) (*Outfit, error)
-	UpdateOutfit(ctx context.Context, outfitID uuid.UUID, updateData map[string]interface{}) (*Outfit, error)
+	UpdateOutfit(ctx context.Context, outfitID, editorID uuid.UUID, updateData map[string]interface{}) (*Outfit, error)
 	DeleteOutfit(ctx context.Context, outfitIOD uuid.UUID) error
-	ArchiveOutfit(ctx context.Context, outfitID uuid.UUID) error
+	ArchiveOutfit(ctx context.Context, userID, outfitID uuid.UUID) error
+	// ArchiveOutfits archives the outfits of the user among outfitIDs and
+	// returns how many were archived.
+	ArchiveOutfits(ctx context.Context, userID uuid.UUID, outfitIDs []uuid.UUID) (int64, error)
+	UnarchiveOutfit(ctx context.Context, userID, outfitID uuid.UUID) error
+	// AutoArchive archives outfits with garments that no longer exist and,
+	// when staleBefore is set, outfits not edited since then. Outfits
+	// unarchived after unarchivedBefore are left alone.
+	AutoArchive(ctx context.Context, unarchivedBefore time.Time, staleBefore *time.Time) (*AutoArchiveReport, error)
 	GetOutfitByID(ctx context.Context, outfitID uuid.UUID) (*Outfit, error)
-	GetOutfitsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Outfit, error)
+	GetOutfitsByUser(ctx context.Context, userID uuid.UUID, filters OutfitFilters, limit, offset int) ([]*Outfit, error)
+	GetAllOutfitsByUser(ctx context.Context, userID uuid.UUID) ([]*Outfit, error)
+	// GetOutfitsByIDs returns the outfits of the user among outfitIDs.
+	GetOutfitsByIDs(ctx context.Context, userID uuid.UUID, outfitIDs []uuid.UUID) ([]*Outfit, error)
+	ListRevisions(ctx context.Context, outfitID uuid.UUID) ([]*OutfitRevision, error)
+	// RestoreRevision sets the outfit back to the content of an earlier
+	// revision, recorded as a new revision.
+	RestoreRevision(ctx context.Context, outfitID, editorID uuid.UUID, version int) (*Outfit, error)
+	// SetOutfitImage stores the collage only if the garments of the outfit
+	// are still the ones it was made from, false otherwise.
+	SetOutfitImage(ctx context.Context, outfitID uuid.UUID, garmentIDs pq.StringArray, imageURL string) (bool, error)
+	ListOutfitsWithoutImage(ctx context.Context, afterID uuid.UUID, limit int) ([]*Outfit, error)
+}
+
+type OutfitCollageService interface {
+	// RenderCollage composes the garment photos of the outfit, stores the
+	// PNG and sets it as the ImageURL of the outfit.
+	RenderCollage(ctx context.Context, outfitID uuid.UUID) (string, error)
+	// BackfillCollages renders the collage of outfits created before
+	// collages existed, and returns how many were rendered.
+	BackfillCollages(ctx context.Context) (int, error)
+}
+
+type OutfitArchiveService interface {
+	// AutoArchive applies the auto-archive policy once.
+	AutoArchive(ctx context.Context) (*AutoArchiveReport, error)
 }
 
 func (o *Outfit) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Acciones que generan una revisión
+const (
+	RevisionCreate  = "create"
+	RevisionClone   = "clone"
+	RevisionUpdate  = "update"
+	RevisionRestore = "restore"
+)
+
+// OutfitSnapshot is the editable content of an outfit at a revision. The
+// collage and the archived flag are not versioned.
+type OutfitSnapshot struct {
+	Name       string   `json:"name"`
+	GarmentIDs []string `json:"garment_ids"`
+	Tags       []string `json:"tags"`
+	Occasion   string   `json:"occasion"`
+	Season     string   `json:"season"`
+}
+
+func (s *OutfitSnapshot) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal OutfitSnapshot value")
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s OutfitSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// FieldChange is the value of a field before and after a revision.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// OutfitChanges are the changed fields of a revision, by JSON name.
+type OutfitChanges map[string]FieldChange
+
+func (c *OutfitChanges) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal OutfitChanges value")
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c OutfitChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// OutfitRevision is an entry of the append-only history of an outfit.
+// Version 1 is the outfit as it was created (or as it was before its first
+// tracked edit, for outfits older than the history).
+type OutfitRevision struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	OutfitID uuid.UUID `json:"outfit_id" gorm:"type:uuid;not null;uniqueIndex:idx_outfit_revisions_version"`
+	Version  int       `json:"version" gorm:"not null;uniqueIndex:idx_outfit_revisions_version"`
+	// Who made the change
+	UserID          uuid.UUID      `json:"user_id" gorm:"type:uuid;not null"`
+	Action          string         `json:"action" gorm:"not null"`
+	Changes         OutfitChanges  `json:"changes" gorm:"type:jsonb"`
+	Snapshot        OutfitSnapshot `json:"snapshot" gorm:"type:jsonb;not null"`
+	RestoredVersion *int           `json:"restored_version,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+func (r *OutfitRevision) BeforeCreate(tx *gorm.DB) (err error) {
+	r.ID = uuid.New()
+	return
+}
+
+// Snapshot returns the versioned fields of the outfit.
+func (o *Outfit) Snapshot() OutfitSnapshot {
+	return OutfitSnapshot{
+		Name:       o.Name,
+		GarmentIDs: append([]string{}, o.GarmentIDs...),
+		Tags:       append([]string{}, o.Tags...),
+		Occasion:   o.Occasion,
+		Season:     o.Season,
+	}
+}
+
+// Updates are the columns to write to bring an outfit back to the snapshot.
+func (s OutfitSnapshot) Updates() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        s.Name,
+		"garment_ids": pq.StringArray(append([]string{}, s.GarmentIDs...)),
+		"tags":        pq.StringArray(append([]string{}, s.Tags...)),
+		"occasion":    s.Occasion,
+		"season":      s.Season,
+	}
+}
+
+// DiffSnapshots lists the fields that differ between two snapshots. With no
+// old snapshot every field counts as changed.
+func DiffSnapshots(old *OutfitSnapshot, new OutfitSnapshot) OutfitChanges {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"name", nil, new.Name},
+		{"garment_ids", nil, new.GarmentIDs},
+		{"tags", nil, new.Tags},
+		{"occasion", nil, new.Occasion},
+		{"season", nil, new.Season},
+	}
+	if old != nil {
+		fields[0].old = old.Name
+		fields[1].old = old.GarmentIDs
+		fields[2].old = old.Tags
+		fields[3].old = old.Occasion
+		fields[4].old = old.Season
+	}
+
+	changes := OutfitChanges{}
+	for _, field := range fields {
+		if old == nil || !reflect.DeepEqual(field.old, field.new) {
+			changes[field.name] = FieldChange{Old: field.old, New: field.new}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// restore applies the updates of a restore like the database does.
+func restore(outfit *Outfit, snapshot OutfitSnapshot) {
+	updates := snapshot.Updates()
+	outfit.Name = updates["name"].(string)
+	outfit.GarmentIDs = updates["garment_ids"].(pq.StringArray)
+	outfit.Tags = updates["tags"].(pq.StringArray)
+	outfit.Occasion = updates["occasion"].(string)
+	outfit.Season = updates["season"].(string)
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	outfit := &Outfit{
+		Name:       "Office",
+		GarmentIDs: pq.StringArray{"a", "b"},
+		Tags:       pq.StringArray{"work"},
+		Occasion:   "formal",
+		Season:     "winter",
+		Archived:   true,
+		ImageURL:   "https://cdn.example.com/collage.jpg",
+	}
+	version1 := outfit.Snapshot()
+
+	outfit.Name = "Weekend"
+	outfit.GarmentIDs = pq.StringArray{"a", "c"}
+	outfit.Occasion = "casual"
+	before := outfit.Snapshot()
+
+	restore(outfit, version1)
+
+	if got := outfit.Snapshot(); !reflect.DeepEqual(got, version1) {
+		t.Errorf("restored outfit = %+v, want %+v", got, version1)
+	}
+	if !outfit.Archived || outfit.ImageURL == "" {
+		t.Error("the restore changed fields that are not versioned")
+	}
+
+	changes := DiffSnapshots(&before, outfit.Snapshot())
+	want := OutfitChanges{
+		"name":        {Old: "Weekend", New: "Office"},
+		"garment_ids": {Old: []string{"a", "c"}, New: []string{"a", "b"}},
+		"occasion":    {Old: "casual", New: "formal"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes of the restore = %v, want %v", changes, want)
+	}
+}
+
+func TestRestoreCurrentSnapshotChangesNothing(t *testing.T) {
+	outfit := &Outfit{Name: "Office", GarmentIDs: pq.StringArray{"a"}, Tags: pq.StringArray{}}
+	before := outfit.Snapshot()
+
+	restore(outfit, before)
+
+	if changes := DiffSnapshots(&before, outfit.Snapshot()); len(changes) != 0 {
+		t.Errorf("restoring the current version changed %v", changes)
+	}
+}
+
+func TestSnapshotDoesNotShareArrays(t *testing.T) {
+	outfit := &Outfit{GarmentIDs: pq.StringArray{"a", "b"}, Tags: pq.StringArray{"work"}}
+	snapshot := outfit.Snapshot()
+
+	outfit.GarmentIDs[0] = "z"
+	restore(outfit, snapshot)
+	outfit.Tags[0] = "gym"
+
+	if snapshot.GarmentIDs[0] != "a" || snapshot.Tags[0] != "work" {
+		t.Errorf("the snapshot changed with the outfit: %+v", snapshot)
+	}
+}
+
+func TestDiffSnapshotsWithoutPrevious(t *testing.T) {
+	changes := DiffSnapshots(nil, OutfitSnapshot{Name: "Office"})
+
+	for _, field := range []string{"name", "garment_ids", "tags", "occasion", "season"} {
+		change, ok := changes[field]
+		if !ok {
+			t.Errorf("%s is not in the changes of the first version", field)
+			continue
+		}
+		if change.Old != nil {
+			t.Errorf("old %s = %v, want nil", field, change.Old)
+		}
+	}
+}
+
+func TestSnapshotScanValue(t *testing.T) {
+	snapshot := OutfitSnapshot{Name: "Office", GarmentIDs: []string{"a"}, Tags: []string{"work"}, Occasion: "formal", Season: "winter"}
+
+	value, err := snapshot.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned OutfitSnapshot
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scanned, snapshot) {
+		t.Errorf("scanned snapshot = %+v, want %+v", scanned, snapshot)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import "github.com/google/uuid"
+
+// Principal is the authenticated caller of a request, set by AuthProtected.
+type Principal struct {
+	UserID uuid.UUID
+	Role   UserRole
+	// Scopes of the personal access token, nil for session tokens which
+	// have full access
+	Scopes []string
+}
+
+// IsSession reports whether the request was made with a login session
+// instead of a personal access token.
+func (p *Principal) IsSession() bool {
+	return p.Scopes == nil
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if p.IsSession() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
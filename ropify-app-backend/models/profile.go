@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Profile is what the owner of the account sees about themselves.
+type Profile struct {
+	ID                uuid.UUID  `json:"id"`
+	FirstName         string     `json:"firstName"`
+	LastName          string     `json:"lastName"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	AvatarURL         string     `json:"avatar_url"`
+	Bio               string     `json:"bio"`
+	Role              UserRole   `json:"role"`
+	HasPassword       bool       `json:"has_password"`
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// PublicProfile only contains what anyone can see.
+type PublicProfile struct {
+	Username     string    `json:"username"`
+	FirstName    string    `json:"firstName"`
+	LastName     string    `json:"lastName"`
+	AvatarURL    string    `json:"avatar_url"`
+	Bio          string    `json:"bio"`
+	GarmentCount int64     `json:"garment_count"`
+	OutfitCount  int64     `json:"outfit_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ProfileUpdate struct {
+	FirstName *string `json:"firstName" validate:"omitempty,min=1,max=50"`
+	LastName  *string `json:"lastName" validate:"omitempty,max=50"`
+	Bio       *string `json:"bio" validate:"omitempty,max=300"`
+}
+
+type ProfileRepository interface {
+	UpdateProfile(ctx context.Context, userID uuid.UUID, updateData map[string]interface{}) (*User, error)
+	CountGarments(ctx context.Context, userID uuid.UUID) (int64, error)
+	CountOutfits(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type ProfileService interface {
+	GetProfile(ctx context.Context, userID uuid.UUID) (*Profile, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update *ProfileUpdate) (*Profile, error)
+	UpdateAvatar(ctx context.Context, userID uuid.UUID, imageBytes []byte) (*Profile, error)
+	GetPublicProfile(ctx context.Context, username string) (*PublicProfile, bool, error)
+}
+
+func (u *User) Profile() *Profile {
+	return &Profile{
+		ID:                u.ID,
+		FirstName:         u.FirstName,
+		LastName:          u.LastName,
+		Username:          u.Username,
+		Email:             u.Email,
+		AvatarURL:         u.AvatarURL,
+		Bio:               u.Bio,
+		Role:              u.Role,
+		HasPassword:       u.HasPassword(),
+		UsernameChangedAt: u.UsernameChangedAt,
+		CreatedAt:         u.CreatedAt,
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Lo que se puede compartir con un link público
+const (
+	ShareOutfit = "outfit"
+	ShareCloset = "closet"
+)
+
+var (
+	ErrShareNotFound = errors.New("share link not found")
+	ErrShareExpired  = errors.New("share link expired or revoked")
+)
+
+// ShareFilters narrow down the garments of a shared closet.
+type ShareFilters struct {
+	Category GarmentCategory `json:"category,omitempty"`
+	Color    string          `json:"color,omitempty"`
+}
+
+// ShareLink gives anyone with its token read access to an outfit or to the
+// closet of the user. The token is signed and not stored, see
+// ShareService.
+type ShareLink struct {
+	ID           uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID       uuid.UUID    `json:"user_id" gorm:"type:uuid;not null;index"`
+	Target       string       `json:"target" gorm:"not null"`
+	OutfitID     *uuid.UUID   `json:"outfit_id" gorm:"type:uuid;index"`
+	Filters      ShareFilters `json:"filters" gorm:"embedded;embeddedPrefix:filter_"`
+	Views        int64        `json:"views" gorm:"not null;default:0"`
+	LastViewedAt *time.Time   `json:"last_viewed_at"`
+	ExpiresAt    *time.Time   `json:"expires_at"`
+	RevokedAt    *time.Time   `json:"revoked_at"`
+	CreatedAt    time.Time    `json:"created_at"`
+
+	// Filled by the service for the owner
+	Token string `json:"token,omitempty" gorm:"-"`
+	URL   string `json:"url,omitempty" gorm:"-"`
+}
+
+// IsActive reports whether the link is neither revoked nor expired.
+func (l *ShareLink) IsActive(now time.Time) bool {
+	return l.RevokedAt == nil && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
+
+func (l *ShareLink) BeforeCreate(tx *gorm.DB) (err error) {
+	l.ID = uuid.New()
+	return
+}
+
+type ShareRequest struct {
+	Target    string       `json:"target" validate:"required,oneof=outfit closet"`
+	OutfitID  *uuid.UUID   `json:"outfit_id"`
+	Filters   ShareFilters `json:"filters"`
+	ExpiresAt *time.Time   `json:"expires_at"`
+}
+
+// SharedGarment only has what visitors of a share link can see.
+type SharedGarment struct {
+	ID           uuid.UUID       `json:"id"`
+	Category     GarmentCategory `json:"category"`
+	Color        string          `json:"color"`
+	Brand        string          `json:"brand"`
+	Labels       StringArray     `json:"labels"`
+	ImageURL     string          `json:"image_url"`
+	MediumURL    string          `json:"medium_url"`
+	ThumbnailURL string          `json:"thumbnail_url"`
+}
+
+type SharedOutfit struct {
+	Name     string           `json:"name"`
+	Tags     []string         `json:"tags"`
+	Occasion string           `json:"occasion"`
+	Season   string           `json:"season"`
+	ImageURL string           `json:"image_url"`
+	Garments []*SharedGarment `json:"garments"`
+}
+
+// SharedContent is what a share link shows.
+type SharedContent struct {
+	Target string         `json:"target"`
+	Owner  *PublicProfile `json:"owner"`
+	Outfit *SharedOutfit  `json:"outfit,omitempty"`
+	// Garments of a shared closet
+	Garments []*SharedGarment `json:"garments,omitempty"`
+	Filters  *ShareFilters    `json:"filters,omitempty"`
+	URL      string           `json:"url"`
+}
+
+type ShareRepository interface {
+	CreateShare(ctx context.Context, link *ShareLink) (*ShareLink, error)
+	GetShare(ctx context.Context, linkID uuid.UUID) (*ShareLink, error)
+	ListShares(ctx context.Context, userID uuid.UUID) ([]*ShareLink, error)
+	RevokeShare(ctx context.Context, userID, linkID uuid.UUID) error
+	RecordView(ctx context.Context, linkID uuid.UUID) error
+}
+
+type ShareService interface {
+	CreateShare(ctx context.Context, userID uuid.UUID, request *ShareRequest) (*ShareLink, error)
+	ListShares(ctx context.Context, userID uuid.UUID) ([]*ShareLink, error)
+	RevokeShare(ctx context.Context, userID, linkID uuid.UUID) error
+	// ViewShare resolves a token, counting the visit when countView is true.
+	ViewShare(ctx context.Context, token string, countView bool) (*SharedContent, error)
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// Scopes que puede tener un token personal
+const (
+	ScopeGarmentsRead  = "garments:read"
+	ScopeGarmentsWrite = "garments:write"
+	ScopeOutfitsRead   = "outfits:read"
+	ScopeOutfitsWrite  = "outfits:write"
+)
+
+var TokenScopes = []string{ScopeGarmentsRead, ScopeGarmentsWrite, ScopeOutfitsRead, ScopeOutfitsWrite}
+
+// PersonalAccessTokenPrefix starts every personal access token so they can be
+// told apart from session JWTs (and found by secret scanners).
+const PersonalAccessTokenPrefix = "rpf_"
+
+// PersonalAccessToken is a long lived credential for scripts. Only the
+// SHA-256 of the token is stored, Prefix identifies it in listings.
+type PersonalAccessToken struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID     uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name       string         `json:"name" gorm:"not null"`
+	Prefix     string         `json:"prefix" gorm:"not null;uniqueIndex"`
+	TokenHash  string         `json:"-" gorm:"not null;uniqueIndex"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	RevokedAt  *time.Time     `json:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type TokenRepository interface {
+	CreateToken(ctx context.Context, token *PersonalAccessToken) (*PersonalAccessToken, error)
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error
+}
+
+type TokenService interface {
+	CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*PersonalAccessToken, string, error)
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*PersonalAccessToken, error)
+	RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error
+}
+
+// HashToken returns the value stored for a personal access token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsActive reports whether the token is neither revoked nor expired.
+func (t *PersonalAccessToken) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+func (t *PersonalAccessToken) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.New()
+	return
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// TripDateLayout is the format of the dates of a trip in requests and
+// responses.
+const TripDateLayout = "2006-01-02"
+
+// MaxTripDays bounds the length of a trip, and so of its packing list.
+const MaxTripDays = 90
+
+// Formatos de exportación de la lista de equipaje
+const (
+	ChecklistText = "text"
+	ChecklistJSON = "json"
+)
+
+var (
+	ErrTripOutOfRange    = errors.New("date is outside the trip")
+	ErrTripOutfitPlanned = errors.New("the outfit is already planned that day")
+	ErrTripOutfitMissing = errors.New("outfit not found")
+)
+
+// Trip is a planned trip of a user. Dates are days, StartDate and EndDate are
+// both included.
+type Trip struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID      uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name        string         `json:"name" gorm:"not null"`
+	Destination string         `json:"destination" gorm:"not null"`
+	StartDate   time.Time      `json:"start_date" gorm:"type:date;not null"`
+	EndDate     time.Time      `json:"end_date" gorm:"type:date;not null"`
+	Activities  pq.StringArray `json:"activities" gorm:"type:text[]"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (t *Trip) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.New()
+	return
+}
+
+// Days returns the number of days of the trip.
+func (t *Trip) Days() int {
+	return int(t.EndDate.Sub(t.StartDate).Hours()/24) + 1
+}
+
+// TripOutfit is an outfit planned for a day of a trip.
+type TripOutfit struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	TripID   uuid.UUID `json:"trip_id" gorm:"type:uuid;not null;uniqueIndex:idx_trip_outfits_day"`
+	Date     time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_trip_outfits_day"`
+	OutfitID uuid.UUID `json:"outfit_id" gorm:"type:uuid;not null;uniqueIndex:idx_trip_outfits_day;index"`
+
+	// Filled when listing the days of a trip, nil if the outfit was deleted
+	Outfit *Outfit `json:"outfit,omitempty" gorm:"-"`
+}
+
+func (o *TripOutfit) BeforeCreate(tx *gorm.DB) (err error) {
+	o.ID = uuid.New()
+	return
+}
+
+// TripPackingCheck marks a garment of the packing list as packed. Unchecked
+// garments have no row.
+type TripPackingCheck struct {
+	TripID    uuid.UUID `json:"trip_id" gorm:"type:uuid;primaryKey"`
+	GarmentID uuid.UUID `json:"garment_id" gorm:"type:uuid;primaryKey"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+type TripRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Destination string   `json:"destination" validate:"required,max=200"`
+	StartDate   string   `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate     string   `json:"end_date" validate:"required,datetime=2006-01-02"`
+	Activities  []string `json:"activities" validate:"max=20,dive,max=50"`
+}
+
+type TripOutfitRequest struct {
+	Date     string    `json:"date" validate:"required,datetime=2006-01-02"`
+	OutfitID uuid.UUID `json:"outfit_id" validate:"required"`
+}
+
+// TripDay lists the outfits planned for a day of a trip.
+type TripDay struct {
+	Date    string        `json:"date"`
+	Outfits []*TripOutfit `json:"outfits"`
+}
+
+// TripDetail is a trip with every one of its days, planned or not.
+type TripDetail struct {
+	*Trip
+	Days []*TripDay `json:"days"`
+}
+
+// TripRef identifies another trip in a packing list.
+type TripRef struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+}
+
+// PackingItem is a garment to pack, once however many outfits use it.
+type PackingItem struct {
+	GarmentID    uuid.UUID       `json:"garment_id"`
+	Category     GarmentCategory `json:"category"`
+	Color        string          `json:"color"`
+	Brand        string          `json:"brand"`
+	ThumbnailURL string          `json:"thumbnail_url"`
+	// How many planned outfits use the garment, and on which days
+	Count   int      `json:"count"`
+	Days    []string `json:"days"`
+	Checked bool     `json:"checked"`
+	// Overlapping trips that also use the garment
+	OverlappingTrips []*TripRef `json:"overlapping_trips"`
+}
+
+// PackingList is the deduplicated list of garments of the outfits of a trip.
+type PackingList struct {
+	Trip           *Trip                   `json:"trip"`
+	Items          []*PackingItem          `json:"items"`
+	CategoryTotals map[GarmentCategory]int `json:"category_totals"`
+	TotalGarments  int                     `json:"total_garments"`
+	Checked        int                     `json:"checked"`
+}
+
+type TripRepository interface {
+	CreateTrip(ctx context.Context, trip *Trip) (*Trip, error)
+	// GetTrip only finds trips of the user.
+	GetTrip(ctx context.Context, userID, tripID uuid.UUID) (*Trip, error)
+	ListTrips(ctx context.Context, userID uuid.UUID) ([]*Trip, error)
+	UpdateTrip(ctx context.Context, trip *Trip) (*Trip, error)
+	// DeleteTrip deletes the trip with its outfits and checklist.
+	DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error
+	// ListOverlappingTrips returns the other trips of the user sharing a day
+	// with the range.
+	ListOverlappingTrips(ctx context.Context, userID, tripID uuid.UUID, start, end time.Time) ([]*Trip, error)
+
+	AddTripOutfit(ctx context.Context, tripOutfit *TripOutfit) (*TripOutfit, error)
+	RemoveTripOutfit(ctx context.Context, tripID, tripOutfitID uuid.UUID) error
+	ListTripOutfits(ctx context.Context, tripIDs []uuid.UUID) ([]*TripOutfit, error)
+
+	ListPackingChecks(ctx context.Context, tripID uuid.UUID) ([]*TripPackingCheck, error)
+	SetPackingCheck(ctx context.Context, tripID, garmentID uuid.UUID, checked bool) error
+}
+
+type TripService interface {
+	CreateTrip(ctx context.Context, userID uuid.UUID, request *TripRequest) (*Trip, error)
+	GetTrip(ctx context.Context, userID, tripID uuid.UUID) (*TripDetail, error)
+	ListTrips(ctx context.Context, userID uuid.UUID) ([]*Trip, error)
+	// UpdateTrip fails if outfits are planned outside the new dates.
+	UpdateTrip(ctx context.Context, userID, tripID uuid.UUID, request *TripRequest) (*Trip, error)
+	DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error
+
+	AddOutfit(ctx context.Context, userID, tripID uuid.UUID, request *TripOutfitRequest) (*TripOutfit, error)
+	RemoveOutfit(ctx context.Context, userID, tripID, tripOutfitID uuid.UUID) error
+
+	PackingList(ctx context.Context, userID, tripID uuid.UUID) (*PackingList, error)
+	// CheckItem marks a garment of the packing list as packed or not.
+	CheckItem(ctx context.Context, userID, tripID, garmentID uuid.UUID, checked bool) error
+	// WriteChecklist writes the packing list as a ChecklistText or
+	// ChecklistJSON checklist.
+	WriteChecklist(list *PackingList, format string, w io.Writer) error
+}
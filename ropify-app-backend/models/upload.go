@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UploadStatus string
+
+const (
+	UploadPending   UploadStatus = "pending"
+	UploadCompleted UploadStatus = "completed"
+	UploadExpired   UploadStatus = "expired"
+)
+
+var (
+	ErrUploadExpired  = errors.New("the upload has expired, request a new one")
+	ErrUploadMissing  = errors.New("the file was not uploaded")
+	ErrUploadTooLarge = errors.New("the uploaded file is larger than allowed")
+	ErrUploadDone     = errors.New("the upload was already completed")
+)
+
+// UploadIntent is a presigned upload of a garment photo. The client PUTs the
+// file straight to the storage and then completes the intent, which attaches
+// the photo to the garment.
+type UploadIntent struct {
+	ID          uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID      uuid.UUID    `json:"user_id" gorm:"type:uuid;not null;index"`
+	GarmentID   uuid.UUID    `json:"garment_id" gorm:"type:uuid;not null"`
+	Key         string       `json:"-" gorm:"not null"`
+	ContentType string       `json:"content_type" gorm:"not null"`
+	MaxSize     int64        `json:"max_size" gorm:"not null"`
+	Status      UploadStatus `json:"status" gorm:"not null;index"`
+	ExpiresAt   time.Time    `json:"expires_at" gorm:"not null;index"`
+	CompletedAt *time.Time   `json:"completed_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// PresignedUpload is what the client needs to send the file.
+type PresignedUpload struct {
+	UploadID  uuid.UUID         `json:"upload_id"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type UploadRepository interface {
+	CreateIntent(ctx context.Context, intent *UploadIntent) error
+	GetIntent(ctx context.Context, userID, uploadID uuid.UUID) (*UploadIntent, error)
+	UpdateIntentStatus(ctx context.Context, uploadID uuid.UUID, status UploadStatus, completedAt *time.Time) error
+	ListExpiredIntents(ctx context.Context, now time.Time, limit int) ([]*UploadIntent, error)
+}
+
+type UploadService interface {
+	CreateUpload(ctx context.Context, userID, garmentID uuid.UUID, contentType string, size int64) (*PresignedUpload, error)
+	CompleteUpload(ctx context.Context, userID, uploadID uuid.UUID) (*GarmentImages, error)
+	// CleanupExpired removes the files of abandoned uploads and returns how
+	// many intents expired.
+	CleanupExpired(ctx context.Context) (int, error)
+}
+
+func (u *UploadIntent) BeforeCreate(tx *gorm.DB) (err error) {
+	u.ID = uuid.New()
+	return
+}
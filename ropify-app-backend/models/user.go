@@ -11,9 +11,14 @@ type UserRole string
 
 const (
 	Manager  UserRole = "manager"
-	attendee UserRole = "attendee"
+	Attendee UserRole = "attendee"
 )
 
+// IsValid reports whether the role is one of the known roles.
+func (r UserRole) IsValid() bool {
+	return r == Manager || r == Attendee
+}
+
 type User struct {
 	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
 	FirstName  string    `json:"firstName" gorm:"text;not null"`
@@ -24,11 +29,40 @@ type User struct {
 	Bio        string    `json:"bio"`
 	Followers  []*User   `json:"followers" gorm:"many2many:user_followers;joinForeignKey:UserID;JoinReferences:FollowerID"`
 	Following  []*User   `json:"following" gorm:"many2many:user_following;joinForeignKey:UserID;JoinReferences:FollowingID"`
-	GoogleID   *string   `json:"google_id" gorm:"index"`
-	FacebookID *string   `json:"facebook_id" gorm:"index"`
-	TwitterID  *string   `json:"twitter_id" gorm:"index"`
+	GoogleID   *string   `json:"-" gorm:"index"` // Obsoleto, ver user_identities
+	FacebookID *string   `json:"-" gorm:"index"` // Obsoleto, ver user_identities
+	TwitterID  *string   `json:"-" gorm:"index"` // Obsoleto, ver user_identities
 	CreatedAt  time.Time `json:"created_at"`
 	Password   string    `json:"-"` // No exponer el password
+
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+
+	Role            UserRole   `json:"role" gorm:"type:text;not null;default:attendee"`
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	SuspendedReason string     `json:"suspended_reason"`
+}
+
+// HasPassword reports whether the user can sign in with email and password.
+// Users created through a social login have no password.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+// SetProviderID stores a social identity in the given column.
+func (u *User) SetProviderID(field, id string) {
+	switch field {
+	case "google_id":
+		u.GoogleID = &id
+	case "facebook_id":
+		u.FacebookID = &id
+	case "twitter_id":
+		u.TwitterID = &id
+	}
+}
+
+// IsSuspended reports whether the account was suspended by a manager.
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
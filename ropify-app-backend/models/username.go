@@ -0,0 +1,35 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUsernameTaken    = errors.New("the username is already taken")
+	ErrUsernameCooldown = errors.New("the username was changed recently, try again later")
+)
+
+// UsernameRedirect keeps a previous username pointing to its owner so old
+// profile links keep working after a rename.
+type UsernameRedirect struct {
+	OldUsername string    `json:"old_username" gorm:"primaryKey"`
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UsernameRepository interface {
+	IsUsernameTaken(ctx context.Context, username string, exceptUserID uuid.UUID) (bool, error)
+	ChangeUsername(ctx context.Context, userID uuid.UUID, newUsername string, changedAt time.Time) error
+	FindRedirect(ctx context.Context, oldUsername string) (*UsernameRedirect, error)
+}
+
+type UsernameService interface {
+	Generate(ctx context.Context, name string) (string, error)
+	IsAvailable(ctx context.Context, username string, userID uuid.UUID) (bool, error)
+	ChangeUsername(ctx context.Context, userID uuid.UUID, username string) (*User, error)
+	Resolve(ctx context.Context, username string) (*User, bool, error)
+}
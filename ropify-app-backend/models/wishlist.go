@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidWishlistItem   = errors.New("invalid wishlist item")
+	ErrWishlistItemPurchased = errors.New("wishlist item already purchased")
+)
+
+// WishlistItem is something the user wants to buy. Once bought it is
+// converted into a Garment and kept, with GarmentID, as a record.
+type WishlistItem struct {
+	ID       uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID   uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name     string          `json:"name" gorm:"not null"`
+	Link     string          `json:"link"`
+	Price    *float64        `json:"price" gorm:"type:numeric(12,2)"`
+	Currency string          `json:"currency"`
+	Category GarmentCategory `json:"category" gorm:"not null"`
+	Color    string          `json:"color"`
+	ImageURL string          `json:"image_url"`
+
+	PurchasedAt *time.Time `json:"purchased_at"`
+	// Garment created from the item when it was purchased
+	GarmentID *uuid.UUID `json:"garment_id" gorm:"type:uuid"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (w *WishlistItem) BeforeCreate(tx *gorm.DB) (err error) {
+	w.ID = uuid.New()
+	return
+}
+
+type WishlistItemRequest struct {
+	Name     string          `json:"name" validate:"required,max=200"`
+	Link     string          `json:"link" validate:"omitempty,url,max=2000"`
+	Price    *float64        `json:"price" validate:"omitempty,min=0"`
+	Currency string          `json:"currency" validate:"omitempty,len=3,alpha"`
+	Category GarmentCategory `json:"category" validate:"required"`
+	Color    string          `json:"color" validate:"max=50"`
+	ImageURL string          `json:"image_url" validate:"omitempty,url,max=2000"`
+}
+
+// PurchaseRequest overrides the fields of the item for the garment.
+type PurchaseRequest struct {
+	Color string `json:"color"`
+	Brand string `json:"brand"`
+}
+
+// CategoryGap compares the garments of a category with the target
+// distribution of the closet.
+type CategoryGap struct {
+	Category    GarmentCategory `json:"category"`
+	Garments    int             `json:"garments"`
+	Share       float64         `json:"share"`
+	TargetShare float64         `json:"target_share"`
+	// Garments missing to reach the target share, not counting wishlist items
+	Missing int `json:"missing"`
+	// Wishlist items not purchased yet in the category
+	Wishlisted       int  `json:"wishlisted"`
+	Underrepresented bool `json:"underrepresented"`
+}
+
+type GapAnalysis struct {
+	TotalGarments int `json:"total_garments"`
+	// Underrepresented categories first, the largest gap first
+	Categories []*CategoryGap `json:"categories"`
+}
+
+type WishlistRepository interface {
+	CreateItem(ctx context.Context, item *WishlistItem) (*WishlistItem, error)
+	// GetItem only finds items of the user.
+	GetItem(ctx context.Context, userID, itemID uuid.UUID) (*WishlistItem, error)
+	ListItems(ctx context.Context, userID uuid.UUID, purchased *bool) ([]*WishlistItem, error)
+	UpdateItem(ctx context.Context, item *WishlistItem) (*WishlistItem, error)
+	DeleteItem(ctx context.Context, userID, itemID uuid.UUID) error
+	// MarkPurchased creates the garment and links it to the item in a
+	// transaction, it fails with ErrWishlistItemPurchased if the item was
+	// purchased already.
+	MarkPurchased(ctx context.Context, item *WishlistItem, garment *Garment) (*Garment, error)
+	CountGarmentsByCategory(ctx context.Context, userID uuid.UUID) (map[GarmentCategory]int, error)
+}
+
+type WishlistService interface {
+	CreateItem(ctx context.Context, userID uuid.UUID, request *WishlistItemRequest) (*WishlistItem, error)
+	GetItem(ctx context.Context, userID, itemID uuid.UUID) (*WishlistItem, error)
+	ListItems(ctx context.Context, userID uuid.UUID, purchased *bool) ([]*WishlistItem, error)
+	UpdateItem(ctx context.Context, userID, itemID uuid.UUID, request *WishlistItemRequest) (*WishlistItem, error)
+	DeleteItem(ctx context.Context, userID, itemID uuid.UUID) error
+	// Purchase converts the item into a garment of the closet.
+	Purchase(ctx context.Context, userID, itemID uuid.UUID, request *PurchaseRequest) (*Garment, error)
+	// GapAnalysis compares the closet with target, the default distribution
+	// when nil. Weights don't need to add up to 1.
+	GapAnalysis(ctx context.Context, userID uuid.UUID, target map[GarmentCategory]float64) (*GapAnalysis, error)
+}
@@ -0,0 +1,219 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+// ScheduleDeletion creates the deletion job, or returns the pending one.
+func (r *AccountRepository) ScheduleDeletion(ctx context.Context, userID uuid.UUID, scheduledFor time.Time) (*models.AccountDeletion, error) {
+	if pending, err := r.GetPendingDeletion(ctx, userID); err == nil {
+		return pending, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	deletion := &models.AccountDeletion{
+		UserID:       userID,
+		Status:       models.DeletionPending,
+		Step:         models.DeletionStepRecords,
+		ScheduledFor: scheduledFor,
+	}
+
+	if err := r.db.WithContext(ctx).Create(deletion).Error; err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+// CancelDeletion cancels a pending deletion whose records were not deleted yet.
+func (r *AccountRepository) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&models.AccountDeletion{}).
+		Where("user_id = ? AND status = ? AND step = ?", userID, models.DeletionPending, models.DeletionStepRecords).
+		Update("status", models.DeletionCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *AccountRepository) GetPendingDeletion(ctx context.Context, userID uuid.UUID) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status = ?", userID, models.DeletionPending).
+		First(&deletion).Error; err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// ClaimDueDeletion takes the next due job and leases it, so several
+// instances of the API never process the same deletion at once.
+func (r *AccountRepository) ClaimDueDeletion(ctx context.Context, now time.Time, lease time.Duration) (*models.AccountDeletion, error) {
+	var deletion models.AccountDeletion
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND scheduled_for <= ?", models.DeletionPending, now).
+			Where("locked_until IS NULL OR locked_until < ?", now).
+			Order("scheduled_for").
+			First(&deletion).Error; err != nil {
+			return err
+		}
+
+		lockedUntil := now.Add(lease)
+		deletion.LockedUntil = &lockedUntil
+		deletion.Attempts++
+
+		return tx.Model(&deletion).Updates(map[string]interface{}{
+			"locked_until": deletion.LockedUntil,
+			"attempts":     deletion.Attempts,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+// DeleteUserRecords deletes every row of the user and moves the job to the
+// storage step in a single transaction.
+func (r *AccountRepository) DeleteUserRecords(ctx context.Context, deletion *models.AccountDeletion) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		userID := deletion.UserID
+
+		steps := []func() error{
+			func() error {
+				return tx.Exec("DELETE FROM outfit_revisions WHERE outfit_id IN (SELECT id FROM outfits WHERE user_id = ?)", userID).Error
+			},
+			func() error {
+				return tx.Exec("DELETE FROM trip_packing_checks WHERE trip_id IN (SELECT id FROM trips WHERE user_id = ?)", userID).Error
+			},
+			func() error {
+				return tx.Exec("DELETE FROM trip_outfits WHERE trip_id IN (SELECT id FROM trips WHERE user_id = ?)", userID).Error
+			},
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.Trip{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.Outfit{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.WishlistItem{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.GarmentStateChange{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.Garment{}).Error },
+			func() error {
+				return tx.Exec("DELETE FROM user_followers WHERE user_id = ? OR follower_id = ?", userID, userID).Error
+			},
+			func() error {
+				return tx.Exec("DELETE FROM user_following WHERE user_id = ? OR following_id = ?", userID, userID).Error
+			},
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.UserIdentity{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.UsernameRedirect{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.UploadIntent{}).Error },
+			func() error { return tx.Where("user_id = ?", userID).Delete(&models.ShareLink{}).Error },
+			func() error { return tx.Where("id = ?", userID).Delete(&models.User{}).Error },
+		}
+
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+
+		deletion.Step = models.DeletionStepStorage
+		return tx.Model(deletion).Update("step", deletion.Step).Error
+	})
+}
+
+func (r *AccountRepository) UpdateDeletion(ctx context.Context, deletion *models.AccountDeletion) error {
+	return r.db.WithContext(ctx).Save(deletion).Error
+}
+
+func (r *AccountRepository) ExportData(ctx context.Context, userID uuid.UUID) (*models.AccountExport, error) {
+	db := r.db.WithContext(ctx)
+	export := &models.AccountExport{
+		User:                &models.User{},
+		Identities:          []*models.UserIdentity{},
+		UsernameRedirects:   []*models.UsernameRedirect{},
+		Garments:            []*models.Garment{},
+		Outfits:             []*models.Outfit{},
+		Followers:           []uuid.UUID{},
+		Following:           []uuid.UUID{},
+		AccessTokens:        []*models.PersonalAccessToken{},
+		ShareLinks:          []*models.ShareLink{},
+		OutfitRevisions:     []*models.OutfitRevision{},
+		Trips:               []*models.Trip{},
+		TripOutfits:         []*models.TripOutfit{},
+		PackingChecks:       []*models.TripPackingCheck{},
+		WishlistItems:       []*models.WishlistItem{},
+		GarmentStateChanges: []*models.GarmentStateChange{},
+	}
+
+	if err := db.First(export.User, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Find(&export.Identities).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.UsernameRedirects).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.Garments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.Outfits).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("user_followers").Where("user_id = ?", userID).Pluck("follower_id", &export.Followers).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("user_following").Where("user_id = ?", userID).Pluck("following_id", &export.Following).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.AccessTokens).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.ShareLinks).Error; err != nil {
+		return nil, err
+	}
+	outfitIDs := db.Model(&models.Outfit{}).Select("id").Where("user_id = ?", userID)
+	if err := db.Where("outfit_id IN (?)", outfitIDs).Order("outfit_id, version").Find(&export.OutfitRevisions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("start_date").Find(&export.Trips).Error; err != nil {
+		return nil, err
+	}
+	tripIDs := db.Model(&models.Trip{}).Select("id").Where("user_id = ?", userID)
+	if err := db.Where("trip_id IN (?)", tripIDs).Order("trip_id, date").Find(&export.TripOutfits).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("trip_id IN (?)", tripIDs).Order("trip_id").Find(&export.PackingChecks).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&export.WishlistItems).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_id = ?", userID).Order("changed_at").Find(&export.GarmentStateChanges).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func NewAccountRepository(db *gorm.DB) models.AccountRepository {
+	return &AccountRepository{
+		db: db,
+	}
+}
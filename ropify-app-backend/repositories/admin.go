@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type AdminRepository struct {
+	db *gorm.DB
+}
+
+func (r *AdminRepository) ListUsers(ctx context.Context, filter *models.AdminUserFilter, limit, offset int) ([]*models.User, int64, error) {
+	users := []*models.User{}
+	query := r.db.WithContext(ctx).Model(&models.User{})
+
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("username ILIKE ? OR email ILIKE ? OR first_name ILIKE ? OR last_name ILIKE ?", like, like, like, like)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.Suspended != nil {
+		if *filter.Suspended {
+			query = query.Where("suspended_at IS NOT NULL")
+		} else {
+			query = query.Where("suspended_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *AdminRepository) UpdateUserFields(ctx context.Context, userID uuid.UUID, updateData map[string]interface{}) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Model(&user).Updates(updateData).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func NewAdminRepository(db *gorm.DB) models.AdminRepository {
+	return &AdminRepository{
+		db: db,
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 
 	"github.com/gaelzamora/ropify-app/models"
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -31,32 +29,25 @@ func (r *AuthRepository) RegisterUser(ctx context.Context, registerData *models.
 	return user, nil
 }
 
-func (r *AuthRepository) RegisterOAuthUser(ctx context.Context, user *models.User) (*models.User, error) {
-	randomPass := uuid.NewString()
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPass), bcrypt.DefaultCost)
-    if err != nil {
-        return nil, err
-    }
-    
-    user.Password = string(hashedPassword)
-    
-    // Usar una transacción para garantizar la consistencia
-    tx := r.db.WithContext(ctx).Begin()
-    if tx.Error != nil {
-        return nil, tx.Error
-    }
-    
-    if err := tx.Create(user).Error; err != nil {
-        tx.Rollback()
-        return nil, err
-    }
-    
-    if err := tx.Commit().Error; err != nil {
-        tx.Rollback()
-        return nil, err
-    }
-    
-    return user, nil
+// RegisterOAuthUser crea el usuario junto con su identidad social. Estos
+// usuarios no tienen password hasta que lo definan.
+func (r *AuthRepository) RegisterOAuthUser(ctx context.Context, user *models.User, identity *models.UserIdentity) (*models.User, error) {
+	user.Password = ""
+
+	// Usar una transacción para garantizar la consistencia
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
 func (r *AuthRepository) GetUser(ctx context.Context, query interface{}, args ...interface{}) (*models.User, error) {
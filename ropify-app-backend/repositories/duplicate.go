@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DuplicateRepository struct {
+	db *gorm.DB
+}
+
+// ListHashedGarments loads every hashed garment of the user, the distances
+// are computed in Go. The scan goes through the user_id index, closets are
+// small enough for that.
+func (r *DuplicateRepository) ListHashedGarments(ctx context.Context, userID uuid.UUID) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND image_hash IS NOT NULL", userID).
+		Order("created_at").
+		Find(&garments).Error; err != nil {
+		return nil, err
+	}
+	return garments, nil
+}
+
+func (r *DuplicateRepository) ListGarmentsWithoutHash(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	if err := r.db.WithContext(ctx).
+		Where("image_hash IS NULL AND image_url <> '' AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&garments).Error; err != nil {
+		return nil, err
+	}
+	return garments, nil
+}
+
+func (r *DuplicateRepository) SetImageHash(ctx context.Context, garmentID uuid.UUID, hash int64) error {
+	return r.db.WithContext(ctx).Model(&models.Garment{}).
+		Where("id = ?", garmentID).
+		Update("image_hash", hash).Error
+}
+
+func NewDuplicateRepository(db *gorm.DB) models.DuplicateRepository {
+	return &DuplicateRepository{
+		db: db,
+	}
+}
@@ -19,15 +19,62 @@ func (r *GarmentRepository) AddGarment(ctx context.Context, garment *models.Garm
 	return garment, nil
 }
 
-func (r *GarmentRepository) FindByBarcode(ctx context.Context, barcode string) (*models.Garment, error) {
+func (r *GarmentRepository) FindByBarcode(ctx context.Context, userID uuid.UUID, barcode string) (*models.Garment, error) {
 	var garment models.Garment
-	if err := r.db.WithContext(ctx).Where("barcode = ?", barcode).First(&garment).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND barcode = ?", userID, barcode).First(&garment).Error; err != nil {
 		return nil, err
 	}
 
 	return &garment, nil
 }
 
+func (r *GarmentRepository) GetGarmentByID(ctx context.Context, userID, garmentID uuid.UUID) (*models.Garment, error) {
+	var garment models.Garment
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", garmentID, userID).First(&garment).Error; err != nil {
+		return nil, err
+	}
+
+	return &garment, nil
+}
+
+func (r *GarmentRepository) FindByExternalIDs(ctx context.Context, userID uuid.UUID, externalIDs []string) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	if len(externalIDs) == 0 {
+		return garments, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND external_id IN ?", userID, externalIDs).
+		Find(&garments).Error; err != nil {
+		return nil, err
+	}
+
+	return garments, nil
+}
+
+func (r *GarmentRepository) GetAllGarmentsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&garments).Error; err != nil {
+		return nil, err
+	}
+	return garments, nil
+}
+
+func (r *GarmentRepository) GetGarmentsByIDs(ctx context.Context, userID uuid.UUID, garmentIDs []string) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	if len(garmentIDs) == 0 {
+		return garments, nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id::text IN ?", userID, garmentIDs).
+		Find(&garments).Error; err != nil {
+		return nil, err
+	}
+
+	return garments, nil
+}
+
 func (r *GarmentRepository) UpdateGarment(ctx context.Context, garmentID uuid.UUID, updatedData map[string]interface{}) (*models.Garment, error) {
 	var garment models.Garment
 	if err := r.db.WithContext(ctx).First(&garment, "id = ?", garmentID).Error; err != nil {
@@ -40,7 +87,15 @@ func (r *GarmentRepository) UpdateGarment(ctx context.Context, garmentID uuid.UU
 }
 
 func (r *GarmentRepository) DeleteGarment(ctx context.Context, garmentID uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&models.Garment{}, "id = ?", garmentID).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.GarmentStateChange{}, "garment_id = ?", garmentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.TripPackingCheck{}, "garment_id = ?", garmentID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Garment{}, "id = ?", garmentID).Error
+	})
 }
 
 func (r *GarmentRepository) FilterGarments(ctx context.Context, userID uuid.UUID, filters map[string]interface{}, sortBy string, limit, offset int) ([]*models.Garment, error) {
@@ -49,6 +104,11 @@ func (r *GarmentRepository) FilterGarments(ctx context.Context, userID uuid.UUID
 	query := r.db.WithContext(ctx).Where("user_id = ?", userID)
 
 	for key, value := range filters {
+		// Varios valores aceptados, como los estados disponibles
+		if states, ok := value.([]models.GarmentState); ok {
+			query = query.Where(key+" IN ?", states)
+			continue
+		}
 		query = query.Where(key+" = ?", value)
 	}
 
@@ -66,10 +126,15 @@ func (r *GarmentRepository) FilterGarments(ctx context.Context, userID uuid.UUID
 	return garments, nil
 }
 
-func (r *GarmentRepository) UpdateGarmentImage(userId uuid.UUID, imageURL string, garmentId uuid.UUID) error {
+func (r *GarmentRepository) UpdateGarmentImage(userId uuid.UUID, images *models.GarmentImages, garmentId uuid.UUID) error {
 	return r.db.Model(&models.Garment{}).
 		Where("id = ? AND user_id = ?", garmentId, userId).
-		Update("image_url", imageURL).Error
+		Updates(map[string]interface{}{
+			"image_url":     images.ImageURL,
+			"medium_url":    images.MediumURL,
+			"thumbnail_url": images.ThumbnailURL,
+			"image_hash":    images.ImageHash,
+		}).Error
 }
 
 func NewGarmentRepository(db *gorm.DB) models.GarmentRepository {
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type GarmentStateRepository struct {
+	db *gorm.DB
+}
+
+func (r *GarmentStateRepository) ChangeStates(ctx context.Context, userID uuid.UUID, garmentIDs []uuid.UUID, state models.GarmentState, note string) (*models.GarmentStateResult, error) {
+	var result *models.GarmentStateResult
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		garments := []*models.Garment{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND id IN ?", userID, garmentIDs).
+			Find(&garments).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result = models.PlanStateChanges(userID, garments, garmentIDs, state, note, now)
+
+		moved := make([]uuid.UUID, len(result.Changed))
+		for i, change := range result.Changed {
+			moved[i] = change.GarmentID
+		}
+
+		if len(moved) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Garment{}).
+			Where("user_id = ? AND id IN ?", userID, moved).
+			Updates(map[string]interface{}{
+				"state":            state,
+				"state_note":       note,
+				"state_changed_at": now,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&result.Changed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *GarmentStateRepository) ResetStates(ctx context.Context, userID uuid.UUID, from []models.GarmentState, state models.GarmentState) (int64, error) {
+	var moved int64
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// El historial primero, mientras las prendas tienen su estado anterior
+		if err := tx.Exec(`
+			INSERT INTO garment_state_changes (id, garment_id, user_id, from_state, to_state, note, changed_at)
+			SELECT uuid_generate_v4(), id, user_id, state, ?, '', ?
+			FROM garments WHERE user_id = ? AND state IN ?`, state, now, userID, from).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.Garment{}).
+			Where("user_id = ? AND state IN ?", userID, from).
+			Updates(map[string]interface{}{
+				"state":            state,
+				"state_note":       "",
+				"state_changed_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		moved = res.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+func (r *GarmentStateRepository) ListStateChanges(ctx context.Context, userID, garmentID uuid.UUID) ([]*models.GarmentStateChange, error) {
+	changes := []*models.GarmentStateChange{}
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND garment_id = ?", userID, garmentID).
+		Order("changed_at DESC").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func NewGarmentStateRepository(db *gorm.DB) models.GarmentStateRepository {
+	return &GarmentStateRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+func (r *IdentityRepository) FindIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) ListIdentities(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error) {
+	identities := []*models.UserIdentity{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("linked_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *IdentityRepository) AddIdentity(ctx context.Context, identity *models.UserIdentity) (*models.UserIdentity, error) {
+	if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// DeleteIdentity removes the identity unless it is the last way the user has
+// to sign in. The user row is locked so two concurrent unlinks cannot both pass.
+func (r *IdentityRepository) DeleteIdentity(ctx context.Context, userID, identityID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		res := tx.Where("id = ? AND user_id = ?", identityID, userID).Delete(&models.UserIdentity{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var remaining int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&remaining).Error; err != nil {
+			return err
+		}
+
+		if remaining == 0 && !user.HasPassword() {
+			return models.ErrLastLoginMethod
+		}
+
+		return nil
+	})
+}
+
+func NewIdentityRepository(db *gorm.DB) models.IdentityRepository {
+	return &IdentityRepository{
+		db: db,
+	}
+}
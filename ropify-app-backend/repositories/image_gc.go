@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"gorm.io/gorm"
+)
+
+type ImageReferenceRepository struct {
+	db *gorm.DB
+}
+
+// ReferencedImageURLs lists every column that can point to the storage.
+func (r *ImageReferenceRepository) ReferencedImageURLs(ctx context.Context) ([]string, error) {
+	urls := []string{}
+
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT image_url FROM garments WHERE image_url <> ''
+		UNION SELECT medium_url FROM garments WHERE medium_url <> ''
+		UNION SELECT thumbnail_url FROM garments WHERE thumbnail_url <> ''
+		UNION SELECT image_url FROM outfits WHERE image_url <> ''
+		UNION SELECT avatar_url FROM users WHERE avatar_url <> ''
+		UNION SELECT image_url FROM wishlist_items WHERE image_url <> ''
+	`).Scan(&urls).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+func (r *ImageReferenceRepository) PendingUploadKeys(ctx context.Context) ([]string, error) {
+	keys := []string{}
+	if err := r.db.WithContext(ctx).Model(&models.UploadIntent{}).
+		Where("status = ?", models.UploadPending).
+		Pluck("key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func NewImageReferenceRepository(db *gorm.DB) models.ImageReferenceRepository {
+	return &ImageReferenceRepository{
+		db: db,
+	}
+}
@@ -2,10 +2,14 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/gaelzamora/ropify-app/models"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OutfitRepository struct {
@@ -14,32 +18,160 @@ type OutfitRepository struct {
 
 // Crear outfit
 func (r *OutfitRepository) AddOutfit(ctx context.Context, outfit *models.Outfit) (*models.Outfit, error) {
-	if err := r.db.WithContext(ctx).Create(outfit).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(outfit).Error; err != nil {
+			return err
+		}
+
+		action := models.RevisionCreate
+		if outfit.ClonedFromID != nil {
+			action = models.RevisionClone
+		}
+		return appendRevision(tx, outfit, outfit.UserID, action, nil, nil)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return outfit, nil
 }
 
 // Editar outfit
-func (r *OutfitRepository) UpdateOutfit(ctx context.Context, outfitID uuid.UUID, updateData map[string]interface{}) (*models.Outfit, error) {
+func (r *OutfitRepository) UpdateOutfit(ctx context.Context, outfitID, editorID uuid.UUID, updateData map[string]interface{}) (*models.Outfit, error) {
 	var outfit models.Outfit
-	if err := r.db.WithContext(ctx).First(&outfit, "id = ?", outfitID).Error; err != nil {
-		return nil, err
-	}
-	if err := r.db.WithContext(ctx).Model(&outfit).Updates(updateData).Error; err != nil {
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&outfit, "id = ? AND user_id = ?", outfitID, editorID).Error; err != nil {
+			return err
+		}
+		before := outfit.Snapshot()
+
+		if err := tx.Model(&outfit).Where("user_id = ?", editorID).Updates(updateData).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&outfit, "id = ?", outfitID).Error; err != nil {
+			return err
+		}
+
+		return appendRevision(tx, &outfit, editorID, models.RevisionUpdate, &before, nil)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &outfit, nil
 }
 
-// Eliminar outfit
+// Eliminar outfit, con su historial
 func (r *OutfitRepository) DeleteOutfit(ctx context.Context, outfitID uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&models.Outfit{}, "id = ?", outfitID).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("outfit_id = ?", outfitID).Delete(&models.OutfitRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("outfit_id = ?", outfitID).Delete(&models.TripOutfit{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Outfit{}, "id = ?", outfitID).Error
+	})
 }
 
 // Archivar outfit (soft delete, ejemplo: usando un campo "archived")
-func (r *OutfitRepository) ArchiveOutfit(ctx context.Context, outfitID uuid.UUID) error {
-	return r.db.WithContext(ctx).Model(&models.Outfit{}).Where("id = ?", outfitID).Update("archived", true).Error
+func (r *OutfitRepository) ArchiveOutfit(ctx context.Context, userID, outfitID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&models.Outfit{}).
+		Where("id = ? AND user_id = ?", outfitID, userID).
+		Updates(map[string]interface{}{
+			"archived":        true,
+			"archived_at":     time.Now(),
+			"archived_reason": models.ArchiveManual,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Archivar varios outfits del usuario
+func (r *OutfitRepository) ArchiveOutfits(ctx context.Context, userID uuid.UUID, outfitIDs []uuid.UUID) (int64, error) {
+	if len(outfitIDs) == 0 {
+		return 0, nil
+	}
+
+	res := r.db.WithContext(ctx).Model(&models.Outfit{}).
+		Where("user_id = ? AND id IN ? AND archived = false", userID, outfitIDs).
+		Updates(map[string]interface{}{
+			"archived":        true,
+			"archived_at":     time.Now(),
+			"archived_reason": models.ArchiveManual,
+		})
+	return res.RowsAffected, res.Error
+}
+
+func (r *OutfitRepository) UnarchiveOutfit(ctx context.Context, userID, outfitID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&models.Outfit{}).
+		Where("id = ? AND user_id = ?", outfitID, userID).
+		Updates(map[string]interface{}{
+			"archived":        false,
+			"archived_at":     nil,
+			"archived_reason": "",
+			"unarchived_at":   time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *OutfitRepository) AutoArchive(ctx context.Context, unarchivedBefore time.Time, staleBefore *time.Time) (*models.AutoArchiveReport, error) {
+	report := &models.AutoArchiveReport{}
+	now := time.Now()
+
+	// Outfits que el usuario desarchivó hace poco no se tocan
+	candidates := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&models.Outfit{}).
+			Where("archived = false").
+			Where("unarchived_at IS NULL OR unarchived_at < ?", unarchivedBefore)
+	}
+
+	res := candidates().
+		Where(`EXISTS (
+			SELECT 1 FROM unnest(outfits.garment_ids) AS g(id)
+			WHERE NOT EXISTS (SELECT 1 FROM garments WHERE garments.id = g.id AND garments.user_id = outfits.user_id)
+		)`).
+		Updates(map[string]interface{}{
+			"archived":        true,
+			"archived_at":     now,
+			"archived_reason": models.ArchiveMissingGarments,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	report.MissingGarments = res.RowsAffected
+
+	if staleBefore == nil {
+		return report, nil
+	}
+
+	// La última edición es la revisión más reciente, o la creación
+	res = candidates().
+		Where(`COALESCE(
+			(SELECT max(created_at) FROM outfit_revisions WHERE outfit_revisions.outfit_id = outfits.id),
+			outfits.created_at
+		) < ?`, *staleBefore).
+		Updates(map[string]interface{}{
+			"archived":        true,
+			"archived_at":     now,
+			"archived_reason": models.ArchiveStale,
+		})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	report.Stale = res.RowsAffected
+
+	return report, nil
 }
 
 // Visualizar outfit por ID
@@ -52,10 +184,47 @@ func (r *OutfitRepository) GetOutfitByID(ctx context.Context, outfitID uuid.UUID
 }
 
 // Listar outfits de un usuario
-func (r *OutfitRepository) GetOutfitsByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*models.Outfit, error) {
+func (r *OutfitRepository) GetOutfitsByUser(ctx context.Context, userID uuid.UUID, filters models.OutfitFilters, limit, offset int) ([]*models.Outfit, error) {
 	outfits := []*models.Outfit{}
-	res := r.db.WithContext(ctx).
-		Where("user_id = ?", userID).
+	query := r.db.WithContext(ctx).Where("user_id = ?", userID)
+
+	switch filters.Archived {
+	case models.ArchivedOnly:
+		query = query.Where("archived = true")
+	case models.ArchivedInclude:
+	default:
+		query = query.Where("archived = false")
+	}
+
+	// && y @> usan el índice GIN de tags y garment_ids
+	if len(filters.AnyTags) > 0 {
+		query = query.Where("tags && ?::text[]", pq.StringArray(filters.AnyTags))
+	}
+	if len(filters.AllTags) > 0 {
+		query = query.Where("tags @> ?::text[]", pq.StringArray(filters.AllTags))
+	}
+	if filters.Occasion != "" {
+		query = query.Where("occasion = ?", filters.Occasion)
+	}
+	if filters.Season != "" {
+		query = query.Where("season = ?", filters.Season)
+	}
+	if filters.GarmentID != nil {
+		query = query.Where("garment_ids @> ARRAY[?]::uuid[]", filters.GarmentID.String())
+	}
+
+	switch filters.Sort {
+	case models.OutfitSortOldest:
+		query = query.Order("created_at, id")
+	case models.OutfitSortName:
+		query = query.Order("lower(name), id")
+	case models.OutfitSortGarmentCount:
+		query = query.Order("COALESCE(cardinality(garment_ids), 0) DESC, created_at DESC, id")
+	default:
+		query = query.Order("created_at DESC, id")
+	}
+
+	res := query.
 		Offset(offset).
 		Limit(limit).
 		Find(&outfits)
@@ -65,6 +234,132 @@ func (r *OutfitRepository) GetOutfitsByUser(ctx context.Context, userID uuid.UUI
 	return outfits, nil
 }
 
+func (r *OutfitRepository) GetAllOutfitsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Outfit, error) {
+	outfits := []*models.Outfit{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&outfits).Error; err != nil {
+		return nil, err
+	}
+	return outfits, nil
+}
+
+func (r *OutfitRepository) GetOutfitsByIDs(ctx context.Context, userID uuid.UUID, outfitIDs []uuid.UUID) ([]*models.Outfit, error) {
+	outfits := []*models.Outfit{}
+	if len(outfitIDs) == 0 {
+		return outfits, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND id IN ?", userID, outfitIDs).Find(&outfits).Error; err != nil {
+		return nil, err
+	}
+	return outfits, nil
+}
+
+func (r *OutfitRepository) ListRevisions(ctx context.Context, outfitID uuid.UUID) ([]*models.OutfitRevision, error) {
+	revisions := []*models.OutfitRevision{}
+	if err := r.db.WithContext(ctx).Where("outfit_id = ?", outfitID).Order("version DESC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *OutfitRepository) RestoreRevision(ctx context.Context, outfitID, editorID uuid.UUID, version int) (*models.Outfit, error) {
+	var outfit models.Outfit
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&outfit, "id = ? AND user_id = ?", outfitID, editorID).Error; err != nil {
+			return err
+		}
+		before := outfit.Snapshot()
+
+		var revision models.OutfitRevision
+		if err := tx.Where("outfit_id = ? AND version = ?", outfitID, version).First(&revision).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&outfit).Where("user_id = ?", editorID).Updates(revision.Snapshot.Updates()).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&outfit, "id = ?", outfitID).Error; err != nil {
+			return err
+		}
+
+		return appendRevision(tx, &outfit, editorID, models.RevisionRestore, &before, &version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &outfit, nil
+}
+
+// appendRevision records the current content of the outfit as its next
+// revision. Outfits created before the history get their previous content as
+// version 1 first. Nothing is recorded when the content did not change.
+func appendRevision(tx *gorm.DB, outfit *models.Outfit, editorID uuid.UUID, action string, before *models.OutfitSnapshot, restored *int) error {
+	version := 0
+	var last models.OutfitRevision
+	err := tx.Where("outfit_id = ?", outfit.ID).Order("version DESC").First(&last).Error
+	switch {
+	case err == nil:
+		version = last.Version
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if before != nil {
+			baseline := &models.OutfitRevision{
+				OutfitID:  outfit.ID,
+				Version:   1,
+				UserID:    outfit.UserID,
+				Action:    models.RevisionCreate,
+				Changes:   models.DiffSnapshots(nil, *before),
+				Snapshot:  *before,
+				CreatedAt: outfit.CreatedAt,
+			}
+			if err := tx.Create(baseline).Error; err != nil {
+				return err
+			}
+			version = 1
+		}
+	default:
+		return err
+	}
+
+	after := outfit.Snapshot()
+	changes := models.DiffSnapshots(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return tx.Create(&models.OutfitRevision{
+		OutfitID:        outfit.ID,
+		Version:         version + 1,
+		UserID:          editorID,
+		Action:          action,
+		Changes:         changes,
+		Snapshot:        after,
+		RestoredVersion: restored,
+	}).Error
+}
+
+func (r *OutfitRepository) SetOutfitImage(ctx context.Context, outfitID uuid.UUID, garmentIDs pq.StringArray, imageURL string) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.Outfit{}).
+		Where("id = ? AND garment_ids IS NOT DISTINCT FROM ?::uuid[]", outfitID, garmentIDs).
+		Update("image_url", imageURL)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *OutfitRepository) ListOutfitsWithoutImage(ctx context.Context, afterID uuid.UUID, limit int) ([]*models.Outfit, error) {
+	outfits := []*models.Outfit{}
+	if err := r.db.WithContext(ctx).
+		Where("(image_url IS NULL OR image_url = '') AND cardinality(garment_ids) > 0 AND id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&outfits).Error; err != nil {
+		return nil, err
+	}
+	return outfits, nil
+}
+
 func NewOutfitRepository(db *gorm.DB) models.OutfitRepository {
 	return &OutfitRepository{
 		db: db,
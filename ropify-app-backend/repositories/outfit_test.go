@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds the queries without a database, query receives every
+// SELECT.
+func dryRunDB(t *testing.T, query func(stmt *gorm.Statement)) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		query(tx.Statement)
+	})
+	return db
+}
+
+func TestGetOutfitsByUserFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters models.OutfitFilters
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "any tag",
+			filters: models.OutfitFilters{AnyTags: []string{"work", "summer"}},
+			want:    []string{"tags && $", "archived = false", "ORDER BY created_at DESC, id"},
+			notWant: []string{"tags @>"},
+		},
+		{
+			name:    "all tags",
+			filters: models.OutfitFilters{AllTags: []string{"work", "summer"}},
+			want:    []string{"tags @> $"},
+			notWant: []string{"tags &&"},
+		},
+		{
+			name:    "any and all tags",
+			filters: models.OutfitFilters{AnyTags: []string{"work"}, AllTags: []string{"summer"}},
+			want:    []string{"tags && $", "tags @> $"},
+		},
+		{
+			name:    "no tags",
+			filters: models.OutfitFilters{AnyTags: []string{}, AllTags: []string{}},
+			notWant: []string{"tags &&", "tags @>"},
+		},
+		{
+			name:    "oldest",
+			filters: models.OutfitFilters{Sort: models.OutfitSortOldest},
+			want:    []string{"ORDER BY created_at, id"},
+		},
+		{
+			name:    "name",
+			filters: models.OutfitFilters{Sort: models.OutfitSortName},
+			want:    []string{"ORDER BY lower(name), id"},
+		},
+		{
+			name:    "garment count",
+			filters: models.OutfitFilters{Sort: models.OutfitSortGarmentCount},
+			want:    []string{"ORDER BY COALESCE(cardinality(garment_ids), 0) DESC"},
+		},
+		{
+			name:    "archived included",
+			filters: models.OutfitFilters{Archived: models.ArchivedInclude},
+			notWant: []string{"archived ="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sql string
+			repository := NewOutfitRepository(dryRunDB(t, func(stmt *gorm.Statement) { sql = stmt.SQL.String() }))
+
+			if _, err := repository.GetOutfitsByUser(context.Background(), uuid.New(), tt.filters, 20, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(sql, want) {
+					t.Errorf("query %q does not contain %q", sql, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(sql, notWant) {
+					t.Errorf("query %q contains %q", sql, notWant)
+				}
+			}
+		})
+	}
+}
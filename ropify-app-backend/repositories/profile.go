@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ProfileRepository struct {
+	db *gorm.DB
+}
+
+func (r *ProfileRepository) UpdateProfile(ctx context.Context, userID uuid.UUID, updateData map[string]interface{}) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if len(updateData) == 0 {
+		return &user, nil
+	}
+	if err := r.db.WithContext(ctx).Model(&user).Updates(updateData).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *ProfileRepository) CountGarments(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Garment{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *ProfileRepository) CountOutfits(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Outfit{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func NewProfileRepository(db *gorm.DB) models.ProfileRepository {
+	return &ProfileRepository{
+		db: db,
+	}
+}
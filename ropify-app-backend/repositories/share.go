@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ShareRepository struct {
+	db *gorm.DB
+}
+
+func (r *ShareRepository) CreateShare(ctx context.Context, link *models.ShareLink) (*models.ShareLink, error) {
+	if err := r.db.WithContext(ctx).Create(link).Error; err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (r *ShareRepository) GetShare(ctx context.Context, linkID uuid.UUID) (*models.ShareLink, error) {
+	var link models.ShareLink
+	if err := r.db.WithContext(ctx).First(&link, "id = ?", linkID).Error; err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *ShareRepository) ListShares(ctx context.Context, userID uuid.UUID) ([]*models.ShareLink, error) {
+	links := []*models.ShareLink{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r *ShareRepository) RevokeShare(ctx context.Context, userID, linkID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&models.ShareLink{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", linkID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordView counts a visit in the database so concurrent views are not lost.
+func (r *ShareRepository) RecordView(ctx context.Context, linkID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.ShareLink{}).
+		Where("id = ?", linkID).
+		Updates(map[string]interface{}{
+			"views":          gorm.Expr("views + 1"),
+			"last_viewed_at": time.Now(),
+		}).Error
+}
+
+func NewShareRepository(db *gorm.DB) models.ShareRepository {
+	return &ShareRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func (r *TokenRepository) CreateToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *TokenRepository) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	tokens := []*models.PersonalAccessToken{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *TokenRepository) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func NewTokenRepository(db *gorm.DB) models.TokenRepository {
+	return &TokenRepository{
+		db: db,
+	}
+}
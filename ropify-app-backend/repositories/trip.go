@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TripRepository struct {
+	db *gorm.DB
+}
+
+func (r *TripRepository) CreateTrip(ctx context.Context, trip *models.Trip) (*models.Trip, error) {
+	if err := r.db.WithContext(ctx).Create(trip).Error; err != nil {
+		return nil, err
+	}
+	return trip, nil
+}
+
+func (r *TripRepository) GetTrip(ctx context.Context, userID, tripID uuid.UUID) (*models.Trip, error) {
+	var trip models.Trip
+	if err := r.db.WithContext(ctx).First(&trip, "id = ? AND user_id = ?", tripID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &trip, nil
+}
+
+func (r *TripRepository) ListTrips(ctx context.Context, userID uuid.UUID) ([]*models.Trip, error) {
+	trips := []*models.Trip{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("start_date DESC, created_at DESC").Find(&trips).Error; err != nil {
+		return nil, err
+	}
+	return trips, nil
+}
+
+func (r *TripRepository) UpdateTrip(ctx context.Context, trip *models.Trip) (*models.Trip, error) {
+	res := r.db.WithContext(ctx).Model(trip).
+		Where("user_id = ?", trip.UserID).
+		Select("name", "destination", "start_date", "end_date", "activities", "updated_at").
+		Updates(trip)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return trip, nil
+}
+
+func (r *TripRepository) DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", tripID, userID).Delete(&models.Trip{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("trip_id = ?", tripID).Delete(&models.TripOutfit{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("trip_id = ?", tripID).Delete(&models.TripPackingCheck{}).Error
+	})
+}
+
+func (r *TripRepository) ListOverlappingTrips(ctx context.Context, userID, tripID uuid.UUID, start, end time.Time) ([]*models.Trip, error) {
+	trips := []*models.Trip{}
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id <> ? AND start_date <= ? AND end_date >= ?", userID, tripID, end, start).
+		Order("start_date").
+		Find(&trips).Error; err != nil {
+		return nil, err
+	}
+	return trips, nil
+}
+
+func (r *TripRepository) AddTripOutfit(ctx context.Context, tripOutfit *models.TripOutfit) (*models.TripOutfit, error) {
+	if err := r.db.WithContext(ctx).Create(tripOutfit).Error; err != nil {
+		return nil, err
+	}
+	return tripOutfit, nil
+}
+
+func (r *TripRepository) RemoveTripOutfit(ctx context.Context, tripID, tripOutfitID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND trip_id = ?", tripOutfitID, tripID).Delete(&models.TripOutfit{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TripRepository) ListTripOutfits(ctx context.Context, tripIDs []uuid.UUID) ([]*models.TripOutfit, error) {
+	tripOutfits := []*models.TripOutfit{}
+	if len(tripIDs) == 0 {
+		return tripOutfits, nil
+	}
+
+	if err := r.db.WithContext(ctx).Where("trip_id IN ?", tripIDs).Order("date, id").Find(&tripOutfits).Error; err != nil {
+		return nil, err
+	}
+	return tripOutfits, nil
+}
+
+func (r *TripRepository) ListPackingChecks(ctx context.Context, tripID uuid.UUID) ([]*models.TripPackingCheck, error) {
+	checks := []*models.TripPackingCheck{}
+	if err := r.db.WithContext(ctx).Where("trip_id = ?", tripID).Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// SetPackingCheck is idempotent, checking a checked garment keeps its
+// original checked_at.
+func (r *TripRepository) SetPackingCheck(ctx context.Context, tripID, garmentID uuid.UUID, checked bool) error {
+	if !checked {
+		return r.db.WithContext(ctx).Where("trip_id = ? AND garment_id = ?", tripID, garmentID).Delete(&models.TripPackingCheck{}).Error
+	}
+
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO trip_packing_checks (trip_id, garment_id, checked_at) VALUES (?, ?, ?)
+		ON CONFLICT (trip_id, garment_id) DO NOTHING`, tripID, garmentID, time.Now()).Error
+}
+
+func NewTripRepository(db *gorm.DB) models.TripRepository {
+	return &TripRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestListOverlappingTripsBounds(t *testing.T) {
+	var sql string
+	var vars []interface{}
+	repository := NewTripRepository(dryRunDB(t, func(stmt *gorm.Statement) {
+		sql, vars = stmt.SQL.String(), stmt.Vars
+	}))
+
+	userID, tripID := uuid.New(), uuid.New()
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 7, 5, 0, 0, 0, 0, time.UTC)
+
+	if _, err := repository.ListOverlappingTrips(context.Background(), userID, tripID, start, end); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trips sharing only the first or last day overlap too
+	if !strings.Contains(sql, "start_date <= $3 AND end_date >= $4") {
+		t.Fatalf("query %q does not compare the dates inclusively", sql)
+	}
+	if len(vars) != 4 || vars[0] != userID || vars[1] != tripID || vars[2] != end || vars[3] != start {
+		t.Errorf("vars = %v, want user, trip, end and start", vars)
+	}
+}
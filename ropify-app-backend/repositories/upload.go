@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+func (r *UploadRepository) CreateIntent(ctx context.Context, intent *models.UploadIntent) error {
+	return r.db.WithContext(ctx).Create(intent).Error
+}
+
+func (r *UploadRepository) GetIntent(ctx context.Context, userID, uploadID uuid.UUID) (*models.UploadIntent, error) {
+	var intent models.UploadIntent
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", uploadID, userID).First(&intent).Error; err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// UpdateIntentStatus only moves pending intents, so an upload can not be
+// completed twice nor completed after the cleanup expired it.
+func (r *UploadRepository) UpdateIntentStatus(ctx context.Context, uploadID uuid.UUID, status models.UploadStatus, completedAt *time.Time) error {
+	res := r.db.WithContext(ctx).Model(&models.UploadIntent{}).
+		Where("id = ? AND status = ?", uploadID, models.UploadPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"completed_at": completedAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UploadRepository) ListExpiredIntents(ctx context.Context, now time.Time, limit int) ([]*models.UploadIntent, error) {
+	intents := []*models.UploadIntent{}
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", models.UploadPending, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&intents).Error; err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
+
+func NewUploadRepository(db *gorm.DB) models.UploadRepository {
+	return &UploadRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UsernameRepository struct {
+	db *gorm.DB
+}
+
+// IsUsernameTaken checks current usernames and the ones still reserved as
+// redirects, ignoring those owned by exceptUserID.
+func (r *UsernameRepository) IsUsernameTaken(ctx context.Context, username string, exceptUserID uuid.UUID) (bool, error) {
+	var count int64
+
+	if err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("username = ? AND id <> ?", username, exceptUserID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := r.db.WithContext(ctx).Model(&models.UsernameRedirect{}).
+		Where("old_username = ? AND user_id <> ?", username, exceptUserID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ChangeUsername renames the user and keeps the previous name as a redirect.
+func (r *UsernameRepository) ChangeUsername(ctx context.Context, userID uuid.UUID, newUsername string, changedAt time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		// Reclaiming one of your own old usernames drops its redirect
+		if err := tx.Where("old_username = ? AND user_id = ?", newUsername, userID).Delete(&models.UsernameRedirect{}).Error; err != nil {
+			return err
+		}
+
+		redirect := &models.UsernameRedirect{OldUsername: user.Username, UserID: userID, CreatedAt: changedAt}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(redirect).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"username":            newUsername,
+			"username_changed_at": changedAt,
+		}).Error
+	})
+}
+
+func (r *UsernameRepository) FindRedirect(ctx context.Context, oldUsername string) (*models.UsernameRedirect, error) {
+	if strings.Contains(oldUsername, "@") {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var redirect models.UsernameRedirect
+	if err := r.db.WithContext(ctx).First(&redirect, "old_username = ?", oldUsername).Error; err != nil {
+		return nil, err
+	}
+	return &redirect, nil
+}
+
+func NewUsernameRepository(db *gorm.DB) models.UsernameRepository {
+	return &UsernameRepository{
+		db: db,
+	}
+}
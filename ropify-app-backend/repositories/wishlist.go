@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WishlistRepository struct {
+	db *gorm.DB
+}
+
+func (r *WishlistRepository) CreateItem(ctx context.Context, item *models.WishlistItem) (*models.WishlistItem, error) {
+	if err := r.db.WithContext(ctx).Create(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (r *WishlistRepository) GetItem(ctx context.Context, userID, itemID uuid.UUID) (*models.WishlistItem, error) {
+	var item models.WishlistItem
+	if err := r.db.WithContext(ctx).First(&item, "id = ? AND user_id = ?", itemID, userID).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *WishlistRepository) ListItems(ctx context.Context, userID uuid.UUID, purchased *bool) ([]*models.WishlistItem, error) {
+	items := []*models.WishlistItem{}
+	query := r.db.WithContext(ctx).Where("user_id = ?", userID)
+
+	if purchased != nil && *purchased {
+		query = query.Where("purchased_at IS NOT NULL")
+	} else if purchased != nil {
+		query = query.Where("purchased_at IS NULL")
+	}
+
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *WishlistRepository) UpdateItem(ctx context.Context, item *models.WishlistItem) (*models.WishlistItem, error) {
+	res := r.db.WithContext(ctx).Model(item).
+		Where("user_id = ?", item.UserID).
+		Select("name", "link", "price", "currency", "category", "color", "image_url", "updated_at").
+		Updates(item)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return item, nil
+}
+
+func (r *WishlistRepository) DeleteItem(ctx context.Context, userID, itemID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", itemID, userID).Delete(&models.WishlistItem{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *WishlistRepository) MarkPurchased(ctx context.Context, item *models.WishlistItem, garment *models.Garment) (*models.Garment, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked models.WishlistItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&locked, "id = ? AND user_id = ?", item.ID, item.UserID).Error; err != nil {
+			return err
+		}
+		if locked.PurchasedAt != nil {
+			return models.ErrWishlistItemPurchased
+		}
+
+		if err := tx.Create(garment).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&locked).Updates(map[string]interface{}{
+			"purchased_at": now,
+			"garment_id":   garment.ID,
+		}).Error; err != nil {
+			return err
+		}
+		item.PurchasedAt = &now
+		item.GarmentID = &garment.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return garment, nil
+}
+
+func (r *WishlistRepository) CountGarmentsByCategory(ctx context.Context, userID uuid.UUID) (map[models.GarmentCategory]int, error) {
+	var rows []struct {
+		Category models.GarmentCategory
+		Count    int
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Garment{}).
+		Select("category, count(*) AS count").
+		Where("user_id = ?", userID).
+		Group("category").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.GarmentCategory]int, len(rows))
+	for _, row := range rows {
+		counts[row.Category] = row.Count
+	}
+	return counts, nil
+}
+
+func NewWishlistRepository(db *gorm.DB) models.WishlistRepository {
+	return &WishlistRepository{
+		db: db,
+	}
+}
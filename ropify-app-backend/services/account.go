@@ -0,0 +1,232 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const deletionLease = 10 * time.Minute
+
+type AccountService struct {
+	repository models.AccountRepository
+	users      models.AuthRepository
+	storage    ObjectStorage
+	grace      time.Duration
+}
+
+// RequestDeletion schedules the deletion of the account after the grace
+// period. Accounts with a password must confirm it.
+func (s *AccountService) RequestDeletion(ctx context.Context, userID uuid.UUID, password string) (*models.AccountDeletion, error) {
+	user, err := s.users.GetUser(ctx, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.HasPassword() && !models.MatchesHash(password, user.Password) {
+		return nil, fmt.Errorf("invalid password")
+	}
+
+	return s.repository.ScheduleDeletion(ctx, userID, time.Now().Add(s.grace))
+}
+
+func (s *AccountService) CancelDeletion(ctx context.Context, userID uuid.UUID) error {
+	return s.repository.CancelDeletion(ctx, userID)
+}
+
+// ProcessDueDeletions runs every deletion whose grace period is over and
+// returns how many were completed. Failed jobs keep their step and are
+// retried later with a backoff.
+func (s *AccountService) ProcessDueDeletions(ctx context.Context) (int, error) {
+	completed := 0
+
+	for {
+		deletion, err := s.repository.ClaimDueDeletion(ctx, time.Now(), deletionLease)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return completed, nil
+		}
+		if err != nil {
+			return completed, err
+		}
+
+		if err := s.runDeletion(ctx, deletion); err != nil {
+			log.Errorf("account deletion %s failed at step %s: %v", deletion.ID, deletion.Step, err)
+
+			retryAt := time.Now().Add(deletionBackoff(deletion.Attempts))
+			deletion.LastError = err.Error()
+			deletion.LockedUntil = &retryAt
+
+			if err := s.repository.UpdateDeletion(ctx, deletion); err != nil {
+				return completed, err
+			}
+			continue
+		}
+
+		completed++
+	}
+}
+
+func (s *AccountService) runDeletion(ctx context.Context, deletion *models.AccountDeletion) error {
+	if deletion.Step == models.DeletionStepRecords {
+		if err := s.repository.DeleteUserRecords(ctx, deletion); err != nil {
+			return err
+		}
+	}
+
+	if deletion.Step == models.DeletionStepStorage {
+		for _, prefix := range UserStoragePrefixes(deletion.UserID.String()) {
+			objects, err := s.storage.List(ctx, prefix)
+			if err != nil {
+				return err
+			}
+
+			keys := make([]string, 0, len(objects))
+			for _, obj := range objects {
+				keys = append(keys, obj.Key)
+			}
+
+			if err := s.storage.Delete(ctx, keys...); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	deletion.Status = models.DeletionCompleted
+	deletion.CompletedAt = &now
+	deletion.LockedUntil = nil
+	deletion.LastError = ""
+
+	return s.repository.UpdateDeletion(ctx, deletion)
+}
+
+func (s *AccountService) PrepareExport(ctx context.Context, userID uuid.UUID) (*models.AccountExport, error) {
+	return s.repository.ExportData(ctx, userID)
+}
+
+// WriteExport writes a ZIP with a JSON file per kind of record and every
+// image of those records kept in our storage.
+func (s *AccountService) WriteExport(ctx context.Context, export *models.AccountExport, w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.User.Profile()},
+		{"identities.json", export.Identities},
+		{"usernames.json", export.UsernameRedirects},
+		{"garments.json", export.Garments},
+		{"outfits.json", export.Outfits},
+		{"social.json", map[string]interface{}{"followers": export.Followers, "following": export.Following}},
+		{"access_tokens.json", export.AccessTokens},
+		{"share_links.json", export.ShareLinks},
+		{"outfit_revisions.json", export.OutfitRevisions},
+		{"trips.json", map[string]interface{}{"trips": export.Trips, "outfits": export.TripOutfits, "packing_checks": export.PackingChecks}},
+		{"wishlist.json", export.WishlistItems},
+		{"garment_states.json", export.GarmentStateChanges},
+	}
+
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	images := map[string]string{}
+	addImage := func(name, imageURL string) {
+		if imageURL != "" {
+			images[name] = imageURL
+		}
+	}
+	addImage("images/avatar", export.User.AvatarURL)
+	for _, garment := range export.Garments {
+		addImage("images/garments/"+garment.ID.String(), garment.ImageURL)
+		addImage("images/garments/"+garment.ID.String()+"_medium", garment.MediumURL)
+		addImage("images/garments/"+garment.ID.String()+"_thumbnail", garment.ThumbnailURL)
+	}
+	for _, outfit := range export.Outfits {
+		addImage("images/outfits/"+outfit.ID.String(), outfit.ImageURL)
+	}
+	for _, item := range export.WishlistItems {
+		addImage("images/wishlist/"+item.ID.String(), item.ImageURL)
+	}
+
+	for name, imageURL := range images {
+		key, ok := s.storage.KeyFromURL(imageURL)
+		if !ok {
+			// Imágenes externas (p. ej. del código de barras), solo van en el JSON
+			continue
+		}
+
+		data, err := s.storage.Get(ctx, key)
+		if err != nil {
+			log.Warnf("export of user %s: skipping image %s: %v", export.User.ID, key, err)
+			continue
+		}
+
+		f, err := archive.Create(name + path.Ext(key))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// RunDeletionWorker processes due deletions every interval until ctx is done.
+func RunDeletionWorker(ctx context.Context, service models.AccountService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if completed, err := service.ProcessDueDeletions(ctx); err != nil {
+			log.Errorf("account deletion worker: %v", err)
+		} else if completed > 0 {
+			log.Infof("account deletion worker: %d accounts deleted", completed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func deletionBackoff(attempts int) time.Duration {
+	backoff := time.Duration(attempts*attempts) * time.Minute
+	if backoff > time.Hour {
+		backoff = time.Hour
+	}
+	return backoff
+}
+
+func NewAccountService(repository models.AccountRepository, users models.AuthRepository, storage ObjectStorage, grace time.Duration) models.AccountService {
+	return &AccountService{
+		repository: repository,
+		users:      users,
+		storage:    storage,
+		grace:      grace,
+	}
+}
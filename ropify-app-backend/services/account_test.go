@@ -0,0 +1,192 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeAccountRepository schedules deletions without a database.
+type fakeAccountRepository struct {
+	models.AccountRepository
+
+	scheduled []uuid.UUID
+}
+
+func (r *fakeAccountRepository) ScheduleDeletion(ctx context.Context, userID uuid.UUID, scheduledFor time.Time) (*models.AccountDeletion, error) {
+	r.scheduled = append(r.scheduled, userID)
+	return &models.AccountDeletion{UserID: userID, Status: models.DeletionPending, ScheduledFor: scheduledFor}, nil
+}
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	storage, err := NewLocalStorage(t.TempDir(), "http://localhost:8080")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	return storage
+}
+
+func TestRequestDeletionPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		password string
+		wantErr  bool
+	}{
+		{name: "social account needs no password", user: &models.User{ID: uuid.New()}},
+		{name: "right password", user: &models.User{ID: uuid.New(), Password: string(hash)}, password: "correct horse"},
+		{name: "wrong password", user: &models.User{ID: uuid.New(), Password: string(hash)}, password: "wrong", wantErr: true},
+		{name: "missing password", user: &models.User{ID: uuid.New(), Password: string(hash)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeAccountRepository{}
+			service := NewAccountService(repository, &fakeUserLookup{user: tt.user}, nil, time.Hour)
+
+			_, err := service.RequestDeletion(context.Background(), tt.user.ID, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestDeletion error = %v, want error %v", err, tt.wantErr)
+			}
+			if scheduled := len(repository.scheduled) == 1; scheduled == tt.wantErr {
+				t.Errorf("deletion scheduled = %v, want %v", scheduled, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	storage := newTestLocalStorage(t)
+	userID := uuid.New()
+
+	avatarURL, err := storage.Put(context.Background(), "avatars/users/"+userID.String()+"/a.jpg", []byte("avatar"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	garmentURL, err := storage.Put(context.Background(), "garments/users/"+userID.String()+"/g.png", []byte("garment"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key, data string) string {
+		t.Helper()
+		stored, err := storage.Put(context.Background(), key, []byte(data), "image/png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	mediumURL := put("garments/users/"+userID.String()+"/g_medium.jpg", "medium")
+	thumbnailURL := put("garments/users/"+userID.String()+"/g_thumb.jpg", "thumbnail")
+	collageURL := put("outfits/users/"+userID.String()+"/o.png", "collage")
+	wishlistURL := put("wishlist/users/"+userID.String()+"/w.jpg", "wishlist")
+
+	garmentID := uuid.New()
+	outfitID := uuid.New()
+	itemID := uuid.New()
+	export := &models.AccountExport{
+		User:              &models.User{ID: userID, Username: "ana", AvatarURL: avatarURL},
+		Identities:        []*models.UserIdentity{{Provider: "google", Subject: "g-1"}},
+		UsernameRedirects: []*models.UsernameRedirect{{OldUsername: "ana@example.com", UserID: userID}},
+		Garments: []*models.Garment{
+			{ID: garmentID, ImageURL: garmentURL, MediumURL: mediumURL, ThumbnailURL: thumbnailURL},
+			// External images only go in the JSON
+			{ID: uuid.New(), ImageURL: "https://images.example.com/barcode.png"},
+		},
+		Outfits:   []*models.Outfit{{ID: outfitID, ImageURL: collageURL}},
+		Followers: []uuid.UUID{},
+		Following: []uuid.UUID{},
+		WishlistItems: []*models.WishlistItem{
+			{ID: itemID, ImageURL: wishlistURL},
+			{ID: uuid.New(), ImageURL: "https://shop.example.com/jacket.jpg"},
+		},
+		AccessTokens: []*models.PersonalAccessToken{
+			{Name: "script", Prefix: "rpf_abcd", TokenHash: "secret-hash"},
+		},
+	}
+
+	var buf bytes.Buffer
+	service := NewAccountService(&fakeAccountRepository{}, &fakeAuthRepository{}, storage, time.Hour)
+	if err := service.WriteExport(context.Background(), export, &buf); err != nil {
+		t.Fatalf("WriteExport: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("the export is not a ZIP: %v", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = data
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range []string{"profile.json", "identities.json", "usernames.json", "garments.json", "outfits.json", "social.json", "access_tokens.json", "share_links.json", "outfit_revisions.json", "trips.json", "wishlist.json", "garment_states.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("the export has no %s, files: %v", name, names)
+		}
+	}
+
+	if got := string(files["images/avatar.jpg"]); got != "avatar" {
+		t.Errorf("images/avatar.jpg = %q, want the stored avatar", got)
+	}
+	if got := string(files["images/garments/"+garmentID.String()+".png"]); got != "garment" {
+		t.Errorf("garment image = %q, want the stored image", got)
+	}
+	for name, want := range map[string]string{
+		"images/garments/" + garmentID.String() + "_medium.jpg":    "medium",
+		"images/garments/" + garmentID.String() + "_thumbnail.jpg": "thumbnail",
+		"images/outfits/" + outfitID.String() + ".png":             "collage",
+		"images/wishlist/" + itemID.String() + ".jpg":              "wishlist",
+	} {
+		if got := string(files[name]); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	images := 0
+	for _, name := range names {
+		if strings.HasPrefix(name, "images/") {
+			images++
+		}
+	}
+	if images != 6 {
+		t.Errorf("the export has %d images, want only the stored ones: %v", images, names)
+	}
+
+	if bytes.Contains(files["access_tokens.json"], []byte("secret-hash")) {
+		t.Errorf("access_tokens.json has the hash of the token: %s", files["access_tokens.json"])
+	}
+
+	var redirects []map[string]interface{}
+	if err := json.Unmarshal(files["usernames.json"], &redirects); err != nil || len(redirects) != 1 {
+		t.Errorf("usernames.json = %s, want the old username", files["usernames.json"])
+	}
+}
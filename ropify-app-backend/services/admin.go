@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+type AdminService struct {
+	repository models.AdminRepository
+	users      models.UserInvalidator
+}
+
+func (s *AdminService) ListUsers(ctx context.Context, filter *models.AdminUserFilter, limit, offset int) ([]*models.AdminUser, int64, error) {
+	users, total, err := s.repository.ListUsers(ctx, filter, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	views := make([]*models.AdminUser, 0, len(users))
+	for _, user := range users {
+		views = append(views, user.AdminView())
+	}
+
+	return views, total, nil
+}
+
+func (s *AdminService) SuspendUser(ctx context.Context, actorID, userID uuid.UUID, reason string) (*models.AdminUser, error) {
+	if actorID == userID {
+		return nil, models.ErrCannotChangeSelf
+	}
+
+	user, err := s.repository.UpdateUserFields(ctx, userID, map[string]interface{}{
+		"suspended_at":     time.Now(),
+		"suspended_reason": reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.users.Invalidate(userID)
+
+	return user.AdminView(), nil
+}
+
+func (s *AdminService) ReinstateUser(ctx context.Context, userID uuid.UUID) (*models.AdminUser, error) {
+	user, err := s.repository.UpdateUserFields(ctx, userID, map[string]interface{}{
+		"suspended_at":     nil,
+		"suspended_reason": "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.users.Invalidate(userID)
+
+	return user.AdminView(), nil
+}
+
+func (s *AdminService) ChangeRole(ctx context.Context, actorID, userID uuid.UUID, role models.UserRole) (*models.AdminUser, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	if actorID == userID {
+		return nil, models.ErrCannotChangeSelf
+	}
+
+	user, err := s.repository.UpdateUserFields(ctx, userID, map[string]interface{}{"role": role})
+	if err != nil {
+		return nil, err
+	}
+	s.users.Invalidate(userID)
+
+	return user.AdminView(), nil
+}
+
+// NewAdminService evicts the users it changes from users, so suspensions and
+// roles apply to the next request.
+func NewAdminService(repository models.AdminRepository, users models.UserInvalidator) models.AdminService {
+	return &AdminService{
+		repository: repository,
+		users:      users,
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+// fakeAdminRepository updates any user.
+type fakeAdminRepository struct {
+	models.AdminRepository
+}
+
+func (r *fakeAdminRepository) UpdateUserFields(ctx context.Context, userID uuid.UUID, updateData map[string]interface{}) (*models.User, error) {
+	return &models.User{ID: userID}, nil
+}
+
+// fakeUserInvalidator records the evicted users.
+type fakeUserInvalidator struct {
+	evicted []uuid.UUID
+}
+
+func (c *fakeUserInvalidator) Invalidate(userID uuid.UUID) {
+	c.evicted = append(c.evicted, userID)
+}
+
+func TestAdminChangesEvictTheUser(t *testing.T) {
+	adminID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		change    func(service models.AdminService) error
+		wantEvict bool
+	}{
+		{
+			name: "suspend",
+			change: func(service models.AdminService) error {
+				_, err := service.SuspendUser(context.Background(), adminID, userID, "spam")
+				return err
+			},
+			wantEvict: true,
+		},
+		{
+			name: "reinstate",
+			change: func(service models.AdminService) error {
+				_, err := service.ReinstateUser(context.Background(), userID)
+				return err
+			},
+			wantEvict: true,
+		},
+		{
+			name: "change role",
+			change: func(service models.AdminService) error {
+				_, err := service.ChangeRole(context.Background(), adminID, userID, models.Manager)
+				return err
+			},
+			wantEvict: true,
+		},
+		{
+			name: "suspend self",
+			change: func(service models.AdminService) error {
+				_, err := service.SuspendUser(context.Background(), userID, userID, "")
+				return err
+			},
+		},
+		{
+			name: "invalid role",
+			change: func(service models.AdminService) error {
+				_, err := service.ChangeRole(context.Background(), adminID, userID, "owner")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &fakeUserInvalidator{}
+			err := tt.change(NewAdminService(&fakeAdminRepository{}, cache))
+
+			if (err == nil) != tt.wantEvict {
+				t.Fatalf("error = %v, want success %v", err, tt.wantEvict)
+			}
+			evicted := len(cache.evicted) == 1 && cache.evicted[0] == userID
+			if evicted != tt.wantEvict || (!tt.wantEvict && len(cache.evicted) > 0) {
+				t.Errorf("evicted %v, want eviction of the user %v", cache.evicted, tt.wantEvict)
+			}
+		})
+	}
+}
@@ -10,12 +10,14 @@ import (
 	"github.com/gaelzamora/ropify-app/models"
 	"github.com/gaelzamora/ropify-app/utils"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type AuthService struct {
 	repository models.AuthRepository
+	usernames  models.UsernameRepository
 }
 
 func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentials) (string, *models.User, error) {
@@ -32,12 +34,11 @@ func (s *AuthService) Login(ctx context.Context, loginData *models.AuthCredentia
 		return "", nil, fmt.Errorf("invalid credentials")
 	}
 
-	claims := jwt.MapClaims{
-		"id":  user.ID,
-		"exp": time.Now().Add(time.Hour * 168).Unix(),
+	if user.IsSuspended() {
+		return "", nil, models.ErrUserSuspended
 	}
 
-	token, err := utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	token, err := GenerateUserToken(user)
 
 	if err != nil {
 		return "", nil, err
@@ -55,6 +56,20 @@ func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCre
 		return "", nil, fmt.Errorf("the user email is already in use")
 	}
 
+	registerData.Username = NormalizeUsername(registerData.Username)
+	if err := ValidateUsername(registerData.Username); err != nil {
+		return "", nil, err
+	}
+
+	// Los usernames retenidos como redirección también cuentan
+	taken, err := s.usernames.IsUsernameTaken(ctx, registerData.Username, uuid.Nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if taken {
+		return "", nil, models.ErrUsernameTaken
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerData.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return "", nil, err
@@ -67,13 +82,8 @@ func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCre
 		return "", nil, err
 	}
 
-	claims := jwt.MapClaims{
-		"id":  user.ID,
-		"exp": time.Now().Add(time.Hour * 168).Unix(),
-	}
-
 	// Generate the JWT
-	token, err := utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	token, err := GenerateUserToken(user)
 	if err != nil {
 		return "", nil, err
 	}
@@ -81,8 +91,21 @@ func (s *AuthService) Register(ctx context.Context, registerData *models.AuthCre
 	return token, user, nil
 }
 
-func NewAuthService(repository models.AuthRepository) models.AuthService {
+// GenerateUserToken issues the session JWT of the user, valid for a week. The
+// role claim lets clients adapt the UI without another request.
+func GenerateUserToken(user *models.User) (string, error) {
+	claims := jwt.MapClaims{
+		"id":   user.ID,
+		"role": user.Role,
+		"exp":  time.Now().Add(time.Hour * 168).Unix(),
+	}
+
+	return utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+}
+
+func NewAuthService(repository models.AuthRepository, usernames models.UsernameRepository) models.AuthService {
 	return &AuthService{
 		repository: repository,
+		usernames:  usernames,
 	}
 }
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("background removal service unavailable, circuit open")
+
+// BackgroundRemover removes the background of garment photos.
+type BackgroundRemover interface {
+	RemoveBackground(ctx context.Context, imageBytes []byte) ([]byte, error)
+	// Health reports whether the remover can take requests.
+	Health(ctx context.Context) error
+}
+
+// NoopBackgroundRemover returns the photos as they are, for environments
+// without the background removal service.
+type NoopBackgroundRemover struct{}
+
+func (NoopBackgroundRemover) RemoveBackground(ctx context.Context, imageBytes []byte) ([]byte, error) {
+	return imageBytes, nil
+}
+
+func (NoopBackgroundRemover) Health(ctx context.Context) error {
+	return nil
+}
+
+type BackgroundRemoverConfig struct {
+	// URL of the remove-background endpoint
+	URL string
+	// HealthURL defaults to /health on the host of URL
+	HealthURL string
+	// Timeout of every attempt
+	Timeout time.Duration
+	// Retries after the first attempt, for network errors and 5xx
+	Retries int
+	// Base of the exponential backoff between retries
+	Backoff time.Duration
+	// Consecutive failures that open the circuit, and how long it stays open
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// HTTPBackgroundRemover calls the Python background removal service.
+type HTTPBackgroundRemover struct {
+	config  BackgroundRemoverConfig
+	client  *http.Client
+	breaker *circuitBreaker
+}
+
+// errPermanent marks failures that retrying will not fix.
+type errPermanent struct{ err error }
+
+func (e errPermanent) Error() string { return e.err.Error() }
+func (e errPermanent) Unwrap() error { return e.err }
+
+func (r *HTTPBackgroundRemover) RemoveBackground(ctx context.Context, imageBytes []byte) ([]byte, error) {
+	if !r.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= r.config.Retries; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, jitteredBackoff(r.config.Backoff, attempt)); err != nil {
+				lastErr = err
+				break
+			}
+		}
+
+		output, err := r.removeOnce(ctx, imageBytes)
+		if err == nil {
+			r.breaker.Success()
+			return output, nil
+		}
+		lastErr = err
+
+		var permanent errPermanent
+		if errors.As(err, &permanent) {
+			// The service answered, it is up: the photo is the problem
+			r.breaker.Success()
+			return nil, err
+		}
+	}
+
+	r.breaker.Failure()
+	return nil, lastErr
+}
+
+func (r *HTTPBackgroundRemover) removeOnce(ctx context.Context, imageBytes []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", "garment.png")
+	if err != nil {
+		return nil, errPermanent{fmt.Errorf("failed to create form file: %v", err)}
+	}
+	if _, err := part.Write(imageBytes); err != nil {
+		return nil, errPermanent{fmt.Errorf("failed to write form file: %v", err)}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errPermanent{fmt.Errorf("failed to close form: %v", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.URL, &body)
+	if err != nil {
+		return nil, errPermanent{fmt.Errorf("failed to create request: %v", err)}
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove background: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("failed to remove background, status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errPermanent{fmt.Errorf("failed to remove background, status %d", resp.StatusCode)}
+	}
+
+	output, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output image: %v", err)
+	}
+
+	return output, nil
+}
+
+// Health calls the /health endpoint next to the remove-background one.
+func (r *HTTPBackgroundRemover) Health(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.config.HealthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("background removal service unreachable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("background removal service unhealthy, status %d", resp.StatusCode)
+	}
+
+	if r.breaker.State() == circuitOpen {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+func NewHTTPBackgroundRemover(config BackgroundRemoverConfig, client *http.Client) (*HTTPBackgroundRemover, error) {
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid background removal URL %q", config.URL)
+	}
+
+	if client == nil {
+		client = &http.Client{}
+	}
+	if config.HealthURL == "" {
+		config.HealthURL = (&url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host, Path: "/health"}).String()
+	}
+
+	return &HTTPBackgroundRemover{
+		config:  config,
+		client:  client,
+		breaker: newCircuitBreaker(config.FailureThreshold, config.OpenTimeout),
+	}, nil
+}
+
+// jitteredBackoff is a random wait up to base*2^(attempt-1), so retries of
+// many requests do not hit the service at the same time.
+func jitteredBackoff(base time.Duration, attempt int) time.Duration {
+	max := base << (attempt - 1)
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)) + 1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after threshold consecutive failures and fails fast
+// until openTimeout passes. Then a single request is let through: success
+// closes it, failure opens it again.
+type circuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	failures    int
+	state       circuitState
+	openedAt    time.Time
+}
+
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// The trial request is still running
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.state = circuitClosed
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) State() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen && time.Since(b.openedAt) >= b.openTimeout {
+		return circuitHalfOpen
+	}
+	return b.state
+}
+
+func newCircuitBreaker(threshold int, openTimeout time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &circuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
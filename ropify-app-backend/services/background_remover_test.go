@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	type step struct {
+		// expire moves the opening of the circuit past the open timeout
+		expire    bool
+		allow     bool
+		succeeded bool
+		wantState circuitState
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed under the threshold",
+			steps: []step{
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+			},
+		},
+		{
+			name: "success resets the failures",
+			steps: []step{
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+				{allow: true, succeeded: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+			},
+		},
+		{
+			name: "opens at the threshold and fails fast",
+			steps: []step{
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitOpen},
+				{allow: false, wantState: circuitOpen},
+			},
+		},
+		{
+			name: "half-open trial success closes it",
+			steps: []step{
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitOpen},
+				{expire: true, allow: true, succeeded: true, wantState: circuitClosed},
+				{allow: true, succeeded: true, wantState: circuitClosed},
+			},
+		},
+		{
+			name: "half-open trial failure opens it again",
+			steps: []step{
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitClosed},
+				{allow: true, wantState: circuitOpen},
+				{expire: true, allow: true, wantState: circuitOpen},
+				{allow: false, wantState: circuitOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker := newCircuitBreaker(3, time.Minute)
+
+			for i, s := range tt.steps {
+				if s.expire {
+					breaker.openedAt = time.Now().Add(-2 * time.Minute)
+					if state := breaker.State(); state != circuitHalfOpen {
+						t.Fatalf("step %d: state after the open timeout = %v, want half-open", i, state)
+					}
+				}
+
+				if allowed := breaker.Allow(); allowed != s.allow {
+					t.Fatalf("step %d: Allow = %v, want %v", i, allowed, s.allow)
+				}
+				if s.allow {
+					if s.expire && breaker.Allow() {
+						t.Fatalf("step %d: a second request got through while half-open", i)
+					}
+					if s.succeeded {
+						breaker.Success()
+					} else {
+						breaker.Failure()
+					}
+				}
+
+				if state := breaker.State(); state != s.wantState {
+					t.Fatalf("step %d: state = %v, want %v", i, state, s.wantState)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPBackgroundRemoverRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantCalls    int32
+		wantErr      bool
+		wantFailures int
+	}{
+		{name: "first attempt", statuses: []int{http.StatusOK}, wantCalls: 1},
+		{name: "retries 5xx", statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}, wantCalls: 3},
+		{name: "gives up after the retries", statuses: []int{500, 500, 500, 500}, wantCalls: 3, wantErr: true, wantFailures: 1},
+		{name: "does not retry 4xx", statuses: []int{http.StatusUnprocessableEntity}, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				call := atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.statuses[call-1])
+				w.Write([]byte("output"))
+			}))
+			defer server.Close()
+
+			remover, err := NewHTTPBackgroundRemover(BackgroundRemoverConfig{
+				URL:              server.URL + "/remove-background",
+				Timeout:          time.Second,
+				Retries:          2,
+				Backoff:          time.Millisecond,
+				FailureThreshold: 5,
+				OpenTimeout:      time.Minute,
+			}, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := remover.RemoveBackground(context.Background(), []byte("photo"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RemoveBackground error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(output) != "output" {
+				t.Errorf("RemoveBackground = %q, want the output of the service", output)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("the service got %d calls, want %d", calls, tt.wantCalls)
+			}
+			if remover.breaker.failures != tt.wantFailures {
+				t.Errorf("breaker failures = %d, want %d", remover.breaker.failures, tt.wantFailures)
+			}
+		})
+	}
+}
+
+func TestHTTPBackgroundRemoverOpensCircuit(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	remover, err := NewHTTPBackgroundRemover(BackgroundRemoverConfig{
+		URL:              server.URL + "/remove-background",
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := remover.RemoveBackground(context.Background(), []byte("photo")); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d: error = %v, want the error of the service", i, err)
+		}
+	}
+
+	if _, err := remover.RemoveBackground(context.Background(), []byte("photo")); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error with the circuit open = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("the service got %d calls, want none once the circuit is open", calls)
+	}
+	if err := remover.Health(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Health error with the circuit open = %v, want ErrCircuitOpen", err)
+	}
+}
+
+func TestNewHTTPBackgroundRemoverURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantHealth string
+		wantErr    bool
+	}{
+		{"http://background-removal-service:8000/remove-background", "http://background-removal-service:8000/health", false},
+		{"https://removal.example.com/api/v1/remove", "https://removal.example.com/health", false},
+		{"http://localhost:8000", "http://localhost:8000/health", false},
+		{"background-removal-service", "", true},
+		{"", "", true},
+		{"http://%zz/remove", "", true},
+	}
+
+	for _, tt := range tests {
+		remover, err := NewHTTPBackgroundRemover(BackgroundRemoverConfig{URL: tt.url}, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewHTTPBackgroundRemover(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if err == nil && remover.config.HealthURL != tt.wantHealth {
+			t.Errorf("health URL of %q = %q, want %q", tt.url, remover.config.HealthURL, tt.wantHealth)
+		}
+	}
+}
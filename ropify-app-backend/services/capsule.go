@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Bounds of the search, so large closets take about as long as small ones.
+// They count work instead of time so a seed always gives the same capsule.
+const (
+	capsuleCandidatesPerRole = 40
+	capsuleRestarts          = 4
+	capsuleEvaluations       = 40000
+)
+
+// Outfits saved when the request doesn't say how many
+const defaultCapsuleOutfits = 10
+
+// capsuleRole is the place of a garment in a combination. Accessories,
+// backpacks and unknown garments are not part of combinations.
+type capsuleRole int
+
+const (
+	roleTop capsuleRole = iota
+	roleBottom
+	roleDress
+	roleShoes
+	roleNone
+)
+
+func roleOf(category models.GarmentCategory) capsuleRole {
+	switch category {
+	case models.Top:
+		return roleTop
+	case models.Bottoms:
+		return roleBottom
+	case models.Dress:
+		return roleDress
+	case models.Sneakers:
+		return roleShoes
+	}
+	return roleNone
+}
+
+// capsuleSearch looks for the subset of the candidates with the most
+// combinations with random restarts and a swap local search.
+type capsuleSearch struct {
+	garments   []*models.Garment
+	roles      []capsuleRole
+	compatible [][]bool
+	// Without shoes in the closet combinations are made without them
+	hasShoes    bool
+	evaluations int
+
+	// Reused by score
+	buckets []int
+	bounds  [roleNone]int
+}
+
+func newCapsuleSearch(garments []*models.Garment) *capsuleSearch {
+	search := &capsuleSearch{}
+
+	byRole := map[capsuleRole][]*models.Garment{}
+	for _, garment := range garments {
+		if role := roleOf(garment.Category); role != roleNone {
+			byRole[role] = append(byRole[role], garment)
+		}
+	}
+	search.hasShoes = len(byRole[roleShoes]) > 0
+
+	colors := make(map[uuid.UUID]paletteColor, len(garments))
+	for _, garment := range garments {
+		colors[garment.ID] = newPaletteColor(garment.Color)
+	}
+
+	// En closets grandes solo quedan las prendas que combinan con más prendas
+	for role := roleTop; role < roleNone; role++ {
+		candidates := byRole[role]
+		if len(candidates) > capsuleCandidatesPerRole {
+			degree := make(map[uuid.UUID]int, len(candidates))
+			for _, garment := range candidates {
+				for other, others := range byRole {
+					if other == role || !rolesCombine(role, other) {
+						continue
+					}
+					for _, o := range others {
+						if colors[garment.ID].compatible(colors[o.ID]) {
+							degree[garment.ID]++
+						}
+					}
+				}
+			}
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return degree[candidates[i].ID] > degree[candidates[j].ID]
+			})
+			candidates = candidates[:capsuleCandidatesPerRole]
+		}
+
+		for _, garment := range candidates {
+			search.garments = append(search.garments, garment)
+			search.roles = append(search.roles, role)
+		}
+	}
+
+	search.compatible = make([][]bool, len(search.garments))
+	for i, a := range search.garments {
+		search.compatible[i] = make([]bool, len(search.garments))
+		for j, b := range search.garments {
+			search.compatible[i][j] = colors[a.ID].compatible(colors[b.ID])
+		}
+	}
+
+	return search
+}
+
+func rolesCombine(a, b capsuleRole) bool {
+	switch {
+	case a == roleShoes || b == roleShoes:
+		return true
+	case a == roleDress || b == roleDress:
+		return false
+	}
+	return a != b
+}
+
+// combinations calls visit with every valid combination of the capsule,
+// until it returns false, and returns how many it visited.
+func (c *capsuleSearch) combinations(capsule []int, visit func(combination []int) bool) int {
+	var tops, bottoms, dresses, shoes []int
+	for _, i := range capsule {
+		switch c.roles[i] {
+		case roleTop:
+			tops = append(tops, i)
+		case roleBottom:
+			bottoms = append(bottoms, i)
+		case roleDress:
+			dresses = append(dresses, i)
+		case roleShoes:
+			shoes = append(shoes, i)
+		}
+	}
+
+	count := 0
+	emit := func(combination ...int) bool {
+		count++
+		return visit == nil || visit(combination)
+	}
+
+	for _, t := range tops {
+		for _, b := range bottoms {
+			if !c.compatible[t][b] {
+				continue
+			}
+			if !c.hasShoes {
+				if !emit(t, b) {
+					return count
+				}
+				continue
+			}
+			for _, s := range shoes {
+				if c.compatible[t][s] && c.compatible[b][s] && !emit(t, b, s) {
+					return count
+				}
+			}
+		}
+	}
+	for _, d := range dresses {
+		if !c.hasShoes {
+			if !emit(d) {
+				return count
+			}
+			continue
+		}
+		for _, s := range shoes {
+			if c.compatible[d][s] && !emit(d, s) {
+				return count
+			}
+		}
+	}
+
+	return count
+}
+
+// score counts the combinations of the capsule like combinations, without
+// building them.
+func (c *capsuleSearch) score(capsule []int) int {
+	c.evaluations++
+
+	c.buckets = c.buckets[:0]
+	for role := roleTop; role < roleNone; role++ {
+		for _, i := range capsule {
+			if c.roles[i] == role {
+				c.buckets = append(c.buckets, i)
+			}
+		}
+		c.bounds[role] = len(c.buckets)
+	}
+	tops := c.buckets[:c.bounds[roleTop]]
+	bottoms := c.buckets[c.bounds[roleTop]:c.bounds[roleBottom]]
+	dresses := c.buckets[c.bounds[roleBottom]:c.bounds[roleDress]]
+	shoes := c.buckets[c.bounds[roleDress]:c.bounds[roleShoes]]
+
+	count := 0
+	for _, t := range tops {
+		compatible := c.compatible[t]
+		for _, b := range bottoms {
+			if !compatible[b] {
+				continue
+			}
+			if !c.hasShoes {
+				count++
+				continue
+			}
+			for _, s := range shoes {
+				if compatible[s] && c.compatible[b][s] {
+					count++
+				}
+			}
+		}
+	}
+	for _, d := range dresses {
+		if !c.hasShoes {
+			count++
+			continue
+		}
+		for _, s := range shoes {
+			if c.compatible[d][s] {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// run returns the best capsule of size garments found within the budget. It
+// stops early, with the best capsule so far, if ctx is done.
+func (c *capsuleSearch) run(ctx context.Context, rng *rand.Rand, size int) []int {
+	if size >= len(c.garments) {
+		all := make([]int, len(c.garments))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	var best []int
+	bestScore := -1
+
+	for restart := 0; restart < capsuleRestarts; restart++ {
+		perm := rng.Perm(len(c.garments))
+		current := append([]int{}, perm[:size]...)
+		outside := append([]int{}, perm[size:]...)
+		score := c.score(current)
+
+	search:
+		for improved := true; improved; {
+			improved = false
+			for _, i := range rng.Perm(len(current)) {
+				for _, j := range rng.Perm(len(outside)) {
+					if c.evaluations >= capsuleEvaluations || ctx.Err() != nil {
+						break search
+					}
+
+					current[i], outside[j] = outside[j], current[i]
+					if candidate := c.score(current); candidate > score {
+						score = candidate
+						improved = true
+						break
+					}
+					current[i], outside[j] = outside[j], current[i]
+				}
+			}
+		}
+
+		if score > bestScore {
+			best, bestScore = append([]int{}, current...), score
+		}
+		if c.evaluations >= capsuleEvaluations || ctx.Err() != nil {
+			break
+		}
+	}
+
+	return best
+}
+
+type CapsuleService struct {
+	garments models.GarmentRepository
+	outfits  models.OutfitRepository
+	collages models.OutfitCollageService
+}
+
+func (s *CapsuleService) GenerateCapsule(ctx context.Context, userID uuid.UUID, request *models.CapsuleRequest) (*models.Capsule, error) {
+	if request.Size < models.MinCapsuleSize || request.Size > models.MaxCapsuleSize {
+		return nil, fmt.Errorf("%w: size must be between %d and %d", models.ErrInvalidCapsule, models.MinCapsuleSize, models.MaxCapsuleSize)
+	}
+
+	garments, err := s.garments.GetAllGarmentsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// El resultado solo depende de la semilla, no del orden de la consulta
+	sort.Slice(garments, func(i, j int) bool {
+		return garments[i].ID.String() < garments[j].ID.String()
+	})
+
+	search := newCapsuleSearch(garments)
+	if len(search.garments) == 0 {
+		return nil, fmt.Errorf("%w: the closet has no tops, bottoms, dresses or shoes", models.ErrInvalidCapsule)
+	}
+
+	rng := rand.New(rand.NewSource(request.Seed))
+	picked := search.run(ctx, rng, request.Size)
+
+	capsule := &models.Capsule{
+		Seed:         request.Seed,
+		Garments:     make([]*models.Garment, len(picked)),
+		Combinations: []*models.CapsuleCombination{},
+		Candidates:   len(search.garments),
+	}
+	sort.Slice(picked, func(i, j int) bool {
+		if search.roles[picked[i]] != search.roles[picked[j]] {
+			return search.roles[picked[i]] < search.roles[picked[j]]
+		}
+		return picked[i] < picked[j]
+	})
+	for i, index := range picked {
+		capsule.Garments[i] = search.garments[index]
+	}
+
+	capsule.CombinationCount = search.combinations(picked, func(combination []int) bool {
+		garmentIDs := make([]uuid.UUID, len(combination))
+		for i, index := range combination {
+			garmentIDs[i] = search.garments[index].ID
+		}
+		capsule.Combinations = append(capsule.Combinations, &models.CapsuleCombination{GarmentIDs: garmentIDs})
+		return true
+	})
+	if len(capsule.Combinations) > models.MaxCapsuleCombinations {
+		capsule.Combinations = capsule.Combinations[:models.MaxCapsuleCombinations]
+	}
+
+	if request.Save {
+		if capsule.Outfits, err = s.saveOutfits(ctx, userID, capsule, request.MaxOutfits); err != nil {
+			return nil, err
+		}
+	}
+
+	return capsule, nil
+}
+
+func (s *CapsuleService) saveOutfits(ctx context.Context, userID uuid.UUID, capsule *models.Capsule, max int) ([]*models.Outfit, error) {
+	if max <= 0 {
+		max = defaultCapsuleOutfits
+	}
+	if max > models.MaxCapsuleOutfits {
+		max = models.MaxCapsuleOutfits
+	}
+
+	outfits := []*models.Outfit{}
+	for i, combination := range capsule.Combinations {
+		if i == max {
+			break
+		}
+
+		garmentIDs := make(pq.StringArray, len(combination.GarmentIDs))
+		for j, garmentID := range combination.GarmentIDs {
+			garmentIDs[j] = garmentID.String()
+		}
+
+		outfit, err := s.outfits.AddOutfit(ctx, &models.Outfit{
+			UserID:     userID,
+			Name:       fmt.Sprintf("Capsule %d #%d", capsule.Seed, i+1),
+			GarmentIDs: garmentIDs,
+			Tags:       pq.StringArray{models.CapsuleTag},
+		})
+		if err != nil {
+			return nil, err
+		}
+		outfits = append(outfits, outfit)
+	}
+
+	// Los collages se generan después de responder
+	go func() {
+		for _, outfit := range outfits {
+			renderCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := s.collages.RenderCollage(renderCtx, outfit.ID); err != nil {
+				log.Warnf("collage of outfit %s failed: %v", outfit.ID, err)
+			}
+			cancel()
+		}
+	}()
+
+	return outfits, nil
+}
+
+func NewCapsuleService(garments models.GarmentRepository, outfits models.OutfitRepository, collages models.OutfitCollageService) models.CapsuleService {
+	return &CapsuleService{
+		garments: garments,
+		outfits:  outfits,
+		collages: collages,
+	}
+}
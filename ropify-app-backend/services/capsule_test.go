@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+// fakeGarmentRepository returns the same closet for every user.
+type fakeGarmentRepository struct {
+	models.GarmentRepository
+
+	garments []*models.Garment
+}
+
+func (r *fakeGarmentRepository) GetAllGarmentsByUser(ctx context.Context, userID uuid.UUID) ([]*models.Garment, error) {
+	return append([]*models.Garment{}, r.garments...), nil
+}
+
+// testCloset builds a closet of n garments, the same for the same seed.
+func testCloset(n int, seed int64) []*models.Garment {
+	rng := rand.New(rand.NewSource(seed))
+	categories := []models.GarmentCategory{models.Top, models.Bottoms, models.Sneakers, models.Top, models.Bottoms, models.Dress, models.Accesories}
+	colors := []string{"black", "white", "#1e3a8a", "#dc2626", "#16a34a", "#f59e0b", "#9333ea", "#0ea5e9", "beige"}
+
+	garments := make([]*models.Garment, n)
+	for i := range garments {
+		var id uuid.UUID
+		rng.Read(id[:])
+		garments[i] = &models.Garment{
+			ID:       id,
+			Category: categories[rng.Intn(len(categories))],
+			Color:    colors[rng.Intn(len(colors))],
+		}
+	}
+	return garments
+}
+
+func capsuleGarmentIDs(capsule *models.Capsule) []uuid.UUID {
+	ids := make([]uuid.UUID, len(capsule.Garments))
+	for i, garment := range capsule.Garments {
+		ids[i] = garment.ID
+	}
+	return ids
+}
+
+func TestGenerateCapsuleFixedSeed(t *testing.T) {
+	closet := testCloset(150, 1)
+	request := &models.CapsuleRequest{Size: 12, Seed: 42}
+
+	first, err := NewCapsuleService(&fakeGarmentRepository{garments: closet}, nil, nil).GenerateCapsule(context.Background(), uuid.New(), request)
+	if err != nil {
+		t.Fatalf("GenerateCapsule: %v", err)
+	}
+
+	// The same seed gives the same capsule whatever the order of the closet
+	shuffled := append([]*models.Garment{}, closet...)
+	rand.New(rand.NewSource(7)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	second, err := NewCapsuleService(&fakeGarmentRepository{garments: shuffled}, nil, nil).GenerateCapsule(context.Background(), uuid.New(), request)
+	if err != nil {
+		t.Fatalf("GenerateCapsule: %v", err)
+	}
+
+	firstIDs, secondIDs := capsuleGarmentIDs(first), capsuleGarmentIDs(second)
+	if len(firstIDs) != request.Size {
+		t.Fatalf("the capsule has %d garments, want %d", len(firstIDs), request.Size)
+	}
+	for i := range firstIDs {
+		if firstIDs[i] != secondIDs[i] {
+			t.Fatalf("the same seed gave different capsules: %v and %v", firstIDs, secondIDs)
+		}
+	}
+	if first.CombinationCount != second.CombinationCount {
+		t.Errorf("the same seed gave %d and %d combinations", first.CombinationCount, second.CombinationCount)
+	}
+	if first.CombinationCount == 0 || len(first.Combinations) == 0 {
+		t.Fatalf("the capsule has no combinations")
+	}
+	if first.Candidates > 4*capsuleCandidatesPerRole {
+		t.Errorf("the search used %d candidates, want at most %d per role", first.Candidates, capsuleCandidatesPerRole)
+	}
+
+	inCapsule := map[uuid.UUID]models.GarmentCategory{}
+	for _, garment := range first.Garments {
+		inCapsule[garment.ID] = garment.Category
+	}
+	for _, combination := range first.Combinations {
+		counts := map[models.GarmentCategory]int{}
+		for _, garmentID := range combination.GarmentIDs {
+			category, ok := inCapsule[garmentID]
+			if !ok {
+				t.Fatalf("combination %v has a garment out of the capsule", combination.GarmentIDs)
+			}
+			counts[category]++
+		}
+		outfit := counts[models.Top] == 1 && counts[models.Bottoms] == 1 && counts[models.Dress] == 0
+		dress := counts[models.Dress] == 1 && counts[models.Top] == 0 && counts[models.Bottoms] == 0
+		if (!outfit && !dress) || counts[models.Sneakers] != 1 {
+			t.Errorf("combination %v is not a top and a bottom or a dress, with shoes: %v", combination.GarmentIDs, counts)
+		}
+	}
+}
+
+func TestCapsuleSearchFindsBestOfSmallCloset(t *testing.T) {
+	const size = 4
+	search := newCapsuleSearch(testCloset(12, 3))
+
+	// Every subset of the candidates, to know the best possible score
+	best := 0
+	var subsets func(start int, capsule []int)
+	subsets = func(start int, capsule []int) {
+		if len(capsule) == size {
+			if score := search.score(capsule); score > best {
+				best = score
+			}
+			return
+		}
+		for i := start; i < len(search.garments); i++ {
+			subsets(i+1, append(capsule, i))
+		}
+	}
+	subsets(0, nil)
+	search.evaluations = 0
+
+	picked := search.run(context.Background(), rand.New(rand.NewSource(1)), size)
+	if len(picked) != size {
+		t.Fatalf("run picked %d garments, want %d", len(picked), size)
+	}
+	if got := search.score(picked); got != best {
+		t.Errorf("the capsule has %d combinations, the best one has %d", got, best)
+	}
+	if got := search.combinations(picked, nil); got != search.score(picked) {
+		t.Errorf("combinations counted %d, score %d", got, search.score(picked))
+	}
+}
+
+func TestGenerateCapsuleInvalid(t *testing.T) {
+	accessories := []*models.Garment{
+		{ID: uuid.New(), Category: models.Accesories, Color: "black"},
+		{ID: uuid.New(), Category: models.Backpack, Color: "black"},
+	}
+
+	tests := []struct {
+		name    string
+		closet  []*models.Garment
+		request *models.CapsuleRequest
+	}{
+		{"too small", testCloset(20, 1), &models.CapsuleRequest{Size: models.MinCapsuleSize - 1}},
+		{"too large", testCloset(20, 1), &models.CapsuleRequest{Size: models.MaxCapsuleSize + 1}},
+		{"empty closet", nil, &models.CapsuleRequest{Size: models.MinCapsuleSize}},
+		{"only accessories", accessories, &models.CapsuleRequest{Size: models.MinCapsuleSize}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewCapsuleService(&fakeGarmentRepository{garments: tt.closet}, nil, nil)
+			if _, err := service.GenerateCapsule(context.Background(), uuid.New(), tt.request); !errors.Is(err, models.ErrInvalidCapsule) {
+				t.Errorf("GenerateCapsule error = %v, want ErrInvalidCapsule", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/gaelzamora/ropify-app/models"
+)
+
+// categoryAliases maps the category names used by the barcode API, wardrobe
+// apps and spreadsheets to GarmentCategory. Keys are lowercase.
+var categoryAliases = map[string]models.GarmentCategory{
+	"top": models.Top, "tops": models.Top, "shirt": models.Top, "t-shirt": models.Top,
+	"bottom": models.Bottoms, "bottoms": models.Bottoms, "pants": models.Bottoms, "jeans": models.Bottoms, "shorts": models.Bottoms,
+	"dress": models.Dress, "dresses": models.Dress,
+	"snearkers": models.Sneakers, "sneakers": models.Sneakers, "shoes": models.Sneakers,
+	"accesories": models.Accesories, "accessories": models.Accesories, "jewelry": models.Accesories, "watches": models.Accesories,
+	"backpack": models.Backpack, "bag": models.Backpack,
+}
+
+// NormalizeCategory maps a category name to GarmentCategory, case
+// insensitive. The bool is false when the name is unknown and the result is
+// models.Unknown.
+func NormalizeCategory(name string) (models.GarmentCategory, bool) {
+	category, ok := categoryAliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return models.Unknown, false
+	}
+	return category, true
+}
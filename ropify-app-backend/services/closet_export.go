@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+// Diseño del lookbook, en puntos sobre una página A4
+const (
+	lookbookMargin     = 40.0
+	lookbookGridTop    = 90.0
+	lookbookGridBottom = pdfPageHeight - 60
+	lookbookGap        = 15.0
+	lookbookColumns    = 3
+	lookbookRows       = 3
+	lookbookCaption    = 30.0
+	// Outfits are listed one per row with small photos of their garments
+	lookbookOutfitRow   = 130.0
+	lookbookOutfitPhoto = 80.0
+	// Largest side of the photos embedded in the PDF, in pixels
+	lookbookImageSide = 600
+)
+
+var categoryTitles = map[models.GarmentCategory]string{
+	models.Top:        "Tops",
+	models.Bottoms:    "Bottoms",
+	models.Dress:      "Dresses",
+	models.Sneakers:   "Sneakers",
+	models.Accesories: "Accessories",
+	models.Backpack:   "Bags",
+	models.Unknown:    "Other",
+}
+
+type ClosetExportService struct {
+	garments models.GarmentRepository
+	outfits  models.OutfitRepository
+	storage  ObjectStorage
+}
+
+func (s *ClosetExportService) PrepareExport(ctx context.Context, userID uuid.UUID, includeOutfits bool) (*models.ClosetExport, error) {
+	garments, err := s.garments.GetAllGarmentsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.ClosetExport{UserID: userID, Garments: garments}
+
+	if includeOutfits {
+		if export.Outfits, err = s.outfits.GetAllOutfitsByUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+func (s *ClosetExportService) WriteExport(ctx context.Context, export *models.ClosetExport, format string, w io.Writer) error {
+	switch format {
+	case models.ExportCSV:
+		return writeClosetCSV(export, w)
+	case models.ExportJSON:
+		return writeClosetJSON(export, w)
+	case models.ExportPDF:
+		return s.writeLookbook(ctx, export, w)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// writeClosetCSV uses the same columns as the import, so the file can be
+// imported again. Outfits are listed by name in their garments.
+func writeClosetCSV(export *models.ClosetExport, w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"id", "external_id", "category", "color", "labels", "brand", "image_url", "is_verified", "created_at"}
+	outfitNames := map[string][]string{}
+	if export.Outfits != nil {
+		header = append(header, "outfits")
+		for _, outfit := range export.Outfits {
+			for _, garmentID := range outfit.GarmentIDs {
+				outfitNames[garmentID] = append(outfitNames[garmentID], outfit.Name)
+			}
+		}
+	}
+
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, garment := range export.Garments {
+		externalID := ""
+		if garment.ExternalID != nil {
+			externalID = *garment.ExternalID
+		}
+
+		record := []string{
+			garment.ID.String(),
+			externalID,
+			string(garment.Category),
+			garment.Color,
+			strings.Join(garment.Labels, ";"),
+			garment.Brand,
+			garment.ImageURL,
+			strconv.FormatBool(garment.IsVerified),
+			garment.CreatedAt.Format(time.RFC3339),
+		}
+		if export.Outfits != nil {
+			record = append(record, strings.Join(outfitNames[garment.ID.String()], ";"))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeClosetJSON(export *models.ClosetExport, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(struct {
+		Garments []*models.Garment `json:"garments"`
+		Outfits  []*models.Outfit  `json:"outfits,omitempty"`
+	}{export.Garments, export.Outfits})
+}
+
+// lookbookImage is a photo already written to the PDF.
+type lookbookImage struct {
+	object        int
+	width, height int
+}
+
+type lookbook struct {
+	ctx     context.Context
+	storage ObjectStorage
+	pdf     *pdfWriter
+	pages   int
+	fonts   int
+	kids    []int
+	date    string
+	// Photos are embedded once and shared by the pages that show them
+	images map[uuid.UUID]*lookbookImage
+}
+
+// writeLookbook renders a grid of the garments grouped by category, followed
+// by the outfits when they were requested.
+func (s *ClosetExportService) writeLookbook(ctx context.Context, export *models.ClosetExport, w io.Writer) error {
+	pdf := newPDFWriter(w)
+	book := &lookbook{
+		ctx:     ctx,
+		storage: s.storage,
+		pdf:     pdf,
+		pages:   pdf.reserve(),
+		fonts:   pdf.reserve(),
+		date:    time.Now().Format("2006-01-02"),
+		images:  map[uuid.UUID]*lookbookImage{},
+	}
+	catalog := pdf.reserve()
+
+	pdf.object(book.fonts, "<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >> "+
+		"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>")
+
+	groups := map[models.GarmentCategory][]*models.Garment{}
+	for _, garment := range export.Garments {
+		category := garment.Category
+		if _, ok := categoryTitles[category]; !ok {
+			category = models.Unknown
+		}
+		groups[category] = append(groups[category], garment)
+	}
+
+	for _, category := range models.GarmentCategories {
+		if err := book.garmentPages(categoryTitles[category], groups[category]); err != nil {
+			return err
+		}
+	}
+
+	if len(export.Outfits) > 0 {
+		garments := make(map[string]*models.Garment, len(export.Garments))
+		for _, garment := range export.Garments {
+			garments[garment.ID.String()] = garment
+		}
+		if err := book.outfitPages(export.Outfits, garments); err != nil {
+			return err
+		}
+	}
+
+	if len(book.kids) == 0 {
+		page := book.newPage("Lookbook")
+		page.text(lookbookMargin, lookbookGridTop+20, 12, false, "There are no garments in this closet yet.")
+		book.finishPage(page)
+	}
+
+	kids := make([]string, len(book.kids))
+	for i, kid := range book.kids {
+		kids[i] = fmt.Sprintf("%d 0 R", kid)
+	}
+	pdf.object(book.pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	pdf.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", book.pages))
+
+	return pdf.close(catalog)
+}
+
+func (b *lookbook) garmentPages(title string, garments []*models.Garment) error {
+	perPage := lookbookColumns * lookbookRows
+	cellWidth := (pdfPageWidth - 2*lookbookMargin - (lookbookColumns-1)*lookbookGap) / lookbookColumns
+	cellHeight := (lookbookGridBottom - lookbookGridTop - (lookbookRows-1)*lookbookGap) / lookbookRows
+
+	for start := 0; start < len(garments); start += perPage {
+		heading := fmt.Sprintf("%s (%d)", title, len(garments))
+		if start > 0 {
+			heading = title + " (continued)"
+		}
+		page := b.newPage(heading)
+
+		for i, garment := range garments[start:min(start+perPage, len(garments))] {
+			if err := b.ctx.Err(); err != nil {
+				return err
+			}
+
+			x := lookbookMargin + float64(i%lookbookColumns)*(cellWidth+lookbookGap)
+			y := lookbookGridTop + float64(i/lookbookColumns)*(cellHeight+lookbookGap)
+
+			b.drawPhoto(page, garment, x, y, cellWidth, cellHeight-lookbookCaption)
+
+			caption := garment.Color
+			if garment.Brand != "" {
+				caption += " - " + garment.Brand
+			}
+			captionY := y + cellHeight - lookbookCaption
+			page.text(x, captionY+12, 9, true, truncateText(caption, 9, cellWidth))
+			page.text(x, captionY+24, 8, false, truncateText(strings.Join(garment.Labels, ", "), 8, cellWidth))
+		}
+
+		b.finishPage(page)
+	}
+
+	return nil
+}
+
+func (b *lookbook) outfitPages(outfits []*models.Outfit, garments map[string]*models.Garment) error {
+	height, width := lookbookGridBottom-lookbookGridTop, pdfPageWidth-2*lookbookMargin
+	perPage := int(height / lookbookOutfitRow)
+	photos := int((width + lookbookGap/2) / (lookbookOutfitPhoto + lookbookGap/2))
+
+	for start := 0; start < len(outfits); start += perPage {
+		heading := fmt.Sprintf("Outfits (%d)", len(outfits))
+		if start > 0 {
+			heading = "Outfits (continued)"
+		}
+		page := b.newPage(heading)
+
+		for i, outfit := range outfits[start:min(start+perPage, len(outfits))] {
+			if err := b.ctx.Err(); err != nil {
+				return err
+			}
+
+			y := lookbookGridTop + float64(i)*lookbookOutfitRow
+			page.text(lookbookMargin, y+12, 12, true, truncateText(outfit.Name, 12, width))
+
+			details := []string{}
+			for _, detail := range []string{outfit.Occasion, outfit.Season, strings.Join(outfit.Tags, ", ")} {
+				if detail != "" {
+					details = append(details, detail)
+				}
+			}
+			page.text(lookbookMargin, y+24, 8, false, truncateText(strings.Join(details, " - "), 8, width))
+
+			x := lookbookMargin
+			for j, garmentID := range outfit.GarmentIDs {
+				if j == photos {
+					break
+				}
+				garment, ok := garments[garmentID]
+				if !ok {
+					continue
+				}
+				b.drawPhoto(page, garment, x, y+32, lookbookOutfitPhoto, lookbookOutfitPhoto)
+				x += lookbookOutfitPhoto + lookbookGap/2
+			}
+
+			page.line(lookbookMargin, y+lookbookOutfitRow-8, pdfPageWidth-lookbookMargin, y+lookbookOutfitRow-8, 0.85)
+		}
+
+		b.finishPage(page)
+	}
+
+	return nil
+}
+
+func (b *lookbook) newPage(title string) *pdfPage {
+	page := newPDFPage()
+	page.text(lookbookMargin, lookbookMargin+20, 20, true, title)
+	page.line(lookbookMargin, lookbookMargin+32, pdfPageWidth-lookbookMargin, lookbookMargin+32, 0.7)
+	return page
+}
+
+func (b *lookbook) finishPage(page *pdfPage) {
+	footer := fmt.Sprintf("Ropify lookbook - %s - %d", b.date, len(b.kids)+1)
+	page.text(lookbookMargin, pdfPageHeight-30, 8, false, footer)
+	b.kids = append(b.kids, b.pdf.addPage(page, b.pages, b.fonts))
+}
+
+// drawPhoto fits the photo of the garment in the box, or draws a placeholder
+// when it has none or it can not be read.
+func (b *lookbook) drawPhoto(page *pdfPage, garment *models.Garment, x, y, width, height float64) {
+	img := b.image(garment)
+	if img == nil {
+		page.fillRect(x, y, width, height, 0.92)
+		page.text(x+width/2-20, y+height/2+3, 8, false, "No photo")
+		return
+	}
+
+	scale := width / float64(img.width)
+	if s := height / float64(img.height); s < scale {
+		scale = s
+	}
+	w, h := float64(img.width)*scale, float64(img.height)*scale
+	page.image(img.object, x+(width-w)/2, y+(height-h)/2, w, h)
+}
+
+func (b *lookbook) image(garment *models.Garment) *lookbookImage {
+	if img, ok := b.images[garment.ID]; ok {
+		return img
+	}
+
+	img, err := b.loadImage(garment)
+	if err != nil {
+		log.Warnf("lookbook: skipping photo of garment %s: %v", garment.ID, err)
+	}
+	b.images[garment.ID] = img
+	return img
+}
+
+// loadImage embeds the medium rendition, or the original for garments
+// created before renditions existed.
+func (b *lookbook) loadImage(garment *models.Garment) (*lookbookImage, error) {
+	imageURL := garment.MediumURL
+	if imageURL == "" {
+		imageURL = garment.ImageURL
+	}
+	if imageURL == "" {
+		return nil, nil
+	}
+
+	key, ok := b.storage.KeyFromURL(imageURL)
+	if !ok {
+		return nil, fmt.Errorf("image %s is not in our storage", imageURL)
+	}
+
+	data, err := b.storage.Get(b.ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+	decoded = FitWithin(decoded, lookbookImageSide)
+
+	// EncodeJPEG puts transparent photos on a white background
+	jpeg, err := EncodeJPEG(decoded, 80)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := decoded.Bounds()
+	return &lookbookImage{
+		object: b.pdf.jpeg(jpeg, bounds.Dx(), bounds.Dy()),
+		width:  bounds.Dx(),
+		height: bounds.Dy(),
+	}, nil
+}
+
+func NewClosetExportService(garments models.GarmentRepository, outfits models.OutfitRepository, storage ObjectStorage) models.ClosetExportService {
+	return &ClosetExportService{
+		garments: garments,
+		outfits:  outfits,
+		storage:  storage,
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func testClosetExport(withOutfits bool) *models.ClosetExport {
+	externalID := "sheet-7"
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	shirt := &models.Garment{
+		ID:         uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+		ExternalID: &externalID,
+		Category:   models.Top,
+		Color:      "white",
+		Labels:     models.StringArray{"cotton", "summer"},
+		Brand:      "Acme",
+		ImageURL:   "https://cdn.example.com/shirt.jpg",
+		IsVerified: true,
+		CreatedAt:  createdAt,
+	}
+	jeans := &models.Garment{
+		ID:        uuid.MustParse("22222222-2222-2222-2222-222222222222"),
+		Category:  models.Bottoms,
+		Color:     "#1e3a8a",
+		CreatedAt: createdAt,
+	}
+
+	export := &models.ClosetExport{Garments: []*models.Garment{shirt, jeans}}
+	if withOutfits {
+		export.Outfits = []*models.Outfit{
+			{ID: uuid.New(), Name: "Office", GarmentIDs: pq.StringArray{shirt.ID.String(), jeans.ID.String()}},
+			{ID: uuid.New(), Name: "Beach", GarmentIDs: pq.StringArray{shirt.ID.String()}},
+		}
+	}
+	return export
+}
+
+func TestWriteClosetCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		outfits bool
+		want    [][]string
+	}{
+		{
+			name: "garments",
+			want: [][]string{
+				{"id", "external_id", "category", "color", "labels", "brand", "image_url", "is_verified", "created_at"},
+				{"11111111-1111-1111-1111-111111111111", "sheet-7", "top", "white", "cotton;summer", "Acme", "https://cdn.example.com/shirt.jpg", "true", "2025-03-01T12:00:00Z"},
+				{"22222222-2222-2222-2222-222222222222", "", "bottom", "#1e3a8a", "", "", "", "false", "2025-03-01T12:00:00Z"},
+			},
+		},
+		{
+			name:    "with outfits",
+			outfits: true,
+			want: [][]string{
+				{"id", "external_id", "category", "color", "labels", "brand", "image_url", "is_verified", "created_at", "outfits"},
+				{"11111111-1111-1111-1111-111111111111", "sheet-7", "top", "white", "cotton;summer", "Acme", "https://cdn.example.com/shirt.jpg", "true", "2025-03-01T12:00:00Z", "Office;Beach"},
+				{"22222222-2222-2222-2222-222222222222", "", "bottom", "#1e3a8a", "", "", "", "false", "2025-03-01T12:00:00Z", "Office"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			service := NewClosetExportService(nil, nil, nil)
+			if err := service.WriteExport(context.Background(), testClosetExport(tt.outfits), models.ExportCSV, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("the export is not a valid CSV: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.want) {
+				t.Errorf("CSV = %q, want %q", records, tt.want)
+			}
+		})
+	}
+}
+
+func TestClosetCSVCanBeImported(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeClosetCSV(testClosetExport(true), &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parseImportCSV(&buf)
+	if err != nil {
+		t.Fatalf("parseImportCSV: %v", err)
+	}
+
+	want := []models.GarmentImportRow{
+		{ExternalID: "sheet-7", Category: "top", Color: "white", Labels: []string{"cotton", "summer"}, Brand: "Acme", ImageURL: "https://cdn.example.com/shirt.jpg"},
+		{Category: "bottom", Color: "#1e3a8a", Labels: []string{}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("imported rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestWriteClosetJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		outfits bool
+		keys    []string
+	}{
+		{"garments", false, []string{"garments"}},
+		{"with outfits", true, []string{"garments", "outfits"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			service := NewClosetExportService(nil, nil, nil)
+			if err := service.WriteExport(context.Background(), testClosetExport(tt.outfits), models.ExportJSON, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			var document map[string][]map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &document); err != nil {
+				t.Fatalf("the export is not valid JSON: %v", err)
+			}
+
+			keys := []string{}
+			for key := range document {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Fatalf("keys = %v, want %v", keys, tt.keys)
+			}
+
+			garments := document["garments"]
+			if len(garments) != 2 {
+				t.Fatalf("got %d garments, want 2", len(garments))
+			}
+			if garments[0]["id"] != "11111111-1111-1111-1111-111111111111" || garments[0]["color"] != "white" {
+				t.Errorf("first garment = %v", garments[0])
+			}
+			if _, ok := garments[0]["image_hash"]; ok {
+				t.Error("the export includes the image hash")
+			}
+			if tt.outfits && len(document["outfits"]) != 2 {
+				t.Errorf("got %d outfits, want 2", len(document["outfits"]))
+			}
+		})
+	}
+}
+
+func TestWriteClosetExportFormat(t *testing.T) {
+	service := NewClosetExportService(nil, nil, nil)
+	if err := service.WriteExport(context.Background(), testClosetExport(false), "xlsx", &bytes.Buffer{}); err == nil {
+		t.Error("WriteExport accepted an unsupported format")
+	}
+}
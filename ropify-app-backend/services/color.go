@@ -0,0 +1,140 @@
+package services
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// colorNames maps the color names users type, in English and Spanish, to an
+// RGB value. Keys are lowercase.
+var colorNames = map[string]uint32{
+	"black": 0x000000, "negro": 0x000000,
+	"white": 0xffffff, "blanco": 0xffffff,
+	"gray": 0x808080, "grey": 0x808080, "gris": 0x808080,
+	"charcoal": 0x36454f,
+	"silver":   0xc0c0c0, "plata": 0xc0c0c0,
+	"beige": 0xd8c8a8, "cream": 0xf3ead6, "crema": 0xf3ead6, "ivory": 0xfffff0,
+	"khaki": 0xc3b091, "caqui": 0xc3b091,
+	"brown": 0x7b4b2a, "cafe": 0x7b4b2a, "café": 0x7b4b2a, "marron": 0x7b4b2a, "marrón": 0x7b4b2a,
+	"camel": 0xc19a6b, "tan": 0xd2b48c,
+	"navy": 0x1f2a44, "marino": 0x1f2a44, "azul marino": 0x1f2a44,
+	"denim": 0x3b5b82, "mezclilla": 0x3b5b82,
+	"blue": 0x2f6fdf, "azul": 0x2f6fdf,
+	"light blue": 0x9cc9ee, "celeste": 0x9cc9ee,
+	"red": 0xd62828, "rojo": 0xd62828,
+	"burgundy": 0x7a1f2b, "vino": 0x7a1f2b, "bordo": 0x7a1f2b,
+	"pink": 0xf4a6c1, "rosa": 0xf4a6c1,
+	"orange": 0xf28c28, "naranja": 0xf28c28,
+	"yellow": 0xf5d33d, "amarillo": 0xf5d33d,
+	"mustard": 0xd4a017, "mostaza": 0xd4a017,
+	"green": 0x2e8b57, "verde": 0x2e8b57,
+	"olive": 0x708238, "oliva": 0x708238,
+	"teal":   0x008080,
+	"purple": 0x7d3c98, "morado": 0x7d3c98, "violeta": 0x7d3c98,
+	"lilac": 0xc8a2c8, "lila": 0xc8a2c8,
+	"gold": 0xd4af37, "dorado": 0xd4af37,
+}
+
+// hsl is a color as hue (degrees), saturation and lightness (0 to 1).
+type hsl struct {
+	h, s, l float64
+}
+
+// parseColor reads a hex color (#rgb or #rrggbb) or a known color name.
+func parseColor(color string) (hsl, bool) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if rgb, ok := colorNames[color]; ok {
+		return rgbToHSL(rgb), true
+	}
+
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return hsl{}, false
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return hsl{}, false
+	}
+	return rgbToHSL(uint32(rgb)), true
+}
+
+func rgbToHSL(rgb uint32) hsl {
+	r := float64(rgb>>16&0xff) / 255
+	g := float64(rgb>>8&0xff) / 255
+	b := float64(rgb&0xff) / 255
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	c := hsl{l: (max + min) / 2}
+	if max == min {
+		return c
+	}
+
+	d := max - min
+	if c.l > 0.5 {
+		c.s = d / (2 - max - min)
+	} else {
+		c.s = d / (max + min)
+	}
+	switch max {
+	case r:
+		c.h = math.Mod((g-b)/d+6, 6)
+	case g:
+		c.h = (b-r)/d + 2
+	default:
+		c.h = (r-g)/d + 4
+	}
+	c.h *= 60
+	return c
+}
+
+// neutral colors go with anything: greys, near black and near white,
+// beiges and browns, and navy.
+func (c hsl) neutral() bool {
+	switch {
+	case c.s < 0.15, c.l < 0.12, c.l > 0.92:
+		return true
+	case c.h >= 20 && c.h <= 50 && c.s < 0.45:
+		return true
+	case c.h >= 200 && c.h <= 240 && c.l < 0.3:
+		return true
+	}
+	return false
+}
+
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// paletteColor is a parsed garment color, ok is false when it couldn't be
+// read.
+type paletteColor struct {
+	hsl
+	ok bool
+}
+
+func newPaletteColor(color string) paletteColor {
+	c, ok := parseColor(color)
+	return paletteColor{hsl: c, ok: ok}
+}
+
+// compatible is the color rule of capsules: two garments go together when
+// one of them is neutral or their hues are analogous (up to 40 degrees apart)
+// or complementary (180 degrees apart, give or take 30). Colors that can't be
+// read count as neutral, so they never rule a garment out.
+func (c paletteColor) compatible(other paletteColor) bool {
+	if !c.ok || c.neutral() || !other.ok || other.neutral() {
+		return true
+	}
+
+	d := hueDistance(c.h, other.h)
+	return d <= 40 || d >= 150
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+type DuplicateService struct {
+	repository models.DuplicateRepository
+	storage    ObjectStorage
+}
+
+func (s *DuplicateService) FindDuplicates(ctx context.Context, garment *models.Garment) ([]*models.GarmentDuplicate, error) {
+	duplicates := []*models.GarmentDuplicate{}
+	if garment.ImageHash == nil {
+		return duplicates, nil
+	}
+
+	garments, err := s.repository.ListHashedGarments(ctx, garment.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, other := range garments {
+		if other.ID == garment.ID {
+			continue
+		}
+
+		distance := HammingDistance(uint64(*garment.ImageHash), uint64(*other.ImageHash))
+		if distance <= DuplicateHashDistance {
+			duplicates = append(duplicates, &models.GarmentDuplicate{Garment: other, Distance: distance})
+		}
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return duplicates[i].Distance < duplicates[j].Distance
+	})
+
+	return duplicates, nil
+}
+
+// ListClusters links every pair of garments within maxDistance and returns
+// the groups of more than one garment, biggest first.
+func (s *DuplicateService) ListClusters(ctx context.Context, userID uuid.UUID, maxDistance int) ([]*models.DuplicateCluster, error) {
+	garments, err := s.repository.ListHashedGarments(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Union-find over the indexes of garments
+	parent := make([]int, len(garments))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range garments {
+		for j := i + 1; j < len(garments); j++ {
+			distance := HammingDistance(uint64(*garments[i].ImageHash), uint64(*garments[j].ImageHash))
+			if distance > maxDistance {
+				continue
+			}
+
+			if ri, rj := find(i), find(j); ri != rj {
+				parent[rj] = ri
+			}
+		}
+	}
+
+	groups := map[int]*models.DuplicateCluster{}
+	roots := []int{}
+	for i, garment := range garments {
+		root := find(i)
+		cluster, ok := groups[root]
+		if !ok {
+			cluster = &models.DuplicateCluster{}
+			groups[root] = cluster
+			roots = append(roots, root)
+		}
+		cluster.Garments = append(cluster.Garments, garment)
+	}
+
+	clusters := []*models.DuplicateCluster{}
+	for _, root := range roots {
+		if cluster := groups[root]; len(cluster.Garments) > 1 {
+			cluster.MaxDistance = maxPairDistance(cluster.Garments)
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return len(clusters[i].Garments) > len(clusters[j].Garments)
+	})
+
+	return clusters, nil
+}
+
+// maxPairDistance is the distance between the two least alike garments of a
+// cluster, chains of similar photos can be further apart than the threshold.
+func maxPairDistance(garments []*models.Garment) int {
+	distance := 0
+	for i := range garments {
+		for j := i + 1; j < len(garments); j++ {
+			distance = max(distance, HammingDistance(uint64(*garments[i].ImageHash), uint64(*garments[j].ImageHash)))
+		}
+	}
+	return distance
+}
+
+// BackfillHashes downloads the photos without hash from our storage. Photos
+// elsewhere (e.g. barcode lookups) or that fail to decode are skipped.
+func (s *DuplicateService) BackfillHashes(ctx context.Context) (int, error) {
+	hashed := 0
+	after := uuid.Nil
+
+	for {
+		garments, err := s.repository.ListGarmentsWithoutHash(ctx, after, 100)
+		if err != nil {
+			return hashed, err
+		}
+		if len(garments) == 0 {
+			return hashed, nil
+		}
+
+		for _, garment := range garments {
+			after = garment.ID
+
+			key, ok := s.storage.KeyFromURL(garment.ImageURL)
+			if !ok {
+				continue
+			}
+
+			data, err := s.storage.Get(ctx, key)
+			if err != nil {
+				log.Warnf("hash backfill: garment %s: %v", garment.ID, err)
+				continue
+			}
+
+			img, err := DecodeOrientedImage(data)
+			if err != nil {
+				log.Warnf("hash backfill: garment %s: %v", garment.ID, err)
+				continue
+			}
+
+			if err := s.repository.SetImageHash(ctx, garment.ID, int64(DifferenceHash(img))); err != nil {
+				return hashed, err
+			}
+			hashed++
+		}
+	}
+}
+
+func NewDuplicateService(repository models.DuplicateRepository, storage ObjectStorage) models.DuplicateService {
+	return &DuplicateService{
+		repository: repository,
+		storage:    storage,
+	}
+}
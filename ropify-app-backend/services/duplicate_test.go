@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+type fakeDuplicateRepository struct {
+	models.DuplicateRepository
+
+	garments []*models.Garment
+}
+
+func (r *fakeDuplicateRepository) ListHashedGarments(ctx context.Context, userID uuid.UUID) ([]*models.Garment, error) {
+	return append([]*models.Garment{}, r.garments...), nil
+}
+
+// hashedGarment has a hash whose lowest bits are set, so the distance
+// between two of them is the difference of their bits.
+func hashedGarment(bits int) *models.Garment {
+	hash := int64(uint64(1)<<bits - 1)
+	return &models.Garment{ID: uuid.New(), ImageHash: &hash}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0b1011, 0b1011, 0},
+		{0b1011, 0b0010, 2},
+		{0, ^uint64(0), 64},
+	}
+
+	for _, tt := range tests {
+		if got := HammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance(%b, %b) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindDuplicatesThreshold(t *testing.T) {
+	garment := hashedGarment(0)
+	near := hashedGarment(3)
+	limit := hashedGarment(DuplicateHashDistance)
+	far := hashedGarment(DuplicateHashDistance + 1)
+
+	service := NewDuplicateService(&fakeDuplicateRepository{garments: []*models.Garment{limit, garment, far, near}}, nil)
+
+	duplicates, err := service.FindDuplicates(context.Background(), garment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*models.Garment{near, limit}
+	if len(duplicates) != len(want) {
+		t.Fatalf("found %d duplicates, want %d", len(duplicates), len(want))
+	}
+	for i, duplicate := range duplicates {
+		if duplicate.Garment != want[i] {
+			t.Errorf("duplicate %d is not the expected garment, closest must go first", i)
+		}
+	}
+	if duplicates[1].Distance != DuplicateHashDistance {
+		t.Errorf("distance = %d, want %d", duplicates[1].Distance, DuplicateHashDistance)
+	}
+}
+
+func TestFindDuplicatesWithoutHash(t *testing.T) {
+	service := NewDuplicateService(&fakeDuplicateRepository{garments: []*models.Garment{hashedGarment(0)}}, nil)
+
+	duplicates, err := service.FindDuplicates(context.Background(), &models.Garment{ID: uuid.New()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates) != 0 {
+		t.Errorf("a garment without hash has %d duplicates", len(duplicates))
+	}
+}
+
+func TestListClusters(t *testing.T) {
+	// a-b and b-c are within the distance, a-c only through b
+	a, b, c := hashedGarment(0), hashedGarment(6), hashedGarment(12)
+	d, e := hashedGarment(40), hashedGarment(42)
+	alone := hashedGarment(60)
+
+	service := NewDuplicateService(&fakeDuplicateRepository{garments: []*models.Garment{d, a, alone, b, e, c}}, nil)
+
+	clusters, err := service.ListClusters(context.Background(), uuid.New(), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2", len(clusters))
+	}
+	if len(clusters[0].Garments) != 3 || len(clusters[1].Garments) != 2 {
+		t.Fatalf("cluster sizes = %d, %d, want 3, 2 biggest first", len(clusters[0].Garments), len(clusters[1].Garments))
+	}
+	if clusters[0].MaxDistance != 12 {
+		t.Errorf("max distance of the chain = %d, want 12", clusters[0].MaxDistance)
+	}
+	if clusters[1].MaxDistance != 2 {
+		t.Errorf("max distance = %d, want 2", clusters[1].MaxDistance)
+	}
+	for _, cluster := range clusters {
+		for _, garment := range cluster.Garments {
+			if garment == alone {
+				t.Error("a garment without duplicates was clustered")
+			}
+		}
+	}
+}
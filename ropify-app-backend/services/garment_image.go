@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+// ImageRendition is a scaled copy of a garment photo.
+type ImageRendition struct {
+	Name    string
+	MaxSide int
+}
+
+// GarmentRenditions are generated for every garment photo, from the one the
+// closet grid shows to the one of the detail view.
+var GarmentRenditions = []ImageRendition{
+	{Name: "thumb", MaxSide: 256},
+	{Name: "medium", MaxSide: 800},
+	{Name: "full", MaxSide: 2048},
+}
+
+// ProcessedImage is an encoded rendition ready to be stored.
+type ProcessedImage struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+// ProcessGarmentImage rejects anything that is not an image, applies the EXIF
+// orientation and encodes every rendition without metadata. Photos with
+// transparency (background removed) are kept as PNG, the rest become JPEG.
+// It also returns the perceptual hash of the photo.
+func ProcessGarmentImage(data []byte) ([]ProcessedImage, uint64, error) {
+	img, err := DecodeOrientedImage(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	transparent := HasTransparency(img)
+
+	renditions := make([]ProcessedImage, 0, len(GarmentRenditions))
+	for _, rendition := range GarmentRenditions {
+		processed, err := encodeRendition(FitWithin(img, rendition.MaxSide), transparent)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to encode %s rendition: %v", rendition.Name, err)
+		}
+		processed.Name = rendition.Name
+		renditions = append(renditions, processed)
+	}
+
+	return renditions, DifferenceHash(img), nil
+}
+
+// NormalizeImage returns the upright photo without metadata at full size,
+// for the services that analyze the original.
+func NormalizeImage(data []byte) ([]byte, error) {
+	img, err := DecodeOrientedImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := encodeRendition(img, HasTransparency(img))
+	if err != nil {
+		return nil, err
+	}
+	return processed.Data, nil
+}
+
+func encodeRendition(img image.Image, transparent bool) (ProcessedImage, error) {
+	if transparent {
+		data, err := EncodePNG(img)
+		return ProcessedImage{Data: data, ContentType: "image/png", Extension: "png"}, err
+	}
+
+	data, err := EncodeJPEG(img, 85)
+	return ProcessedImage{Data: data, ContentType: "image/jpeg", Extension: "jpg"}, err
+}
+
+type GarmentImageService struct {
+	storage ObjectStorage
+}
+
+// StoreGarmentImage uploads the renditions under the garments prefix of the
+// user, sharing one id so they can be told apart from other photos.
+func (s *GarmentImageService) StoreGarmentImage(ctx context.Context, userID uuid.UUID, data []byte) (*models.GarmentImages, error) {
+	renditions, hash, err := ProcessGarmentImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.NewString()
+	urls := map[string]string{}
+
+	for _, rendition := range renditions {
+		key := fmt.Sprintf("garments/users/%s/%s-%s.%s", userID, id, rendition.Name, rendition.Extension)
+
+		url, err := s.storage.Put(ctx, key, rendition.Data, rendition.ContentType)
+		if err != nil {
+			return nil, err
+		}
+		urls[rendition.Name] = url
+	}
+
+	imageHash := int64(hash)
+
+	return &models.GarmentImages{
+		ThumbnailURL: urls["thumb"],
+		MediumURL:    urls["medium"],
+		ImageURL:     urls["full"],
+		ImageHash:    &imageHash,
+	}, nil
+}
+
+func NewGarmentImageService(storage ObjectStorage) models.GarmentImageService {
+	return &GarmentImageService{
+		storage: storage,
+	}
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportRows = 1000
+	// Remote photos downloaded at once
+	importWorkers = 4
+)
+
+type GarmentImportService struct {
+	garments models.GarmentRepository
+	images   models.GarmentImageService
+	fetcher  *RemoteImageFetcher
+}
+
+func (s *GarmentImportService) Import(ctx context.Context, userID uuid.UUID, format string, file io.Reader, dryRun bool) (*models.GarmentImportReport, error) {
+	rows, err := parseImport(format, file)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.GarmentImportReport{
+		DryRun: dryRun,
+		Total:  len(rows),
+		Rows:   make([]*models.GarmentImportResult, len(rows)),
+	}
+
+	seen := map[string]int{}
+	externalIDs := []string{}
+	for i, row := range rows {
+		result := validateImportRow(i+1, row)
+		if result.ExternalID != "" {
+			if first, ok := seen[result.ExternalID]; ok {
+				result.Errors = append(result.Errors, fmt.Sprintf("external_id repeated, first used in row %d", first))
+			} else {
+				seen[result.ExternalID] = i + 1
+				externalIDs = append(externalIDs, result.ExternalID)
+			}
+		}
+		report.Rows[i] = result
+	}
+
+	existing, err := s.garments.FindByExternalIDs(ctx, userID, externalIDs)
+	if err != nil {
+		return nil, err
+	}
+	byExternalID := make(map[string]*models.Garment, len(existing))
+	for _, garment := range existing {
+		byExternalID[*garment.ExternalID] = garment
+	}
+
+	for _, result := range report.Rows {
+		if len(result.Errors) > 0 {
+			result.Action = models.ImportError
+			continue
+		}
+		result.Action = models.ImportCreate
+		if garment, ok := byExternalID[result.ExternalID]; ok {
+			result.Action = models.ImportUpdate
+			result.GarmentID = &garment.ID
+		}
+	}
+
+	if !dryRun {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, importWorkers)
+		for i, result := range report.Rows {
+			if result.Action == models.ImportError {
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(row models.GarmentImportRow, result *models.GarmentImportResult) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s.importRow(ctx, userID, row, result, byExternalID[result.ExternalID])
+			}(rows[i], result)
+		}
+		wg.Wait()
+	}
+
+	for _, result := range report.Rows {
+		switch result.Action {
+		case models.ImportCreate:
+			report.Created++
+		case models.ImportUpdate:
+			report.Updated++
+		default:
+			report.Failed++
+		}
+	}
+
+	return report, nil
+}
+
+// importRow creates or updates the garment of a valid row. Errors are written
+// on the result.
+func (s *GarmentImportService) importRow(ctx context.Context, userID uuid.UUID, row models.GarmentImportRow, result *models.GarmentImportResult, existing *models.Garment) {
+	fail := func(err error) {
+		result.Action = models.ImportError
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	var externalID *string
+	if result.ExternalID != "" {
+		externalID = &result.ExternalID
+	}
+
+	// The photo is only fetched when the garment has none yet, so re-running
+	// an import does not download everything again
+	var images *models.GarmentImages
+	imageURL := strings.TrimSpace(row.ImageURL)
+	if imageURL != "" && (existing == nil || existing.ImageURL == "") {
+		var err error
+		if images, err = s.fetchImage(ctx, userID, imageURL); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("image not imported: %v", err))
+		}
+	}
+
+	if existing != nil {
+		_, err := s.garments.UpdateGarment(ctx, existing.ID, map[string]interface{}{
+			"category": result.Category,
+			"color":    strings.TrimSpace(row.Color),
+			"labels":   models.StringArray(cleanLabels(row.Labels)),
+			"brand":    strings.TrimSpace(row.Brand),
+		})
+		if err != nil {
+			fail(err)
+			return
+		}
+		if images != nil {
+			if err := s.garments.UpdateGarmentImage(userID, images, existing.ID); err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("image not imported: %v", err))
+			}
+		}
+		return
+	}
+
+	garment := &models.Garment{
+		UserID:     userID,
+		Category:   result.Category,
+		Color:      strings.TrimSpace(row.Color),
+		Labels:     cleanLabels(row.Labels),
+		Brand:      strings.TrimSpace(row.Brand),
+		ExternalID: externalID,
+	}
+	if images != nil {
+		garment.SetImages(images)
+	}
+
+	created, err := s.garments.AddGarment(ctx, garment)
+	if err != nil {
+		fail(err)
+		return
+	}
+	result.GarmentID = &created.ID
+}
+
+func (s *GarmentImportService) fetchImage(ctx context.Context, userID uuid.UUID, imageURL string) (*models.GarmentImages, error) {
+	data, err := s.fetcher.Fetch(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.images.StoreGarmentImage(ctx, userID, data)
+}
+
+func validateImportRow(number int, row models.GarmentImportRow) *models.GarmentImportResult {
+	result := &models.GarmentImportResult{
+		Row:        number,
+		ExternalID: strings.TrimSpace(row.ExternalID),
+	}
+
+	if strings.TrimSpace(row.Color) == "" {
+		result.Errors = append(result.Errors, "color is required")
+	}
+
+	category, ok := NormalizeCategory(row.Category)
+	if !ok {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("unknown category %q, imported as %q", row.Category, models.Unknown))
+	}
+	result.Category = category
+
+	if imageURL := strings.TrimSpace(row.ImageURL); imageURL != "" {
+		u, err := url.Parse(imageURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			result.Errors = append(result.Errors, "image_url must be an http or https URL")
+		}
+	}
+
+	return result
+}
+
+func cleanLabels(labels []string) []string {
+	cleaned := []string{}
+	for _, label := range labels {
+		if label = strings.TrimSpace(label); label != "" {
+			cleaned = append(cleaned, label)
+		}
+	}
+	return cleaned
+}
+
+func parseImport(format string, file io.Reader) ([]models.GarmentImportRow, error) {
+	var rows []models.GarmentImportRow
+	var err error
+
+	switch format {
+	case "csv":
+		rows, err = parseImportCSV(file)
+	case "json":
+		rows, err = parseImportJSON(file)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", models.ErrInvalidImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no garments found", models.ErrInvalidImport)
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("%w: at most %d garments per import", models.ErrInvalidImport, maxImportRows)
+	}
+
+	return rows, nil
+}
+
+func parseImportJSON(file io.Reader) ([]models.GarmentImportRow, error) {
+	var rows []models.GarmentImportRow
+	if err := json.NewDecoder(file).Decode(&rows); err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidImport, err)
+	}
+	return rows, nil
+}
+
+// parseImportCSV reads a CSV with a header row. Labels are separated by ";"
+// or "|" and unknown columns are ignored.
+func parseImportCSV(file io.Reader) ([]models.GarmentImportRow, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidImport, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	if _, ok := columns["color"]; !ok {
+		return nil, fmt.Errorf("%w: missing color column", models.ErrInvalidImport)
+	}
+
+	rows := []models.GarmentImportRow{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidImport, err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("%w: at most %d garments per import", models.ErrInvalidImport, maxImportRows)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		rows = append(rows, models.GarmentImportRow{
+			ExternalID: field("external_id"),
+			Category:   field("category"),
+			Color:      field("color"),
+			Labels: strings.FieldsFunc(field("labels"), func(r rune) bool {
+				return r == ';' || r == '|'
+			}),
+			Brand:    field("brand"),
+			ImageURL: field("image_url"),
+		})
+	}
+
+	return rows, nil
+}
+
+func NewGarmentImportService(garments models.GarmentRepository, images models.GarmentImageService, fetcher *RemoteImageFetcher) models.GarmentImportService {
+	return &GarmentImportService{
+		garments: garments,
+		images:   images,
+		fetcher:  fetcher,
+	}
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	GoogleJWKSURL      = "https://www.googleapis.com/oauth2/v3/certs"
+	GoogleTokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+)
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+var ErrInvalidIDToken = errors.New("invalid google id token")
+
+// GoogleIDTokenClaims are the claims Google puts in the ID tokens it issues.
+type GoogleIDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// UserInfo maps the claims to the provider-agnostic profile.
+func (c *GoogleIDTokenClaims) UserInfo() *OAuthUserInfo {
+	return &OAuthUserInfo{
+		ID:            c.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified,
+		Name:          c.Name,
+		GivenName:     c.GivenName,
+		FamilyName:    c.FamilyName,
+		Picture:       c.Picture,
+	}
+}
+
+// GoogleIDTokenVerifier checks Google ID tokens locally against the Google
+// signing keys, which are fetched from the JWKS endpoint and cached for as
+// long as its Cache-Control header allows.
+type GoogleIDTokenVerifier struct {
+	jwksURL      string
+	tokenInfoURL string
+	audiences    []string
+	httpClient   *http.Client
+	now          func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	expiresAt   time.Time
+	refreshedAt time.Time
+}
+
+// Verify parses the token, checks its signature, issuer, audience and expiry
+// and returns its claims.
+func (v *GoogleIDTokenVerifier) Verify(ctx context.Context, rawToken string) (*GoogleIDTokenClaims, error) {
+	claims := &GoogleIDTokenClaims{}
+
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(v.now),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if !containsString(googleIssuers, claims.Issuer) {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidIDToken, claims.Issuer)
+	}
+
+	validAudience := false
+	for _, aud := range claims.Audience {
+		if containsString(v.audiences, aud) {
+			validAudience = true
+			break
+		}
+	}
+	if !validAudience {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// VerifyAccessToken asks Google who an access token was issued to, and
+// returns its subject only if it was issued to one of our client IDs. Access
+// tokens can't be verified locally, and any app can get one for its users.
+func (v *GoogleIDTokenVerifier) VerifyAccessToken(ctx context.Context, accessToken string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.tokenInfoURL+"?"+url.Values{"access_token": {accessToken}}.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch google token info: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Google answers 400 to expired or made up tokens
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: access token rejected with status %d", ErrInvalidIDToken, resp.StatusCode)
+	}
+
+	var info struct {
+		Aud string `json:"aud"`
+		Azp string `json:"azp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to decode google token info: %v", err)
+	}
+
+	if !containsString(v.audiences, info.Aud) && !containsString(v.audiences, info.Azp) {
+		return "", fmt.Errorf("%w: access token issued to another client", ErrInvalidIDToken)
+	}
+	if info.Sub == "" {
+		return "", fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return info.Sub, nil
+}
+
+// key returns the public key with the given id, refreshing the cache when it
+// has expired or the key is unknown (Google rotates its keys).
+func (v *GoogleIDTokenVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	fresh := v.now().Before(v.expiresAt)
+	recent := v.now().Sub(v.refreshedAt) < time.Minute
+	v.mu.RUnlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	// Do not let tokens with made up key ids hammer the JWKS endpoint
+	if !ok && fresh && recent {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	key, ok = v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (v *GoogleIDTokenVerifier) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch google keys: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch google keys: status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("failed to decode google keys: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+
+		key, err := parseRSAPublicKey(jwk.N, jwk.E)
+		if err != nil {
+			return err
+		}
+		keys[jwk.Kid] = key
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = keys
+	v.refreshedAt = v.now()
+	v.expiresAt = v.now().Add(cacheMaxAge(resp.Header.Get("Cache-Control"), time.Hour))
+
+	return nil
+}
+
+func parseRSAPublicKey(n, e string) (*rsa.PublicKey, error) {
+	nBytes, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key modulus: %v", err)
+	}
+
+	eBytes, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key exponent: %v", err)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(nBytes),
+		E: int(new(big.Int).SetBytes(eBytes).Int64()),
+	}, nil
+}
+
+// cacheMaxAge reads max-age from a Cache-Control header.
+func cacheMaxAge(header string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if !found || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return fallback
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NewGoogleIDTokenVerifier builds a verifier that accepts tokens issued to any
+// of the given client IDs. Empty URLs use the public Google endpoints.
+func NewGoogleIDTokenVerifier(audiences []string, jwksURL, tokenInfoURL string, client *http.Client) *GoogleIDTokenVerifier {
+	if jwksURL == "" {
+		jwksURL = GoogleJWKSURL
+	}
+	if tokenInfoURL == "" {
+		tokenInfoURL = GoogleTokenInfoURL
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	// An unset client ID must never match a token without audience
+	validAudiences := []string{}
+	for _, aud := range audiences {
+		if aud = strings.TrimSpace(aud); aud != "" {
+			validAudiences = append(validAudiences, aud)
+		}
+	}
+
+	return &GoogleIDTokenVerifier{
+		jwksURL:      jwksURL,
+		tokenInfoURL: tokenInfoURL,
+		audiences:    validAudiences,
+		httpClient:   client,
+		now:          time.Now,
+		keys:         map[string]*rsa.PublicKey{},
+	}
+}
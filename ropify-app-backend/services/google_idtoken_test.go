@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testGoogleClientID = "web-client.apps.googleusercontent.com"
+
+// fakeJWKS serves the public keys it holds and counts the requests.
+type fakeJWKS struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetches atomic.Int32
+}
+
+func newFakeJWKS(t *testing.T, keys map[string]*rsa.PublicKey) *fakeJWKS {
+	t.Helper()
+
+	fake := &fakeJWKS{keys: keys}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.fetches.Add(1)
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+
+		jwks := map[string][]map[string]string{"keys": {}}
+		for kid, key := range fake.keys {
+			jwks["keys"] = append(jwks["keys"], map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		w.Header().Set("Cache-Control", "public, max-age=3600, must-revalidate")
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeJWKS) setKeys(keys map[string]*rsa.PublicKey) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = keys
+}
+
+func generateTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+	return key
+}
+
+func googleTestClaims(now time.Time) *GoogleIDTokenClaims {
+	return &GoogleIDTokenClaims{
+		Email:         "ana@example.com",
+		EmailVerified: true,
+		Name:          "Ana Pérez",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://accounts.google.com",
+			Subject:   "g-1",
+			Audience:  jwt.ClaimStrings{testGoogleClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+// testClock is a time that tests can move forward.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestVerifier(jwksURL string, clock *testClock) *GoogleIDTokenVerifier {
+	verifier := NewGoogleIDTokenVerifier([]string{testGoogleClientID, ""}, jwksURL, "", nil)
+	verifier.now = clock.Now
+	return verifier
+}
+
+func TestGoogleIDTokenVerify(t *testing.T) {
+	key := generateTestKey(t)
+	otherKey := generateTestKey(t)
+	jwks := newFakeJWKS(t, map[string]*rsa.PublicKey{"key-1": &key.PublicKey})
+	clock := &testClock{now: time.Now()}
+
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			token: func() string { return signTestToken(t, key, "key-1", googleTestClaims(clock.Now())) },
+		},
+		{
+			name: "issuer without scheme",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.Issuer = "accounts.google.com"
+				return signTestToken(t, key, "key-1", claims)
+			},
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.Issuer = "https://evil.example.com"
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.Audience = jwt.ClaimStrings{"another-app.apps.googleusercontent.com"}
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty audience does not match an unset client ID",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.Audience = jwt.ClaimStrings{""}
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired token",
+			token: func() string {
+				claims := googleTestClaims(clock.Now().Add(-2 * time.Hour))
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing expiry",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.ExpiresAt = nil
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing subject",
+			token: func() string {
+				claims := googleTestClaims(clock.Now())
+				claims.Subject = ""
+				return signTestToken(t, key, "key-1", claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "signed by another key",
+			token: func() string {
+				return signTestToken(t, otherKey, "key-1", googleTestClaims(clock.Now()))
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown kid",
+			token: func() string {
+				return signTestToken(t, key, "key-9", googleTestClaims(clock.Now()))
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 signed with the public modulus",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, googleTestClaims(clock.Now()))
+				token.Header["kid"] = "key-1"
+				signed, err := token.SignedString(key.PublicKey.N.Bytes())
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return signed
+			},
+			wantErr: true,
+		},
+		{
+			name: "alg none",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, googleTestClaims(clock.Now()))
+				token.Header["kid"] = "key-1"
+				signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatalf("sign token: %v", err)
+				}
+				return signed
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := newTestVerifier(jwks.URL, clock)
+
+			claims, err := verifier.Verify(context.Background(), tt.token())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("Verify error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+
+			info := claims.UserInfo()
+			if info.ID != "g-1" || info.Email != "ana@example.com" || !info.EmailVerified {
+				t.Errorf("UserInfo = %+v", info)
+			}
+		})
+	}
+}
+
+func TestGoogleIDTokenJWKSCache(t *testing.T) {
+	key := generateTestKey(t)
+	jwks := newFakeJWKS(t, map[string]*rsa.PublicKey{"key-1": &key.PublicKey})
+	clock := &testClock{now: time.Now()}
+	verifier := newTestVerifier(jwks.URL, clock)
+
+	verify := func() {
+		t.Helper()
+		if _, err := verifier.Verify(context.Background(), signTestToken(t, key, "key-1", googleTestClaims(clock.Now()))); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+
+	verify()
+	verify()
+	if got := jwks.fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want the cached keys to be reused", got)
+	}
+
+	// max-age is one hour
+	clock.Advance(61 * time.Minute)
+	verify()
+	if got := jwks.fetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times after max-age, want a refresh", got)
+	}
+}
+
+func TestGoogleIDTokenKeyRotation(t *testing.T) {
+	oldKey := generateTestKey(t)
+	newKey := generateTestKey(t)
+	jwks := newFakeJWKS(t, map[string]*rsa.PublicKey{"key-1": &oldKey.PublicKey})
+	clock := &testClock{now: time.Now()}
+	verifier := newTestVerifier(jwks.URL, clock)
+
+	if _, err := verifier.Verify(context.Background(), signTestToken(t, oldKey, "key-1", googleTestClaims(clock.Now()))); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	jwks.setKeys(map[string]*rsa.PublicKey{"key-2": &newKey.PublicKey})
+	newToken := signTestToken(t, newKey, "key-2", googleTestClaims(clock.Now()))
+
+	// Unknown keys right after a refresh don't go to the endpoint again
+	if _, err := verifier.Verify(context.Background(), newToken); err == nil {
+		t.Fatal("Verify accepted a key unknown to the cache right after a refresh")
+	}
+	if got := jwks.fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want unknown kids rate limited", got)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := verifier.Verify(context.Background(), newToken); err != nil {
+		t.Fatalf("Verify with the rotated key: %v", err)
+	}
+	if got := jwks.fetches.Load(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want a refresh for the new kid", got)
+	}
+}
+
+func TestGoogleVerifyAccessToken(t *testing.T) {
+	tokenInfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responses := map[string]map[string]string{
+			"ours":       {"aud": testGoogleClientID, "azp": testGoogleClientID, "sub": "g-1", "expires_in": "3599"},
+			"ours-azp":   {"aud": "android-client", "azp": testGoogleClientID, "sub": "g-1", "expires_in": "3599"},
+			"other-app":  {"aud": "another-app.apps.googleusercontent.com", "azp": "another-app.apps.googleusercontent.com", "sub": "g-1"},
+			"no-subject": {"aud": testGoogleClientID, "azp": testGoogleClientID},
+		}
+		body, ok := responses[r.URL.Query().Get("access_token")]
+		if !ok {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+	defer tokenInfo.Close()
+
+	verifier := NewGoogleIDTokenVerifier([]string{testGoogleClientID}, "", tokenInfo.URL, nil)
+
+	tests := []struct {
+		token   string
+		wantErr bool
+	}{
+		{token: "ours"},
+		{token: "ours-azp"},
+		{token: "other-app", wantErr: true},
+		{token: "no-subject", wantErr: true},
+		{token: "expired", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			subject, err := verifier.VerifyAccessToken(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("VerifyAccessToken error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAccessToken: %v", err)
+			}
+			if subject != "g-1" {
+				t.Errorf("subject = %q, want g-1", subject)
+			}
+		})
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"public, max-age=19800, must-revalidate, no-transform", 19800 * time.Second},
+		{"MAX-AGE=60", time.Minute},
+		{"no-cache", time.Hour},
+		{"max-age=0", time.Hour},
+		{"max-age=soon", time.Hour},
+		{"", time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := cacheMaxAge(tt.header, time.Hour); got != tt.want {
+			t.Errorf("cacheMaxAge(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestHandleGoogleTokenChecksAccessTokenAudience(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	audience := testGoogleClientID
+	tokenInfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"aud": audience, "azp": audience, "sub": "g-1"})
+	}))
+	defer tokenInfo.Close()
+
+	verifier := NewGoogleIDTokenVerifier([]string{testGoogleClientID}, "", tokenInfo.URL, nil)
+	service := NewOAuthService(&fakeAuthRepository{}, &fakeIdentityRepository{}, &fakeUsernameService{}, verifier, fake.providers()...)
+
+	if _, _, err := service.HandleGoogleToken(context.Background(), "", "access-token"); err != nil {
+		t.Fatalf("HandleGoogleToken with our access token: %v", err)
+	}
+
+	audience = "another-app.apps.googleusercontent.com"
+	if _, _, err := service.HandleGoogleToken(context.Background(), "", "access-token"); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("HandleGoogleToken error = %v, want the token of another app rejected", err)
+	}
+}
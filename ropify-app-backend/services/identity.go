@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gaelzamora/ropify-app/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const linkTicketTTL = 10 * time.Minute
+
+// LinkRequiredError is returned when a social login matches the email of an
+// existing account. LinkToken must be sent back by the signed-in owner of
+// that account to attach the identity.
+type LinkRequiredError struct {
+	Provider  string
+	Email     string
+	LinkToken string
+}
+
+func (e *LinkRequiredError) Error() string {
+	return fmt.Sprintf("an account with the email %s already exists, sign in and confirm linking your %s account", e.Email, e.Provider)
+}
+
+// linkSecret is kept apart from the session secret so a ticket can never be
+// used as an access token.
+func linkSecret() []byte {
+	return []byte(os.Getenv("JWT_SECRET") + ":link")
+}
+
+// NewLinkTicket signs the provider identity that is waiting to be linked.
+func NewLinkTicket(provider string, userInfo *OAuthUserInfo) (string, error) {
+	claims := jwt.MapClaims{
+		"provider": provider,
+		"sub":      userInfo.ID,
+		"email":    userInfo.Email,
+		"exp":      time.Now().Add(linkTicketTTL).Unix(),
+	}
+
+	return utils.GenerateJWT(claims, jwt.SigningMethodHS256, string(linkSecret()))
+}
+
+func parseLinkTicket(ticket string) (*models.UserIdentity, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(ticket, claims, func(token *jwt.Token) (interface{}, error) {
+		return linkSecret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid or expired link token")
+	}
+
+	provider, _ := claims["provider"].(string)
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+
+	if provider == "" || subject == "" {
+		return nil, fmt.Errorf("invalid or expired link token")
+	}
+
+	return &models.UserIdentity{
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}, nil
+}
+
+type IdentityService struct {
+	repository models.IdentityRepository
+	users      models.AuthRepository
+}
+
+func (s *IdentityService) List(ctx context.Context, userID uuid.UUID) ([]*models.UserIdentity, error) {
+	return s.repository.ListIdentities(ctx, userID)
+}
+
+// Link attaches the identity of a link ticket to the signed-in user. Accounts
+// with a password must confirm it.
+func (s *IdentityService) Link(ctx context.Context, userID uuid.UUID, linkToken, password string) (*models.UserIdentity, error) {
+	identity, err := parseLinkTicket(linkToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.GetUser(ctx, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.HasPassword() && !models.MatchesHash(password, user.Password) {
+		return nil, fmt.Errorf("invalid password")
+	}
+
+	existing, err := s.repository.FindIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		if existing.UserID == userID {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("this %s account is already linked to another user", identity.Provider)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	identity.UserID = userID
+
+	return s.repository.AddIdentity(ctx, identity)
+}
+
+func (s *IdentityService) Unlink(ctx context.Context, userID, identityID uuid.UUID) error {
+	return s.repository.DeleteIdentity(ctx, userID, identityID)
+}
+
+func NewIdentityService(repository models.IdentityRepository, users models.AuthRepository) models.IdentityService {
+	return &IdentityService{
+		repository: repository,
+		users:      users,
+	}
+}
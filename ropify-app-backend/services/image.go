@@ -0,0 +1,261 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	AvatarSize = 512
+
+	// Anything bigger is rejected before decoding to avoid decompression bombs
+	maxImagePixels = 50_000_000
+)
+
+// ErrInvalidImage wraps every error caused by the uploaded data itself: not an
+// image, an unsupported type (whatever the file name says), corrupt or too big.
+var ErrInvalidImage = errors.New("invalid image")
+
+// SniffImageType detects the type of the image from its first bytes.
+func SniffImageType(data []byte) (string, error) {
+	switch contentType := http.DetectContentType(data); contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return contentType, nil
+	default:
+		return "", fmt.Errorf("%w: %s is not supported, use JPEG, PNG or GIF", ErrInvalidImage, contentType)
+	}
+}
+
+// DecodeImage decodes a JPEG, PNG or GIF image.
+func DecodeImage(data []byte) (image.Image, string, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: unsupported or corrupt image: %v", ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, "", fmt.Errorf("%w: image too large: %dx%d", ErrInvalidImage, config.Width, config.Height)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: unsupported or corrupt image: %v", ErrInvalidImage, err)
+	}
+	return img, format, nil
+}
+
+// DecodeOrientedImage decodes the image and applies its EXIF orientation,
+// so it is displayed upright once the metadata is dropped on encoding.
+func DecodeOrientedImage(data []byte) (image.Image, error) {
+	if _, err := SniffImageType(data); err != nil {
+		return nil, err
+	}
+
+	img, format, err := DecodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	return img, nil
+}
+
+// CropSquare returns the centered square of the image.
+func CropSquare(img image.Image) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, image.Pt(x0, y0), draw.Src)
+
+	return square
+}
+
+// Resize scales the image to width x height with bilinear interpolation.
+func Resize(img image.Image, width, height int) *image.RGBA {
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	if src.Bounds().Empty() || width <= 0 || height <= 0 {
+		return dst
+	}
+
+	// Bilinear only looks at 4 pixels, big reductions are first halved with
+	// a box filter so they do not alias
+	for src.Bounds().Dx() >= 2*width && src.Bounds().Dy() >= 2*height {
+		src = halve(src)
+	}
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	xRatio := float64(sw) / float64(width)
+	yRatio := float64(sh) / float64(height)
+
+	for y := 0; y < height; y++ {
+		sy := (float64(y)+0.5)*yRatio - 0.5
+		y0, fy := clampIndex(sy, sh)
+		y1 := min(y0+1, sh-1)
+
+		for x := 0; x < width; x++ {
+			sx := (float64(x)+0.5)*xRatio - 0.5
+			x0, fx := clampIndex(sx, sw)
+			x1 := min(x0+1, sw-1)
+
+			c00 := src.RGBAAt(x0, y0)
+			c10 := src.RGBAAt(x1, y0)
+			c01 := src.RGBAAt(x0, y1)
+			c11 := src.RGBAAt(x1, y1)
+
+			dst.SetRGBA(x, y, color.RGBA{
+				R: bilinear(c00.R, c10.R, c01.R, c11.R, fx, fy),
+				G: bilinear(c00.G, c10.G, c01.G, c11.G, fx, fy),
+				B: bilinear(c00.B, c10.B, c01.B, c11.B, fx, fy),
+				A: bilinear(c00.A, c10.A, c01.A, c11.A, fx, fy),
+			})
+		}
+	}
+
+	return dst
+}
+
+// FitWithin scales the image down so its longest side is at most maxSide,
+// smaller images are returned as they are.
+func FitWithin(img image.Image, maxSide int) image.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		return Resize(img, maxSide, max(1, h*maxSide/w))
+	}
+	return Resize(img, max(1, w*maxSide/h), maxSide)
+}
+
+// HasTransparency reports whether any pixel is not fully opaque, e.g. the
+// garments returned by RemoveBackground.
+func HasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xFFFF {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG encodes the image as JPEG, flattening transparency on white.
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ProcessAvatar crops the image to a square and scales it to AvatarSize.
+func ProcessAvatar(data []byte) ([]byte, error) {
+	img, err := DecodeOrientedImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	square := CropSquare(img)
+	side := square.Bounds().Dx()
+	if side > AvatarSize {
+		side = AvatarSize
+	}
+
+	return EncodeJPEG(Resize(square, side, side), 85)
+}
+
+// flatten draws the image over a white background.
+func flatten(img image.Image) *image.RGBA {
+	flat := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// halve averages every 2x2 block of pixels.
+func halve(src *image.RGBA) *image.RGBA {
+	w, h := src.Bounds().Dx()/2, src.Bounds().Dy()/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, b, a int
+			for _, c := range []color.RGBA{
+				src.RGBAAt(2*x, 2*y), src.RGBAAt(2*x+1, 2*y),
+				src.RGBAAt(2*x, 2*y+1), src.RGBAAt(2*x+1, 2*y+1),
+			} {
+				r += int(c.R)
+				g += int(c.G)
+				b += int(c.B)
+				a += int(c.A)
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8((r + 2) / 4), G: uint8((g + 2) / 4), B: uint8((b + 2) / 4), A: uint8((a + 2) / 4)})
+		}
+	}
+
+	return dst
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	return rgba
+}
+
+func clampIndex(v float64, size int) (int, float64) {
+	if v < 0 {
+		return 0, 0
+	}
+	i := int(v)
+	if i >= size-1 {
+		return size - 1, 0
+	}
+	return i, v - float64(i)
+}
+
+func bilinear(c00, c10, c01, c11 uint8, fx, fy float64) uint8 {
+	top := float64(c00)*(1-fx) + float64(c10)*fx
+	bottom := float64(c01)*(1-fx) + float64(c11)*fx
+	return uint8(top*(1-fy) + bottom*fy + 0.5)
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// maxReportedKeys bounds the keys listed in a report.
+const maxReportedKeys = 1000
+
+// ImageGC deletes the stored images nothing points to anymore: photos of
+// deleted garments, replaced images and uploads whose garment insert failed.
+type ImageGC struct {
+	references models.ImageReferenceRepository
+	storage    ObjectStorage
+	grace      time.Duration
+
+	// Only one run at a time per process
+	running sync.Mutex
+
+	mu      sync.Mutex
+	metrics models.ImageGCMetrics
+}
+
+// Run lists the managed prefixes and deletes the unreferenced objects older
+// than the grace window. In dry run mode nothing is deleted, the report lists
+// what would be.
+func (gc *ImageGC) Run(ctx context.Context, dryRun bool) (*models.ImageGCReport, error) {
+	gc.running.Lock()
+	defer gc.running.Unlock()
+
+	report := &models.ImageGCReport{DryRun: dryRun, StartedAt: time.Now()}
+
+	err := gc.collect(ctx, report)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	gc.record(report)
+
+	return report, err
+}
+
+func (gc *ImageGC) collect(ctx context.Context, report *models.ImageGCReport) error {
+	// References are loaded before listing: an object uploaded meanwhile is
+	// newer than the grace window and is kept anyway
+	referenced, err := gc.referencedKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	cutoff := report.StartedAt.Add(-gc.grace)
+	orphans := []StoredObject{}
+
+	for _, prefix := range ManagedStoragePrefixes {
+		objects, err := gc.storage.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			report.Scanned++
+
+			switch {
+			case referenced[object.Key]:
+				report.Referenced++
+			case object.LastModified.After(cutoff):
+				report.Recent++
+			default:
+				report.Orphaned++
+				report.Bytes += object.Size
+				orphans = append(orphans, object)
+			}
+		}
+	}
+
+	for i := 0; i < len(orphans) && i < maxReportedKeys; i++ {
+		report.Keys = append(report.Keys, orphans[i].Key)
+	}
+
+	if report.DryRun {
+		return nil
+	}
+
+	// Delete in batches so a failure still reports what was removed
+	for start := 0; start < len(orphans); start += 1000 {
+		batch := orphans[start:min(start+1000, len(orphans))]
+
+		keys := make([]string, 0, len(batch))
+		for _, object := range batch {
+			keys = append(keys, object.Key)
+		}
+		if err := gc.storage.Delete(ctx, keys...); err != nil {
+			return err
+		}
+
+		report.Deleted += len(batch)
+		for _, object := range batch {
+			report.BytesDeleted += object.Size
+		}
+	}
+
+	return nil
+}
+
+func (gc *ImageGC) referencedKeys(ctx context.Context) (map[string]bool, error) {
+	urls, err := gc.references.ReferencedImageURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uploads, err := gc.references.PendingUploadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(urls)+len(uploads))
+	for _, url := range urls {
+		// External URLs (e.g. barcode lookups) are not in our storage
+		if key, ok := gc.storage.KeyFromURL(url); ok {
+			keys[key] = true
+		}
+	}
+	for _, key := range uploads {
+		keys[key] = true
+	}
+
+	return keys, nil
+}
+
+func (gc *ImageGC) record(report *models.ImageGCReport) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	finished := report.FinishedAt
+	gc.metrics.Runs++
+	if report.Error != "" {
+		gc.metrics.Failures++
+	}
+	gc.metrics.Deleted += int64(report.Deleted)
+	gc.metrics.BytesFreed += report.BytesDeleted
+	gc.metrics.LastRunAt = &finished
+	gc.metrics.LastDuration = report.FinishedAt.Sub(report.StartedAt)
+	gc.metrics.LastReport = report
+
+	log.Infof("image gc: scanned=%d referenced=%d recent=%d orphaned=%d deleted=%d bytes=%d dry_run=%t",
+		report.Scanned, report.Referenced, report.Recent, report.Orphaned, report.Deleted, report.Bytes, report.DryRun)
+}
+
+func (gc *ImageGC) Metrics() models.ImageGCMetrics {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.metrics
+}
+
+func RunImageGC(ctx context.Context, gc models.ImageGCService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := gc.Run(ctx, false); err != nil {
+			log.Errorf("image gc: %v", err)
+		}
+	}
+}
+
+func NewImageGC(references models.ImageReferenceRepository, storage ObjectStorage, grace time.Duration) models.ImageGCService {
+	return &ImageGC{
+		references: references,
+		storage:    storage,
+		grace:      grace,
+	}
+}
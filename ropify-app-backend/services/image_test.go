@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+// exifSegment builds an APP1 segment with an IFD0 holding only the
+// orientation tag.
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegment inserts a segment right after the SOI marker of a JPEG.
+func withSegment(jpegData, segment []byte) []byte {
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 40), G: uint8(y * 40), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	base := testJPEG(t, 4, 2)
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			data := withSegment(base, exifSegment(order, uint16(orientation)))
+			if got := jpegOrientation(data); got != orientation {
+				t.Errorf("jpegOrientation(%v, %d) = %d", order, orientation, got)
+			}
+		}
+	}
+
+	valid := exifSegment(binary.BigEndian, 6)
+	badOrder := append([]byte{}, valid...)
+	copy(badOrder[10:], "XX")
+	badIFD := append([]byte{}, valid...)
+	binary.BigEndian.PutUint32(badIFD[14:], 4000)
+	tooManyEntries := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(tooManyEntries[18:], 50)
+	binary.BigEndian.PutUint16(tooManyEntries[20:], 0x0110) // Model, not the orientation
+	badLength := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(badLength[2:], 60000)
+	notExif := append([]byte{}, valid...)
+	copy(notExif[4:], "XMP\x00\x00\x00")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"no EXIF", base},
+		{"empty", nil},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n")},
+		{"orientation 0", withSegment(base, exifSegment(binary.BigEndian, 0))},
+		{"orientation 9", withSegment(base, exifSegment(binary.BigEndian, 9))},
+		{"unknown byte order", withSegment(base, badOrder)},
+		{"IFD out of range", withSegment(base, badIFD)},
+		{"more entries than data", withSegment(base, tooManyEntries)},
+		{"segment longer than the file", withSegment(base, badLength)},
+		{"APP1 without EXIF", withSegment(base, notExif)},
+		{"only the SOI and the segment header", withSegment([]byte{0xFF, 0xD8}, valid[:4])},
+	}
+
+	for _, tt := range tests {
+		if got := jpegOrientation(tt.data); got != 1 {
+			t.Errorf("%s: jpegOrientation = %d, want 1", tt.name, got)
+		}
+	}
+
+	// Every truncation of a valid file must be handled without panicking
+	full := withSegment(base, valid)
+	for i := range full {
+		jpegOrientation(full[:i])
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3x2 image with the top-left pixel red and the one on its right green
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, green)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		red, green  image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(1, 0)},
+		{2, 3, 2, image.Pt(2, 0), image.Pt(1, 0)}, // mirrored
+		{3, 3, 2, image.Pt(2, 1), image.Pt(1, 1)}, // 180 degrees
+		{4, 3, 2, image.Pt(0, 1), image.Pt(1, 1)}, // flipped
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 1)}, // transposed
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 1)}, // 90 degrees clockwise
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 1)}, // transversed
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 1)}, // 90 degrees counterclockwise
+		{0, 3, 2, image.Pt(0, 0), image.Pt(1, 0)},
+		{9, 3, 2, image.Pt(0, 0), image.Pt(1, 0)},
+	}
+
+	for _, tt := range tests {
+		got := toRGBA(applyOrientation(src, tt.orientation))
+		if got.Bounds().Dx() != tt.w || got.Bounds().Dy() != tt.h {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", tt.orientation, got.Bounds().Dx(), got.Bounds().Dy(), tt.w, tt.h)
+			continue
+		}
+		if got.RGBAAt(tt.red.X, tt.red.Y) != red || got.RGBAAt(tt.green.X, tt.green.Y) != green {
+			t.Errorf("orientation %d: red at %v and green at %v expected", tt.orientation, tt.red, tt.green)
+		}
+	}
+}
+
+func TestDecodeOrientedImage(t *testing.T) {
+	data := withSegment(testJPEG(t, 4, 2), exifSegment(binary.LittleEndian, 6))
+
+	img, err := DecodeOrientedImage(data)
+	if err != nil {
+		t.Fatalf("DecodeOrientedImage: %v", err)
+	}
+	if img.Bounds().Dx() != 2 || img.Bounds().Dy() != 4 {
+		t.Errorf("size %dx%d, want the 4x2 photo rotated to 2x4", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+// pngHeader is the start of a PNG that claims to be w x h, enough for
+// image.DecodeConfig.
+func pngHeader(w, h uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], w)
+	binary.BigEndian.PutUint32(ihdr[4:], h)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // RGBA
+
+	chunk := make([]byte, 4, 4+4+13+4)
+	binary.BigEndian.PutUint32(chunk, 13)
+	chunk = append(chunk, "IHDR"...)
+	chunk = append(chunk, ihdr...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	return append([]byte("\x89PNG\r\n\x1a\n"), chunk...)
+}
+
+func TestDecodeImageInvalid(t *testing.T) {
+	valid := testJPEG(t, 4, 4)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		tooLarge bool
+	}{
+		{"over the pixel limit", pngHeader(10_000, 10_000), true},
+		{"wide over the pixel limit", pngHeader(maxImagePixels+1, 1), true},
+		{"under the pixel limit without pixels", pngHeader(7_000, 7_000), false},
+		{"truncated JPEG", valid[:len(valid)/2], false},
+		{"not an image", []byte("<html></html>"), false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeOrientedImage(tt.data)
+			if !errors.Is(err, ErrInvalidImage) {
+				t.Fatalf("DecodeOrientedImage error = %v, want ErrInvalidImage", err)
+			}
+			if tooLarge := strings.Contains(err.Error(), "too large"); tooLarge != tt.tooLarge {
+				t.Errorf("error %q, want too large %v", err, tt.tooLarge)
+			}
+		})
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		maxSide      int
+		wantW, wantH int
+	}{
+		{100, 50, 200, 100, 50},
+		{400, 200, 100, 100, 50},
+		{200, 400, 100, 50, 100},
+		{1000, 1, 100, 100, 1},
+		{1, 1000, 100, 1, 100},
+		{300, 300, 100, 100, 100},
+	}
+
+	for _, tt := range tests {
+		got := FitWithin(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.maxSide)
+		if got.Bounds().Dx() != tt.wantW || got.Bounds().Dy() != tt.wantH {
+			t.Errorf("FitWithin(%dx%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.maxSide, got.Bounds().Dx(), got.Bounds().Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestResizeKeepsFlatColor(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	fill := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			src.SetRGBA(x, y, fill)
+		}
+	}
+
+	for _, size := range []image.Point{{7, 5}, {32, 24}, {100, 80}} {
+		got := Resize(src, size.X, size.Y)
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				if c := got.RGBAAt(x, y); c != fill {
+					t.Fatalf("Resize to %v: pixel (%d,%d) = %v, want %v", size, x, y, c, fill)
+				}
+			}
+		}
+	}
+
+	if got := Resize(src, 0, 10); !got.Bounds().Empty() {
+		t.Errorf("Resize to 0x10 = %v, want an empty image", got.Bounds())
+	}
+}
+
+func TestProcessAvatarDropsMetadata(t *testing.T) {
+	data := withSegment(testJPEG(t, 40, 20), exifSegment(binary.BigEndian, 6))
+
+	avatar, err := ProcessAvatar(data)
+	if err != nil {
+		t.Fatalf("ProcessAvatar: %v", err)
+	}
+	if bytes.Contains(avatar, []byte("Exif\x00\x00")) {
+		t.Error("the avatar keeps the EXIF metadata")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(avatar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != config.Height || config.Width > AvatarSize {
+		t.Errorf("avatar is %dx%d, want a square up to %d", config.Width, config.Height, AvatarSize)
+	}
+}
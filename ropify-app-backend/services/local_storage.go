@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStoragePath is where the API serves the files of a LocalStorage.
+const LocalStoragePath = "/storage/"
+
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalStorage keeps the objects on disk, to work offline without S3. Upload
+// URLs are signed with an HMAC like S3 presigned URLs, the API serves them
+// through the handlers of NewLocalStorageHandler.
+type LocalStorage struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", fmt.Errorf("failed to store %s: %v", key, err)
+	}
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to store %s: %v", key, err)
+	}
+
+	return s.objectURL(key), nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", key, err)
+	}
+	return data, nil
+}
+
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", key, err)
+	}
+	return f, nil
+}
+
+// Stat sniffs the content type, the disk does not keep the one of the upload.
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*StoredObject, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", key, err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", key, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+
+	return &StoredObject{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  http.DetectContentType(head[:n]),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]StoredObject, error) {
+	objects := []StoredObject{}
+
+	err := filepath.WalkDir(s.dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, StoredObject{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %v", err)
+	}
+
+	return objects, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		file, err := s.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *LocalStorage) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expiresAt := time.Now().Add(expires).Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expiresAt, 10)},
+		"signature": {s.sign(http.MethodPut, key, contentType, expiresAt)},
+	}
+
+	return s.objectURL(key) + "?" + query.Encode(), nil
+}
+
+// VerifyPut checks an upload made to an URL returned by PresignPut.
+func (s *LocalStorage) VerifyPut(key, contentType, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidSignature
+	}
+
+	expected := s.sign(http.MethodPut, key, contentType, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) KeyFromURL(rawURL string) (string, bool) {
+	escaped, ok := strings.CutPrefix(rawURL, strings.TrimSuffix(s.baseURL, "/")+LocalStoragePath)
+	if !ok {
+		return "", false
+	}
+
+	key, err := url.PathUnescape(escaped)
+	if err != nil || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+func (s *LocalStorage) sign(method, key, contentType string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", method, key, contentType, expiresAt)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps a key inside the storage directory, rejecting keys that would
+// escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean != "/"+key {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) objectURL(key string) string {
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	return strings.TrimSuffix(s.baseURL, "/") + LocalStoragePath + escaped
+}
+
+// NewLocalStorage stores the files in dir, baseURL is the public URL of the
+// API. URLs are signed with the JWT secret.
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+
+	return &LocalStorage{
+		dir:     dir,
+		baseURL: baseURL,
+		secret:  []byte(os.Getenv("JWT_SECRET") + ":storage"),
+	}, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStoragePath(t *testing.T) {
+	storage := newTestLocalStorage(t)
+
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"garments/users/1/a.jpg", true},
+		{"avatars/a b.jpg", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"garments/../../secret", false},
+		{"garments/./a.jpg", false},
+		{"garments//a.jpg", false},
+		{"garments/", false},
+		{"..", false},
+	}
+
+	for _, tt := range tests {
+		file, err := storage.path(tt.key)
+		if (err == nil) != tt.valid {
+			t.Errorf("path(%q) error = %v, want valid %v", tt.key, err, tt.valid)
+			continue
+		}
+		if tt.valid && !strings.HasPrefix(file, storage.dir+"/") {
+			t.Errorf("path(%q) = %q, outside of %q", tt.key, file, storage.dir)
+		}
+	}
+}
+
+func TestLocalStorageRejectsTraversal(t *testing.T) {
+	storage := newTestLocalStorage(t)
+
+	if _, err := storage.Put(context.Background(), "../escaped.txt", []byte("x"), "text/plain"); err == nil {
+		t.Error("Put wrote outside of the storage directory")
+	}
+	if _, err := storage.Get(context.Background(), "../../etc/passwd"); err == nil {
+		t.Error("Get read outside of the storage directory")
+	}
+	if _, err := storage.PresignPut(context.Background(), "a/../../b.jpg", "image/jpeg", time.Minute); err == nil {
+		t.Error("PresignPut signed a key outside of the storage directory")
+	}
+}
+
+func TestLocalStorageVerifyPut(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	storage := newTestLocalStorage(t)
+
+	const key = "garments/users/1/a.jpg"
+	rawURL, err := storage.PresignPut(context.Background(), key, "image/jpeg", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := parsed.Query().Get("expires")
+	signature := parsed.Query().Get("signature")
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	expiredSignature := storage.sign("PUT", key, "image/jpeg", time.Now().Add(-time.Minute).Unix())
+
+	tests := []struct {
+		name        string
+		key         string
+		contentType string
+		expires     string
+		signature   string
+		valid       bool
+	}{
+		{"presigned URL", key, "image/jpeg", expires, signature, true},
+		{"another key", "garments/users/2/a.jpg", "image/jpeg", expires, signature, false},
+		{"another content type", key, "text/html", expires, signature, false},
+		{"extended expiry", key, "image/jpeg", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10), signature, false},
+		{"expired", key, "image/jpeg", past, expiredSignature, false},
+		{"invalid expiry", key, "image/jpeg", "tomorrow", signature, false},
+		{"no signature", key, "image/jpeg", expires, "", false},
+		{"tampered signature", key, "image/jpeg", expires, strings.Repeat("0", len(signature)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := storage.VerifyPut(tt.key, tt.contentType, tt.expires, tt.signature)
+			if tt.valid && err != nil {
+				t.Errorf("VerifyPut: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifyPut error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestLocalStorageKeyFromURL(t *testing.T) {
+	storage := newTestLocalStorage(t)
+
+	stored, err := storage.Put(context.Background(), "avatars/a b.jpg", []byte("x"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, ok := storage.KeyFromURL(stored); !ok || key != "avatars/a b.jpg" {
+		t.Errorf("KeyFromURL(%q) = %q, %v", stored, key, ok)
+	}
+	if _, ok := storage.KeyFromURL("https://images.example.com/storage/a.jpg"); ok {
+		t.Error("KeyFromURL accepted the URL of another host")
+	}
+}
@@ -2,135 +2,146 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/gaelzamora/ropify-app/config"
 	"github.com/gaelzamora/ropify-app/models"
-	"github.com/gaelzamora/ropify-app/utils"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
 )
 
 type OAuthService struct {
 	repository models.AuthRepository
+	identities models.IdentityRepository
+	usernames  models.UsernameService
+	providers  map[string]OAuthProvider
+	states     *oauthStateStore
+
+	googleVerifier *GoogleIDTokenVerifier
+}
+
+// HandleGoogleToken signs in with a token obtained by the app through Google
+// Sign-In. ID tokens are verified locally; the userinfo endpoint is only
+// called when the client can only provide an access token, once tokeninfo
+// confirms it was issued to one of our client IDs.
+func (s *OAuthService) HandleGoogleToken(ctx context.Context, idToken, accessToken string) (string, *models.User, error) {
+	provider, err := s.Provider("google")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var userInfo *OAuthUserInfo
+
+	if s.googleVerifier == nil {
+		return "", nil, fmt.Errorf("google sign-in is not configured")
+	}
+
+	switch {
+	case idToken != "":
+		claims, err := s.googleVerifier.Verify(ctx, idToken)
+		if err != nil {
+			return "", nil, err
+		}
+		userInfo = claims.UserInfo()
+	case accessToken != "":
+		subject, err := s.googleVerifier.VerifyAccessToken(ctx, accessToken)
+		if err != nil {
+			return "", nil, err
+		}
+		userInfo, err = provider.FetchUserInfo(ctx, &oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"})
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to fetch google user info: %v", err)
+		}
+		if userInfo.ID != subject {
+			return "", nil, fmt.Errorf("%w: user info of another account", ErrInvalidIDToken)
+		}
+	default:
+		return "", nil, fmt.Errorf("an id_token or access_token is required")
+	}
+
+	return s.login(ctx, provider, userInfo)
 }
 
-func (s *OAuthService) HandleGoogleToken(ctx context.Context, accessToken string) (string, *models.User, error) {
-    // Logging para depuración
-    fmt.Println("Recibido token de acceso:", accessToken[:15]+"...")
-    
-    // Get user info from Google
-    userInfo, err := s.getGoogleUserInfo(accessToken)
-    if err != nil {
-        fmt.Println("Error obteniendo info del usuario:", err)
-        return "", nil, err
-    }
-    
-    fmt.Println("Info de usuario recibida:", userInfo.Email)
-    
-    // Check if user exists or create new user
-    user, err := s.repository.GetUser(ctx, "email = ?", userInfo.Email)
-    if err != nil {
-        fmt.Println("Usuario no encontrado, creando nuevo usuario")
-        // Create new user
-        newUser := &models.User{
-            Email:     userInfo.Email,
-            FirstName: userInfo.GivenName,
-            LastName:  userInfo.FamilyName,
-            Username:  userInfo.Email, // O generar un nombre de usuario único
-            GoogleID:  &userInfo.ID,
-            AvatarURL: userInfo.Picture, // Corregido: no uses puntero
-        }
-        
-        user, err = s.repository.RegisterOAuthUser(ctx, newUser)
-        if err != nil {
-            fmt.Println("Error registrando usuario:", err)
-            return "", nil, err
-        }
-        fmt.Println("Nuevo usuario creado con ID:", user.ID)
-    } else {
-        fmt.Println("Usuario existente encontrado:", user.ID)
-        // Actualizar GoogleID si es necesario
-        if user.GoogleID == nil || *user.GoogleID != userInfo.ID {
-            user.GoogleID = &userInfo.ID
-            if userInfo.Picture != "" && user.AvatarURL == "" {
-                user.AvatarURL = userInfo.Picture
-            }
-            // Descomentar y asegurarse de tener este método implementado
-            err = s.repository.UpdateUser(ctx, user)
-            if err != nil {
-                fmt.Println("Error actualizando usuario:", err)
-                return "", nil, err
-            }
-            fmt.Println("Usuario actualizado correctamente")
-        }
-    }
-    
-    // Generate JWT token
-    claims := jwt.MapClaims{
-        "id":  user.ID,
-        "exp": time.Now().Add(time.Hour * 168).Unix(), // Token válido por 1 semana
-    }
-    
-    jwtToken, err := utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
-    if err != nil {
-        return "", nil, err
-    }
-    
-    fmt.Println("JWT generado correctamente para usuario:", user.ID)
-    return jwtToken, user, nil
+// BeginLogin returns the consent URL of the provider. The state keeps the
+// PKCE verifier until the callback comes back.
+func (s *OAuthService) BeginLogin(providerName string) (string, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state := s.states.create(provider)
+	opts := []oauth2.AuthCodeOption{}
+	if provider.UsesPKCE() {
+		opts = append(opts, oauth2.S256ChallengeOption(state.verifier))
+	}
+
+	return provider.AuthCodeURL(state.value, opts...), nil
 }
 
+// HandleCallback exchanges the authorization code and signs the user in.
+func (s *OAuthService) HandleCallback(ctx context.Context, providerName, code, stateValue string) (string, *models.User, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return "", nil, err
+	}
 
-func (s *OAuthService) HandleGoogleLogin(ctx context.Context, code string) (string, *models.User, error) {
-	// Exchange code for token
-	token, err := config.GoogleOAuthConfig.Exchange(ctx, code)
+	state, ok := s.states.consume(stateValue)
+	if !ok || state.provider != provider.Name() {
+		return "", nil, fmt.Errorf("invalid or expired oauth state")
+	}
+
+	opts := []oauth2.AuthCodeOption{}
+	if provider.UsesPKCE() {
+		opts = append(opts, oauth2.VerifierOption(state.verifier))
+	}
+
+	token, err := provider.Exchange(ctx, code, opts...)
 	if err != nil {
 		return "", nil, fmt.Errorf("code exchange failed: %v", err)
 	}
 
-	// Get user info from Google
-	userInfo, err := s.getGoogleUserInfo(token.AccessToken)
+	userInfo, err := provider.FetchUserInfo(ctx, token)
 	if err != nil {
 		return "", nil, err
 	}
 
-	// Check if user exists or create new user
-	user, err := s.repository.GetUser(ctx, "email = ?", userInfo.Email)
-	if err != nil {
-		// Create new user
-		newUser := &models.User{
-			Email:     userInfo.Email,
-			FirstName: userInfo.GivenName,
-			LastName:  userInfo.FamilyName,
-			Username:  userInfo.Email, // O generar un nombre de usuario único
-			GoogleID:  &userInfo.ID,
-		}
+	return s.login(ctx, provider, userInfo)
+}
 
-		user, err = s.repository.RegisterOAuthUser(ctx, newUser)
+// login signs in the user owning the provider identity, or creates a new
+// user when nobody uses that email yet. An existing account with the same
+// email is never linked silently: the caller gets a LinkRequiredError whose
+// ticket must be confirmed from an authenticated session.
+func (s *OAuthService) login(ctx context.Context, provider OAuthProvider, userInfo *OAuthUserInfo) (string, *models.User, error) {
+	var user *models.User
 
+	identity, err := s.identities.FindIdentity(ctx, provider.Name(), userInfo.ID)
+	switch {
+	case err == nil:
+		user, err = s.repository.GetUser(ctx, "id = ?", identity.UserID)
 		if err != nil {
 			return "", nil, err
 		}
-	} else {
-		// Actualizar GoogleID si es necesario
-		if user.GoogleID == nil || *user.GoogleID != userInfo.ID {
-			// Actualizar el usuario con el GoogleID
-			// Implementar esta lógica en tu repositorio
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = s.register(ctx, provider, userInfo)
+		if err != nil {
+			return "", nil, err
 		}
+	default:
+		return "", nil, err
 	}
 
-	// Generate JWT token
-	claims := jwt.MapClaims{
-		"id":  user.ID,
-		"exp": time.Now().Add(time.Hour * 168).Unix(),
+	if user.IsSuspended() {
+		return "", nil, models.ErrUserSuspended
 	}
 
-	jwtToken, err := utils.GenerateJWT(claims, jwt.SigningMethodHS256, os.Getenv("JWT_SECRET"))
+	// Generate JWT token
+	jwtToken, err := GenerateUserToken(user)
 	if err != nil {
 		return "", nil, err
 	}
@@ -138,6 +149,65 @@ func (s *OAuthService) HandleGoogleLogin(ctx context.Context, code string) (stri
 	return jwtToken, user, nil
 }
 
+func (s *OAuthService) register(ctx context.Context, provider OAuthProvider, userInfo *OAuthUserInfo) (*models.User, error) {
+	email := userInfo.Email
+
+	// Unverified emails are never matched, they would tell anyone whether an
+	// email has an account
+	if email != "" && userInfo.EmailVerified {
+		_, err := s.repository.GetUser(ctx, "email = ?", email)
+		if err == nil {
+			ticket, err := NewLinkTicket(provider.Name(), userInfo)
+			if err != nil {
+				return nil, err
+			}
+			return nil, &LinkRequiredError{Provider: provider.Name(), Email: email, LinkToken: ticket}
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if email == "" || !userInfo.EmailVerified {
+		// Providers like X do not share the email, the column is unique and required
+		email = fmt.Sprintf("%s_%s@oauth.invalid", provider.Name(), userInfo.ID)
+	}
+
+	name := userInfo.Name
+	if name == "" {
+		name = strings.TrimSpace(userInfo.GivenName + " " + userInfo.FamilyName)
+	}
+
+	username, err := s.usernames.Generate(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	newUser := &models.User{
+		Email:     email,
+		FirstName: userInfo.GivenName,
+		LastName:  userInfo.FamilyName,
+		Username:  username,
+		AvatarURL: userInfo.Picture,
+	}
+	newUser.SetProviderID(provider.IDField(), userInfo.ID)
+
+	return s.repository.RegisterOAuthUser(ctx, newUser, &models.UserIdentity{
+		Provider: provider.Name(),
+		Subject:  userInfo.ID,
+		Email:    userInfo.Email,
+	})
+}
+
+// Provider returns the registered provider with the given name.
+func (s *OAuthService) Provider(name string) (OAuthProvider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported oauth provider: %s", name)
+	}
+	return provider, nil
+}
+
 // Estructura para la respuesta de Google
 type GoogleUserInfo struct {
 	ID            string `json:"id"`
@@ -149,30 +219,82 @@ type GoogleUserInfo struct {
 	Picture       string `json:"picture"`
 }
 
-func (s *OAuthService) getGoogleUserInfo(accessToken string) (*GoogleUserInfo, error) {
-	resp, err := http.Get("https://www.googleapis.com/oauth2/v2/userinfo?access_token=" + accessToken)
-	if err != nil {
-		return nil, err
+type oauthState struct {
+	value     string
+	provider  string
+	verifier  string
+	expiresAt time.Time
+}
+
+// oauthStateStore keeps the pending login states in memory. A state can only
+// be consumed once.
+type oauthStateStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	states map[string]*oauthState
+}
+
+func (st *oauthStateStore) create(provider OAuthProvider) *oauthState {
+	state := &oauthState{
+		value:     uuid.NewString(),
+		provider:  provider.Name(),
+		expiresAt: time.Now().Add(st.ttl),
+	}
+	if provider.UsesPKCE() {
+		state.verifier = oauth2.GenerateVerifier()
 	}
-	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	for key, pending := range st.states {
+		if now.After(pending.expiresAt) {
+			delete(st.states, key)
+		}
 	}
+	st.states[state.value] = state
 
-	var userInfo GoogleUserInfo
-	if err := json.Unmarshal(data, &userInfo); err != nil {
-		return nil, err
+	return state
+}
+
+func (st *oauthStateStore) consume(value string) (*oauthState, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	state, ok := st.states[value]
+	if !ok {
+		return nil, false
+	}
+	delete(st.states, value)
+
+	if time.Now().After(state.expiresAt) {
+		return nil, false
 	}
 
-	return &userInfo, nil
+	return state, true
 }
 
-// Implementar métodos similares para Facebook y Twitter
+func newOAuthStateStore(ttl time.Duration) *oauthStateStore {
+	return &oauthStateStore{
+		ttl:    ttl,
+		states: make(map[string]*oauthState),
+	}
+}
 
-func NewOAuthService(repository models.AuthRepository) *OAuthService {
-	return &OAuthService{
-		repository: repository,
+func NewOAuthService(repository models.AuthRepository, identities models.IdentityRepository, usernames models.UsernameService, googleVerifier *GoogleIDTokenVerifier, providers ...OAuthProvider) *OAuthService {
+	service := &OAuthService{
+		repository:     repository,
+		identities:     identities,
+		usernames:      usernames,
+		googleVerifier: googleVerifier,
+		providers:      make(map[string]OAuthProvider, len(providers)),
+		states:         newOAuthStateStore(10 * time.Minute),
 	}
+
+	for _, provider := range providers {
+		service.providers[provider.Name()] = provider
+	}
+
+	return service
 }
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	GoogleUserInfoURL   = "https://www.googleapis.com/oauth2/v2/userinfo"
+	FacebookUserInfoURL = "https://graph.facebook.com/v19.0/me"
+	TwitterUserInfoURL  = "https://api.twitter.com/2/users/me"
+)
+
+// OAuthUserInfo is the provider-agnostic profile returned by an OAuthProvider.
+type OAuthUserInfo struct {
+	ID            string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+}
+
+// OAuthProvider abstracts the parts of a social login that differ between
+// providers: building the consent URL, exchanging the code and reading the
+// user's profile.
+type OAuthProvider interface {
+	// Name is the path segment used in the routes, e.g. "google".
+	Name() string
+	// IDField is the users column that stores the provider subject.
+	IDField() string
+	// UsesPKCE reports whether the flow must send a code verifier.
+	UsesPKCE() bool
+	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	FetchUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error)
+}
+
+// oauthProvider holds what every implementation shares.
+type oauthProvider struct {
+	config      *oauth2.Config
+	userInfoURL string
+	httpClient  *http.Client
+}
+
+func (p *oauthProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+func (p *oauthProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	if p.httpClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	}
+	return p.config.Exchange(ctx, code, opts...)
+}
+
+// getJSON performs an authenticated GET against the provider API and decodes
+// the JSON body into out.
+func (p *oauthProvider) getJSON(ctx context.Context, token *oauth2.Token, rawURL string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	token.SetAuthHeader(req)
+
+	client := p.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("user info request failed with status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+type GoogleProvider struct {
+	oauthProvider
+}
+
+func (p *GoogleProvider) Name() string    { return "google" }
+func (p *GoogleProvider) IDField() string { return "google_id" }
+func (p *GoogleProvider) UsesPKCE() bool  { return false }
+
+func (p *GoogleProvider) FetchUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	var info GoogleUserInfo
+	if err := p.getJSON(ctx, token, p.userInfoURL, &info); err != nil {
+		return nil, err
+	}
+
+	if info.ID == "" {
+		return nil, fmt.Errorf("google did not return a user id")
+	}
+
+	return &OAuthUserInfo{
+		ID:            info.ID,
+		Email:         info.Email,
+		EmailVerified: info.VerifiedEmail,
+		Name:          info.Name,
+		GivenName:     info.GivenName,
+		FamilyName:    info.FamilyName,
+		Picture:       info.Picture,
+	}, nil
+}
+
+type FacebookProvider struct {
+	oauthProvider
+}
+
+func (p *FacebookProvider) Name() string    { return "facebook" }
+func (p *FacebookProvider) IDField() string { return "facebook_id" }
+func (p *FacebookProvider) UsesPKCE() bool  { return false }
+
+func (p *FacebookProvider) FetchUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	var info struct {
+		ID        string `json:"id"`
+		Email     string `json:"email"`
+		Name      string `json:"name"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Picture   struct {
+			Data struct {
+				URL string `json:"url"`
+			} `json:"data"`
+		} `json:"picture"`
+	}
+
+	query := url.Values{"fields": {"id,name,email,first_name,last_name,picture.type(large)"}}
+	if err := p.getJSON(ctx, token, p.userInfoURL+"?"+query.Encode(), &info); err != nil {
+		return nil, err
+	}
+
+	if info.ID == "" {
+		return nil, fmt.Errorf("facebook did not return a user id")
+	}
+
+	// Facebook only returns emails that the user has confirmed.
+	return &OAuthUserInfo{
+		ID:            info.ID,
+		Email:         info.Email,
+		EmailVerified: info.Email != "",
+		Name:          info.Name,
+		GivenName:     info.FirstName,
+		FamilyName:    info.LastName,
+		Picture:       info.Picture.Data.URL,
+	}, nil
+}
+
+type TwitterProvider struct {
+	oauthProvider
+}
+
+func (p *TwitterProvider) Name() string    { return "twitter" }
+func (p *TwitterProvider) IDField() string { return "twitter_id" }
+func (p *TwitterProvider) UsesPKCE() bool  { return true }
+
+func (p *TwitterProvider) FetchUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	var info struct {
+		Data struct {
+			ID              string `json:"id"`
+			Name            string `json:"name"`
+			Username        string `json:"username"`
+			ProfileImageURL string `json:"profile_image_url"`
+		} `json:"data"`
+	}
+
+	query := url.Values{"user.fields": {"profile_image_url"}}
+	if err := p.getJSON(ctx, token, p.userInfoURL+"?"+query.Encode(), &info); err != nil {
+		return nil, err
+	}
+
+	if info.Data.ID == "" {
+		return nil, fmt.Errorf("twitter did not return a user id")
+	}
+
+	// X does not share the account email, only the display name.
+	givenName, familyName, _ := strings.Cut(info.Data.Name, " ")
+
+	return &OAuthUserInfo{
+		ID:         info.Data.ID,
+		Name:       info.Data.Name,
+		GivenName:  givenName,
+		FamilyName: familyName,
+		Picture:    info.Data.ProfileImageURL,
+	}, nil
+}
+
+// NewGoogleProvider builds the Google provider. An empty userInfoURL falls
+// back to the public Google endpoint.
+func NewGoogleProvider(config *oauth2.Config, userInfoURL string, client *http.Client) *GoogleProvider {
+	if userInfoURL == "" {
+		userInfoURL = GoogleUserInfoURL
+	}
+	return &GoogleProvider{oauthProvider{config: config, userInfoURL: userInfoURL, httpClient: client}}
+}
+
+func NewFacebookProvider(config *oauth2.Config, userInfoURL string, client *http.Client) *FacebookProvider {
+	if userInfoURL == "" {
+		userInfoURL = FacebookUserInfoURL
+	}
+	return &FacebookProvider{oauthProvider{config: config, userInfoURL: userInfoURL, httpClient: client}}
+}
+
+func NewTwitterProvider(config *oauth2.Config, userInfoURL string, client *http.Client) *TwitterProvider {
+	if userInfoURL == "" {
+		userInfoURL = TwitterUserInfoURL
+	}
+	return &TwitterProvider{oauthProvider{config: config, userInfoURL: userInfoURL, httpClient: client}}
+}
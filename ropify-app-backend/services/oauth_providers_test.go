@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// fakeOAuthServer stands in for the token and user info endpoints of the
+// three providers.
+type fakeOAuthServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	verifiers []string
+}
+
+func newFakeOAuthServer(t *testing.T) *fakeOAuthServer {
+	t.Helper()
+
+	fake := &fakeOAuthServer{}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != "valid-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		fake.mu.Lock()
+		fake.verifiers = append(fake.verifiers, r.PostForm.Get("code_verifier"))
+		fake.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "token_type": "Bearer"})
+	})
+
+	userInfo := func(body interface{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(body)
+		}
+	}
+	mux.HandleFunc("/google/userinfo", userInfo(map[string]interface{}{
+		"id":             "g-1",
+		"email":          "ana@example.com",
+		"verified_email": true,
+		"name":           "Ana Pérez",
+		"given_name":     "Ana",
+		"family_name":    "Pérez",
+		"picture":        "https://example.com/ana.png",
+	}))
+	mux.HandleFunc("/facebook/me", userInfo(map[string]interface{}{
+		"id":         "fb-1",
+		"email":      "luis@example.com",
+		"name":       "Luis Gómez",
+		"first_name": "Luis",
+		"last_name":  "Gómez",
+		"picture":    map[string]interface{}{"data": map[string]string{"url": "https://example.com/luis.png"}},
+	}))
+	mux.HandleFunc("/twitter/me", userInfo(map[string]interface{}{
+		"data": map[string]string{
+			"id":                "x-1",
+			"name":              "Sofía Ruiz",
+			"username":          "sofia",
+			"profile_image_url": "https://example.com/sofia.png",
+		},
+	}))
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakeOAuthServer) config() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   f.URL + "/auth",
+			TokenURL:  f.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+func (f *fakeOAuthServer) providers() []OAuthProvider {
+	return []OAuthProvider{
+		NewGoogleProvider(f.config(), f.URL+"/google/userinfo", f.Client()),
+		NewFacebookProvider(f.config(), f.URL+"/facebook/me", f.Client()),
+		NewTwitterProvider(f.config(), f.URL+"/twitter/me", f.Client()),
+	}
+}
+
+func (f *fakeOAuthServer) lastVerifier() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.verifiers) == 0 {
+		return ""
+	}
+	return f.verifiers[len(f.verifiers)-1]
+}
+
+// fakeAuthRepository finds only the users of existing, by any query, and
+// keeps the ones registered.
+type fakeAuthRepository struct {
+	models.AuthRepository
+
+	existing   []*models.User
+	registered []*models.User
+	identities []*models.UserIdentity
+}
+
+func (r *fakeAuthRepository) GetUser(ctx context.Context, query interface{}, args ...interface{}) (*models.User, error) {
+	for _, user := range r.existing {
+		for _, arg := range args {
+			if arg == user.Email {
+				return user, nil
+			}
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeAuthRepository) RegisterOAuthUser(ctx context.Context, user *models.User, identity *models.UserIdentity) (*models.User, error) {
+	user.ID = uuid.New()
+	r.registered = append(r.registered, user)
+	r.identities = append(r.identities, identity)
+	return user, nil
+}
+
+type fakeIdentityRepository struct {
+	models.IdentityRepository
+}
+
+func (r *fakeIdentityRepository) FindIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+type fakeUsernameService struct {
+	models.UsernameService
+}
+
+func (s *fakeUsernameService) Generate(ctx context.Context, name string) (string, error) {
+	return "user_" + uuid.NewString()[:8], nil
+}
+
+func newTestOAuthService(t *testing.T, fake *fakeOAuthServer) (*OAuthService, *fakeAuthRepository) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	repository := &fakeAuthRepository{}
+	service := NewOAuthService(repository, &fakeIdentityRepository{}, &fakeUsernameService{}, nil, fake.providers()...)
+	return service, repository
+}
+
+// beginLogin returns the state and the query of the consent URL.
+func beginLogin(t *testing.T, service *OAuthService, provider string) (string, url.Values) {
+	t.Helper()
+
+	authURL, err := service.BeginLogin(provider)
+	if err != nil {
+		t.Fatalf("BeginLogin(%s): %v", provider, err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse consent URL: %v", err)
+	}
+	query := parsed.Query()
+	return query.Get("state"), query
+}
+
+func TestProvidersFetchUserInfo(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	token := &oauth2.Token{AccessToken: "access-token", TokenType: "Bearer"}
+
+	tests := []struct {
+		provider OAuthProvider
+		want     OAuthUserInfo
+	}{
+		{
+			provider: fake.providers()[0],
+			want: OAuthUserInfo{ID: "g-1", Email: "ana@example.com", EmailVerified: true, Name: "Ana Pérez",
+				GivenName: "Ana", FamilyName: "Pérez", Picture: "https://example.com/ana.png"},
+		},
+		{
+			provider: fake.providers()[1],
+			want: OAuthUserInfo{ID: "fb-1", Email: "luis@example.com", EmailVerified: true, Name: "Luis Gómez",
+				GivenName: "Luis", FamilyName: "Gómez", Picture: "https://example.com/luis.png"},
+		},
+		{
+			provider: fake.providers()[2],
+			want: OAuthUserInfo{ID: "x-1", Name: "Sofía Ruiz", GivenName: "Sofía", FamilyName: "Ruiz",
+				Picture: "https://example.com/sofia.png"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider.Name(), func(t *testing.T) {
+			got, err := tt.provider.FetchUserInfo(context.Background(), token)
+			if err != nil {
+				t.Fatalf("FetchUserInfo: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("FetchUserInfo = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvidersRejectInvalidToken(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	token := &oauth2.Token{AccessToken: "stolen", TokenType: "Bearer"}
+
+	for _, provider := range fake.providers() {
+		if _, err := provider.FetchUserInfo(context.Background(), token); err == nil {
+			t.Errorf("%s: FetchUserInfo accepted a token the provider rejected", provider.Name())
+		}
+	}
+}
+
+func TestHandleCallbackStoresProviderID(t *testing.T) {
+	tests := []struct {
+		provider string
+		subject  string
+		id       func(*models.User) *string
+	}{
+		{"google", "g-1", func(u *models.User) *string { return u.GoogleID }},
+		{"facebook", "fb-1", func(u *models.User) *string { return u.FacebookID }},
+		{"twitter", "x-1", func(u *models.User) *string { return u.TwitterID }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			fake := newFakeOAuthServer(t)
+			service, repository := newTestOAuthService(t, fake)
+
+			state, _ := beginLogin(t, service, tt.provider)
+			token, user, err := service.HandleCallback(context.Background(), tt.provider, "valid-code", state)
+			if err != nil {
+				t.Fatalf("HandleCallback: %v", err)
+			}
+			if token == "" {
+				t.Error("HandleCallback returned an empty JWT")
+			}
+
+			if id := tt.id(user); id == nil || *id != tt.subject {
+				t.Errorf("%s ID = %v, want %q", tt.provider, id, tt.subject)
+			}
+			if len(repository.identities) != 1 {
+				t.Fatalf("registered %d identities, want 1", len(repository.identities))
+			}
+			if identity := repository.identities[0]; identity.Provider != tt.provider || identity.Subject != tt.subject {
+				t.Errorf("identity = %s/%s, want %s/%s", identity.Provider, identity.Subject, tt.provider, tt.subject)
+			}
+		})
+	}
+}
+
+func TestTwitterUsesEmailPlaceholder(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	service, _ := newTestOAuthService(t, fake)
+
+	state, _ := beginLogin(t, service, "twitter")
+	_, user, err := service.HandleCallback(context.Background(), "twitter", "valid-code", state)
+	if err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if user.Email != "twitter_x-1@oauth.invalid" {
+		t.Errorf("Email = %q, want the placeholder of the X account", user.Email)
+	}
+}
+
+func TestTwitterPKCERoundTrip(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	service, _ := newTestOAuthService(t, fake)
+
+	state, query := beginLogin(t, service, "twitter")
+	if query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+	challenge := query.Get("code_challenge")
+	if challenge == "" {
+		t.Fatal("the consent URL has no code_challenge")
+	}
+
+	if _, _, err := service.HandleCallback(context.Background(), "twitter", "valid-code", state); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+
+	verifier := fake.lastVerifier()
+	if verifier == "" {
+		t.Fatal("the code exchange sent no code_verifier")
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if got := base64.RawURLEncoding.EncodeToString(sum[:]); got != challenge {
+		t.Errorf("S256(code_verifier) = %q, want the challenge %q", got, challenge)
+	}
+}
+
+func TestNonPKCEProvidersSendNoVerifier(t *testing.T) {
+	for _, provider := range []string{"google", "facebook"} {
+		t.Run(provider, func(t *testing.T) {
+			fake := newFakeOAuthServer(t)
+			service, _ := newTestOAuthService(t, fake)
+
+			state, query := beginLogin(t, service, provider)
+			if query.Get("code_challenge") != "" {
+				t.Errorf("the consent URL has a code_challenge")
+			}
+			if _, _, err := service.HandleCallback(context.Background(), provider, "valid-code", state); err != nil {
+				t.Fatalf("HandleCallback: %v", err)
+			}
+			if verifier := fake.lastVerifier(); verifier != "" {
+				t.Errorf("code_verifier = %q, want none", verifier)
+			}
+		})
+	}
+}
+
+func TestOAuthStateIsSingleUse(t *testing.T) {
+	fake := newFakeOAuthServer(t)
+	service, _ := newTestOAuthService(t, fake)
+
+	state, _ := beginLogin(t, service, "facebook")
+	if _, _, err := service.HandleCallback(context.Background(), "facebook", "valid-code", state); err != nil {
+		t.Fatalf("first HandleCallback: %v", err)
+	}
+	if _, _, err := service.HandleCallback(context.Background(), "facebook", "valid-code", state); err == nil {
+		t.Error("the state was accepted twice")
+	}
+
+	if _, _, err := service.HandleCallback(context.Background(), "facebook", "valid-code", uuid.NewString()); err == nil {
+		t.Error("an unknown state was accepted")
+	}
+
+	// A state only works with the provider it was created for
+	state, _ = beginLogin(t, service, "google")
+	if _, _, err := service.HandleCallback(context.Background(), "facebook", "valid-code", state); err == nil {
+		t.Error("the state of another provider was accepted")
+	}
+	if _, _, err := service.HandleCallback(context.Background(), "google", "valid-code", state); err == nil {
+		t.Error("a state rejected once was accepted afterwards")
+	}
+}
+
+func TestOAuthStateExpires(t *testing.T) {
+	store := newOAuthStateStore(-1)
+	state := store.create(&TwitterProvider{})
+
+	if _, ok := store.consume(state.value); ok {
+		t.Error("an expired state was accepted")
+	}
+}
+
+func TestLoginOnlyMatchesVerifiedEmails(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	tests := []struct {
+		name      string
+		verified  bool
+		wantLink  bool
+		wantEmail string
+	}{
+		{name: "verified email of an account", verified: true, wantLink: true},
+		{name: "unverified email of an account", verified: false, wantEmail: "facebook_fb-1@oauth.invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeAuthRepository{existing: []*models.User{{ID: uuid.New(), Email: "ana@example.com"}}}
+			service := NewOAuthService(repository, &fakeIdentityRepository{}, &fakeUsernameService{}, nil)
+			provider := NewFacebookProvider(&oauth2.Config{}, "", nil)
+
+			_, user, err := service.login(context.Background(), provider, &OAuthUserInfo{
+				ID:            "fb-1",
+				Email:         "ana@example.com",
+				EmailVerified: tt.verified,
+			})
+
+			var linkErr *LinkRequiredError
+			if errors.As(err, &linkErr) != tt.wantLink {
+				t.Fatalf("login error = %v, want link required %v", err, tt.wantLink)
+			}
+			if tt.wantLink {
+				if len(repository.registered) != 0 {
+					t.Errorf("a user was registered for an existing email")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if user.Email != tt.wantEmail {
+				t.Errorf("Email = %q, want %q", user.Email, tt.wantEmail)
+			}
+		})
+	}
+}
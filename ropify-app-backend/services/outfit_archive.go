@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+)
+
+// outfitUnarchiveGrace is how long an outfit the user unarchived is left
+// alone when the stale rule is off. With the rule on it is staleAfter.
+const outfitUnarchiveGrace = 30 * 24 * time.Hour
+
+// OutfitArchiver applies the auto-archive policy: outfits with garments
+// that were deleted, and outfits not edited for staleAfter (0 only applies
+// the first rule).
+type OutfitArchiver struct {
+	repository models.OutfitRepository
+	staleAfter time.Duration
+}
+
+func (a *OutfitArchiver) AutoArchive(ctx context.Context) (*models.AutoArchiveReport, error) {
+	now := time.Now()
+	unarchivedBefore := now.Add(-outfitUnarchiveGrace)
+
+	var staleBefore *time.Time
+	if a.staleAfter > 0 {
+		cutoff := now.Add(-a.staleAfter)
+		staleBefore = &cutoff
+		unarchivedBefore = cutoff
+	}
+
+	return a.repository.AutoArchive(ctx, unarchivedBefore, staleBefore)
+}
+
+// RunOutfitAutoArchive applies the policy every interval until ctx is done.
+func RunOutfitAutoArchive(ctx context.Context, archiver models.OutfitArchiveService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := archiver.AutoArchive(ctx)
+		if err != nil {
+			log.Errorf("outfit auto-archive: %v", err)
+			continue
+		}
+		if report.MissingGarments > 0 || report.Stale > 0 {
+			log.Infof("outfit auto-archive: %d with deleted garments, %d stale", report.MissingGarments, report.Stale)
+		}
+	}
+}
+
+func NewOutfitArchiver(repository models.OutfitRepository, staleAfter time.Duration) models.OutfitArchiveService {
+	return &OutfitArchiver{
+		repository: repository,
+		staleAfter: staleAfter,
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+)
+
+type fakeArchiveRepository struct {
+	models.OutfitRepository
+
+	unarchivedBefore time.Time
+	staleBefore      *time.Time
+}
+
+func (r *fakeArchiveRepository) AutoArchive(ctx context.Context, unarchivedBefore time.Time, staleBefore *time.Time) (*models.AutoArchiveReport, error) {
+	r.unarchivedBefore = unarchivedBefore
+	r.staleBefore = staleBefore
+	return &models.AutoArchiveReport{}, nil
+}
+
+func TestOutfitArchiverCutoffs(t *testing.T) {
+	tests := []struct {
+		name           string
+		staleAfter     time.Duration
+		wantUnarchived time.Duration
+		wantStale      bool
+	}{
+		{"stale rule off", 0, outfitUnarchiveGrace, false},
+		{"stale rule on", 90 * 24 * time.Hour, 90 * 24 * time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeArchiveRepository{}
+			start := time.Now()
+
+			if _, err := NewOutfitArchiver(repository, tt.staleAfter).AutoArchive(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// Unarchived outfits are exempt for a while, never forever
+			if age := start.Sub(repository.unarchivedBefore); age < tt.wantUnarchived-time.Second || age > tt.wantUnarchived+time.Second {
+				t.Errorf("unarchived cutoff is %s ago, want %s", age, tt.wantUnarchived)
+			}
+			if (repository.staleBefore != nil) != tt.wantStale {
+				t.Fatalf("stale cutoff = %v, want set %v", repository.staleBefore, tt.wantStale)
+			}
+			if tt.wantStale && !repository.staleBefore.Equal(repository.unarchivedBefore) {
+				t.Errorf("stale cutoff %s differs from the unarchived cutoff %s", repository.staleBefore, repository.unarchivedBefore)
+			}
+		})
+	}
+}
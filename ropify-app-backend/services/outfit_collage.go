@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+// Medidas del collage en píxeles, formato vertical 4:5 como en los feeds
+const (
+	collageWidth   = 1080
+	collageHeight  = 1350
+	collagePadding = 40
+	collageGap     = 20
+	// Accessories and bags go in a column on the right
+	collageSideWidth = 300
+)
+
+// errOutfitChanged is returned when the garments of the outfit changed while
+// its collage was rendered, the newer render wins.
+var errOutfitChanged = errors.New("outfit changed while rendering its collage")
+
+// collageBands are the rows of the main column from top to bottom, with the
+// share of the height each one takes.
+var collageBands = []struct {
+	categories []models.GarmentCategory
+	weight     int
+}{
+	{[]models.GarmentCategory{models.Top}, 4},
+	{[]models.GarmentCategory{models.Dress}, 6},
+	{[]models.GarmentCategory{models.Bottoms}, 4},
+	{[]models.GarmentCategory{models.Sneakers}, 2},
+}
+
+type collageCell struct {
+	garment *models.Garment
+	rect    image.Rectangle
+}
+
+type OutfitCollageService struct {
+	outfits  models.OutfitRepository
+	garments models.GarmentRepository
+	storage  ObjectStorage
+}
+
+func (s *OutfitCollageService) RenderCollage(ctx context.Context, outfitID uuid.UUID) (string, error) {
+	outfit, err := s.outfits.GetOutfitByID(ctx, outfitID)
+	if err != nil {
+		return "", err
+	}
+
+	// Only garments of the owner of the outfit are drawn
+	found, err := s.garments.GetGarmentsByIDs(ctx, outfit.UserID, outfit.GarmentIDs)
+	if err != nil {
+		return "", err
+	}
+	byID := make(map[string]*models.Garment, len(found))
+	for _, garment := range found {
+		byID[garment.ID.String()] = garment
+	}
+	garments := []*models.Garment{}
+	for _, garmentID := range outfit.GarmentIDs {
+		if garment, ok := byID[strings.ToLower(garmentID)]; ok {
+			garments = append(garments, garment)
+		}
+	}
+
+	imageURL := ""
+	if len(garments) > 0 {
+		data, err := EncodePNG(s.compose(ctx, garments))
+		if err != nil {
+			return "", err
+		}
+
+		key := fmt.Sprintf("outfits/users/%s/%s-%s.png", outfit.UserID, outfit.ID, uuid.New())
+		if imageURL, err = s.storage.Put(ctx, key, data, "image/png"); err != nil {
+			return "", err
+		}
+	}
+
+	updated, err := s.outfits.SetOutfitImage(ctx, outfit.ID, outfit.GarmentIDs, imageURL)
+	if err == nil && !updated {
+		err = errOutfitChanged
+	}
+	if err != nil {
+		s.deleteCollage(ctx, imageURL)
+		return "", err
+	}
+
+	s.deleteCollage(ctx, outfit.ImageURL)
+	return imageURL, nil
+}
+
+func (s *OutfitCollageService) BackfillCollages(ctx context.Context) (int, error) {
+	rendered := 0
+	after := uuid.Nil
+
+	for {
+		outfits, err := s.outfits.ListOutfitsWithoutImage(ctx, after, 100)
+		if err != nil {
+			return rendered, err
+		}
+		if len(outfits) == 0 {
+			return rendered, nil
+		}
+
+		for _, outfit := range outfits {
+			after = outfit.ID
+
+			imageURL, err := s.RenderCollage(ctx, outfit.ID)
+			if err != nil {
+				log.Warnf("collage backfill: outfit %s: %v", outfit.ID, err)
+				continue
+			}
+			if imageURL != "" {
+				rendered++
+			}
+		}
+	}
+}
+
+// compose draws the garments on a white canvas. Photos without background
+// blend in, the rest are drawn as they are.
+func (s *OutfitCollageService) compose(ctx context.Context, garments []*models.Garment) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, collageWidth, collageHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	for _, cell := range collageLayout(garments) {
+		photo, err := s.loadPhoto(ctx, cell.garment)
+		if err != nil {
+			log.Warnf("collage: skipping photo of garment %s: %v", cell.garment.ID, err)
+			continue
+		}
+		if photo == nil {
+			continue
+		}
+
+		// Se escala para que quepa en la celda, centrado
+		w, h := photo.Bounds().Dx(), photo.Bounds().Dy()
+		cw, ch := cell.rect.Dx(), cell.rect.Dy()
+		width, height := cw, h*cw/w
+		if height > ch {
+			width, height = w*ch/h, ch
+		}
+		resized := Resize(photo, max(width, 1), max(height, 1))
+
+		origin := cell.rect.Min.Add(image.Pt((cw-width)/2, (ch-height)/2))
+		draw.Draw(canvas, resized.Bounds().Add(origin), resized, image.Point{}, draw.Over)
+	}
+
+	return canvas
+}
+
+// loadPhoto reads the medium rendition of the garment, or the original for
+// garments created before renditions existed. It returns nil when the
+// garment has no photo.
+func (s *OutfitCollageService) loadPhoto(ctx context.Context, garment *models.Garment) (image.Image, error) {
+	imageURL := garment.MediumURL
+	if imageURL == "" {
+		imageURL = garment.ImageURL
+	}
+	if imageURL == "" {
+		return nil, nil
+	}
+
+	key, ok := s.storage.KeyFromURL(imageURL)
+	if !ok {
+		return nil, fmt.Errorf("image %s is not in our storage", imageURL)
+	}
+
+	data, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := DecodeImage(data)
+	return img, err
+}
+
+// deleteCollage removes a collage that is no longer used. Other images, e.g.
+// one set by the client, are left alone.
+func (s *OutfitCollageService) deleteCollage(ctx context.Context, imageURL string) {
+	key, ok := s.storage.KeyFromURL(imageURL)
+	if !ok || !strings.HasPrefix(key, "outfits/") {
+		return
+	}
+	if err := s.storage.Delete(ctx, key); err != nil {
+		log.Warnf("collage: failed to delete %s: %v", key, err)
+	}
+}
+
+// collageLayout places tops above bottoms and shoes at the bottom of the main
+// column, and accessories, bags and unknown garments in the side column.
+// Garments in the same row share its width.
+func collageLayout(garments []*models.Garment) []collageCell {
+	type band struct {
+		garments []*models.Garment
+		weight   int
+	}
+
+	bands := []band{}
+	inBand := map[*models.Garment]bool{}
+	for _, b := range collageBands {
+		row := band{weight: b.weight}
+		for _, garment := range garments {
+			for _, category := range b.categories {
+				if garment.Category == category {
+					row.garments = append(row.garments, garment)
+					inBand[garment] = true
+				}
+			}
+		}
+		if len(row.garments) > 0 {
+			bands = append(bands, row)
+		}
+	}
+
+	side := []*models.Garment{}
+	for _, garment := range garments {
+		if !inBand[garment] {
+			side = append(side, garment)
+		}
+	}
+
+	// Sin ropa principal los accesorios ocupan todo el collage
+	if len(bands) == 0 {
+		bands, side = []band{{garments: side, weight: 1}}, nil
+	}
+
+	main := image.Rect(collagePadding, collagePadding, collageWidth-collagePadding, collageHeight-collagePadding)
+	cells := []collageCell{}
+
+	if len(side) > 0 {
+		column := image.Rect(main.Max.X-collageSideWidth, main.Min.Y, main.Max.X, main.Max.Y)
+		main.Max.X = column.Min.X - collageGap
+
+		height := (column.Dy() - (len(side)-1)*collageGap) / len(side)
+		for i, garment := range side {
+			y := column.Min.Y + i*(height+collageGap)
+			cells = append(cells, collageCell{garment, image.Rect(column.Min.X, y, column.Max.X, y+height)})
+		}
+	}
+
+	totalWeight := 0
+	for _, b := range bands {
+		totalWeight += b.weight
+	}
+	available := main.Dy() - (len(bands)-1)*collageGap
+
+	y := main.Min.Y
+	for _, b := range bands {
+		height := available * b.weight / totalWeight
+		width := (main.Dx() - (len(b.garments)-1)*collageGap) / len(b.garments)
+
+		for i, garment := range b.garments {
+			x := main.Min.X + i*(width+collageGap)
+			cells = append(cells, collageCell{garment, image.Rect(x, y, x+width, y+height)})
+		}
+		y += height + collageGap
+	}
+
+	return cells
+}
+
+func NewOutfitCollageService(outfits models.OutfitRepository, garments models.GarmentRepository, storage ObjectStorage) models.OutfitCollageService {
+	return &OutfitCollageService{
+		outfits:  outfits,
+		garments: garments,
+		storage:  storage,
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Tamaño A4 en puntos
+const (
+	pdfPageWidth  = 595.0
+	pdfPageHeight = 842.0
+)
+
+// pdfWriter writes a PDF 1.4 file as objects are added, so big documents are
+// never kept in memory. It only supports what the lookbook needs: the
+// standard Helvetica fonts, JPEG images and raw content streams.
+type pdfWriter struct {
+	w       io.Writer
+	offset  int64
+	offsets map[int]int64
+	last    int
+	err     error
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	p := &pdfWriter{w: w, offsets: map[int]int64{}}
+	// The binary comment tells tools the file is not plain text
+	p.write([]byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"))
+	return p
+}
+
+func (p *pdfWriter) write(data []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// reserve returns the number of a new object, to reference it before it is
+// written.
+func (p *pdfWriter) reserve() int {
+	p.last++
+	return p.last
+}
+
+func (p *pdfWriter) object(n int, body string) {
+	p.offsets[n] = p.offset
+	p.write([]byte(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", n, body)))
+}
+
+func (p *pdfWriter) stream(n int, dict string, data []byte) {
+	p.offsets[n] = p.offset
+	p.write([]byte(fmt.Sprintf("%d 0 obj\n<< %s /Length %d >>\nstream\n", n, dict, len(data))))
+	p.write(data)
+	p.write([]byte("\nendstream\nendobj\n"))
+}
+
+// jpeg adds a JPEG image, PDF readers decode it themselves.
+func (p *pdfWriter) jpeg(data []byte, width, height int) int {
+	n := p.reserve()
+	p.stream(n, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode", width, height), data)
+	return n
+}
+
+// close writes the cross-reference table and the trailer.
+func (p *pdfWriter) close(root int) error {
+	xref := p.offset
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", p.last+1)
+	for n := 1; n <= p.last; n++ {
+		if offset, ok := p.offsets[n]; ok {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+		} else {
+			buf.WriteString("0000000000 65535 f \n")
+		}
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.last+1, root, xref)
+	p.write(buf.Bytes())
+
+	return p.err
+}
+
+// pdfPage builds the content stream of a page. Coordinates start at the top
+// left corner, unlike PDF where y grows upwards.
+type pdfPage struct {
+	content bytes.Buffer
+	images  map[string]int
+}
+
+func newPDFPage() *pdfPage {
+	return &pdfPage{images: map[string]int{}}
+}
+
+// text draws a line of text with its baseline at y. Bold uses the second
+// font of the document.
+func (pg *pdfPage) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&pg.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, pdfPageHeight-y, pdfString(s))
+}
+
+func (pg *pdfPage) fillRect(x, y, width, height, gray float64) {
+	fmt.Fprintf(&pg.content, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, pdfPageHeight-y-height, width, height)
+}
+
+func (pg *pdfPage) line(x1, y1, x2, y2, gray float64) {
+	fmt.Fprintf(&pg.content, "%.2f G 0.5 w %.2f %.2f m %.2f %.2f l S 0 G\n", gray, x1, pdfPageHeight-y1, x2, pdfPageHeight-y2)
+}
+
+// image draws the image object n scaled to width x height, with its top
+// left corner at x, y.
+func (pg *pdfPage) image(n int, x, y, width, height float64) {
+	name := fmt.Sprintf("Im%d", n)
+	pg.images[name] = n
+	fmt.Fprintf(&pg.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", width, height, x, pdfPageHeight-y-height, name)
+}
+
+// addPage writes the page and its content, and returns the page object.
+func (p *pdfWriter) addPage(pg *pdfPage, parent, fonts int) int {
+	content := p.reserve()
+	p.stream(content, "", pg.content.Bytes())
+
+	var xobjects strings.Builder
+	for name, n := range pg.images {
+		fmt.Fprintf(&xobjects, "/%s %d 0 R ", name, n)
+	}
+
+	page := p.reserve()
+	p.object(page, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.0f %.0f] /Contents %d 0 R /Resources << /Font %d 0 R /XObject << %s>> >> >>",
+		parent, pdfPageWidth, pdfPageHeight, content, fonts, xobjects.String(),
+	))
+	return page
+}
+
+// pdfString escapes s for a literal string. The fonts use WinAnsiEncoding,
+// so characters outside Latin-1 are replaced.
+func pdfString(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			buf.WriteByte(' ')
+		case r < 32 || r > 255 || (r >= 127 && r < 160):
+			buf.WriteByte('?')
+		case r < 128:
+			buf.WriteRune(r)
+		default:
+			fmt.Fprintf(&buf, "\\%03o", r)
+		}
+	}
+	return buf.String()
+}
+
+// truncateText shortens s to about maxWidth points at the given font size,
+// using the average width of Helvetica.
+func truncateText(s string, size, maxWidth float64) string {
+	limit := int(maxWidth / (size * 0.52))
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	if limit <= 3 {
+		return string(runes[:max(limit, 0)])
+	}
+	return string(runes[:limit-3]) + "..."
+}
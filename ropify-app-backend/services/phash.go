@@ -0,0 +1,39 @@
+package services
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DuplicateHashDistance is the Hamming distance up to which two photos are
+// considered the same garment. dHash distances of 0-5 are near copies,
+// above 10 they are usually different images.
+const DuplicateHashDistance = 10
+
+// DifferenceHash computes the 64 bit dHash of the image: it is scaled to 9x8
+// in grayscale and every bit tells whether a pixel is brighter than its right
+// neighbour. Resizing, recompression and small color changes barely change it.
+func DifferenceHash(img image.Image) uint64 {
+	small := Resize(flatten(img), 9, 8)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(small, x, y) > luminance(small, x+1, y) {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func luminance(img *image.RGBA, x, y int) float64 {
+	c := img.RGBAAt(x, y)
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
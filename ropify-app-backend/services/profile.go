@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+type ProfileService struct {
+	repository models.ProfileRepository
+	users      models.AuthRepository
+	usernames  models.UsernameService
+	storage    ObjectStorage
+}
+
+func (s *ProfileService) GetProfile(ctx context.Context, userID uuid.UUID) (*models.Profile, error) {
+	user, err := s.users.GetUser(ctx, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.Profile(), nil
+}
+
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, update *models.ProfileUpdate) (*models.Profile, error) {
+	updateData := map[string]interface{}{}
+
+	if update.FirstName != nil {
+		firstName := strings.TrimSpace(*update.FirstName)
+		if firstName == "" {
+			return nil, fmt.Errorf("firstName cannot be empty")
+		}
+		updateData["first_name"] = firstName
+	}
+	if update.LastName != nil {
+		updateData["last_name"] = strings.TrimSpace(*update.LastName)
+	}
+	if update.Bio != nil {
+		updateData["bio"] = strings.TrimSpace(*update.Bio)
+	}
+
+	user, err := s.repository.UpdateProfile(ctx, userID, updateData)
+	if err != nil {
+		return nil, err
+	}
+
+	return user.Profile(), nil
+}
+
+// UpdateAvatar crops and resizes the image server side before storing it, so
+// whatever the client sends the avatar is a small square JPEG.
+func (s *ProfileService) UpdateAvatar(ctx context.Context, userID uuid.UUID, imageBytes []byte) (*models.Profile, error) {
+	avatar, err := ProcessAvatar(imageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.users.GetUser(ctx, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("avatars/users/%s/%s.jpg", userID, uuid.New())
+	avatarURL, err := s.storage.Put(ctx, key, avatar, "image/jpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repository.UpdateProfile(ctx, userID, map[string]interface{}{"avatar_url": avatarURL})
+	if err != nil {
+		s.deleteAvatar(ctx, avatarURL)
+		return nil, err
+	}
+
+	// Las fotos de los proveedores sociales no están en el storage
+	s.deleteAvatar(ctx, current.AvatarURL)
+
+	return user.Profile(), nil
+}
+
+// deleteAvatar removes an avatar from the storage, if it is there. Failures
+// are left to the image garbage collector.
+func (s *ProfileService) deleteAvatar(ctx context.Context, avatarURL string) {
+	key, ok := s.storage.KeyFromURL(avatarURL)
+	if !ok {
+		return
+	}
+	if err := s.storage.Delete(ctx, key); err != nil {
+		log.Warnf("failed to delete avatar %s: %v", key, err)
+	}
+}
+
+// GetPublicProfile returns the public view of a user. The bool is true when
+// the username is an old one of the user.
+func (s *ProfileService) GetPublicProfile(ctx context.Context, username string) (*models.PublicProfile, bool, error) {
+	user, redirected, err := s.usernames.Resolve(ctx, username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	garmentCount, err := s.repository.CountGarments(ctx, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	outfitCount, err := s.repository.CountOutfits(ctx, user.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &models.PublicProfile{
+		Username:     user.Username,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		AvatarURL:    user.AvatarURL,
+		Bio:          user.Bio,
+		GarmentCount: garmentCount,
+		OutfitCount:  outfitCount,
+		CreatedAt:    user.CreatedAt,
+	}, redirected, nil
+}
+
+func NewProfileService(repository models.ProfileRepository, users models.AuthRepository, usernames models.UsernameService, storage ObjectStorage) models.ProfileService {
+	return &ProfileService{
+		repository: repository,
+		users:      users,
+		usernames:  usernames,
+		storage:    storage,
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("address not allowed")
+
+// RemoteImageFetcher downloads images from URLs given by users. It only
+// follows http(s) and refuses private and loopback addresses so the API can
+// not be used to reach internal services.
+type RemoteImageFetcher struct {
+	client  *http.Client
+	maxSize int64
+}
+
+func (f *RemoteImageFetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid image URL %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image, status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %v", err)
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidImage, f.maxSize)
+	}
+
+	return data, nil
+}
+
+// checkPublicAddress refuses host:port addresses that are not public.
+func checkPublicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func NewRemoteImageFetcher(timeout time.Duration, maxSize int64) *RemoteImageFetcher {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Checked after DNS resolution, on the address actually dialed
+		Control: func(network, address string, conn syscall.RawConn) error {
+			return checkPublicAddress(address)
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &RemoteImageFetcher{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return errors.New("redirect to unsupported scheme")
+				}
+				return nil
+			},
+		},
+		maxSize: maxSize,
+	}
+}
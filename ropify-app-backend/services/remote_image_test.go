@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.10.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.0.0.5:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"[fd00::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"224.0.0.1:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"localhost:80", false},
+		{"127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		err := checkPublicAddress(tt.address)
+		if (err == nil) != tt.allowed {
+			t.Errorf("checkPublicAddress(%q) = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+	}
+}
+
+func TestRemoteImageFetcherRejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	fetcher := NewRemoteImageFetcher(time.Second, 1<<20)
+
+	if _, err := fetcher.Fetch(context.Background(), server.URL); !errors.Is(err, errPrivateAddress) {
+		t.Fatalf("Fetch(%s) error = %v, want errPrivateAddress", server.URL, err)
+	}
+}
+
+func TestRemoteImageFetcherInvalidURL(t *testing.T) {
+	fetcher := NewRemoteImageFetcher(time.Second, 1<<20)
+
+	for _, rawURL := range []string{"", "file:///etc/passwd", "ftp://example.com/a.jpg", "gopher://example.com", "http://", "/a.jpg"} {
+		if _, err := fetcher.Fetch(context.Background(), rawURL); err == nil {
+			t.Errorf("Fetch(%q) was accepted", rawURL)
+		}
+	}
+}
+
+func TestRemoteImageFetcherMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	// The client of the test server skips the address check
+	fetcher := &RemoteImageFetcher{client: server.Client(), maxSize: 4}
+
+	if _, err := fetcher.Fetch(context.Background(), server.URL); !errors.Is(err, ErrInvalidImage) {
+		t.Fatalf("Fetch error = %v, want ErrInvalidImage", err)
+	}
+}
@@ -1,36 +0,0 @@
-package services
-
-import (
-	"bytes"
-	"fmt"
-	"os"
-	"time"
-
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-)
-
-func UploadToS3Bytes(imageBytes []byte, destiny, filename string) (string, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_REGION")),
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to create AWS session: %v", err)
-	}
-
-	fileName := fmt.Sprintf("%s/%d-%s", destiny, time.Now().Unix(), filename)
-	uploader := s3manager.NewUploader(sess)
-
-	result, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket:      aws.String(os.Getenv("AWS_BUCKET_NAME")),
-		Key:         aws.String(fileName),
-		Body:        bytes.NewReader(imageBytes),
-		ContentType: aws.String("image/png"), // Cambia si usas otro formato
-	})
-	if err != nil {
-		return "", fmt.Errorf("failed to upload file to S3: %v", err)
-	}
-
-	return result.Location, nil
-}
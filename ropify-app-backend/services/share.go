@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ShareLinkPath is where the HTML page of a share link is served.
+const ShareLinkPath = "/s/"
+
+// Garments shown in a shared closet, the newest first
+const maxSharedGarments = 200
+
+// shareSecret is kept apart from the session secret so a share token can
+// never be used as an access token.
+func shareSecret() []byte {
+	return []byte(os.Getenv("JWT_SECRET") + ":share")
+}
+
+// shareToken is the ID of the link followed by its signature, so forged
+// tokens are rejected without a database lookup.
+func shareToken(linkID uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(linkID[:]) + "." + shareSignature(linkID)
+}
+
+func shareSignature(linkID uuid.UUID) string {
+	mac := hmac.New(sha256.New, shareSecret())
+	mac.Write(linkID[:])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func parseShareToken(token string) (uuid.UUID, error) {
+	encodedID, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return uuid.Nil, models.ErrShareNotFound
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encodedID)
+	if err != nil {
+		return uuid.Nil, models.ErrShareNotFound
+	}
+	linkID, err := uuid.FromBytes(raw)
+	if err != nil {
+		return uuid.Nil, models.ErrShareNotFound
+	}
+
+	if !hmac.Equal([]byte(shareSignature(linkID)), []byte(signature)) {
+		return uuid.Nil, models.ErrShareNotFound
+	}
+
+	return linkID, nil
+}
+
+func sharedGarment(garment *models.Garment) *models.SharedGarment {
+	return &models.SharedGarment{
+		ID:           garment.ID,
+		Category:     garment.Category,
+		Color:        garment.Color,
+		Brand:        garment.Brand,
+		Labels:       garment.Labels,
+		ImageURL:     garment.ImageURL,
+		MediumURL:    garment.MediumURL,
+		ThumbnailURL: garment.ThumbnailURL,
+	}
+}
+
+type ShareService struct {
+	repository models.ShareRepository
+	outfits    models.OutfitRepository
+	garments   models.GarmentRepository
+	users      models.AuthRepository
+	profiles   models.ProfileService
+	baseURL    string
+}
+
+func (s *ShareService) CreateShare(ctx context.Context, userID uuid.UUID, request *models.ShareRequest) (*models.ShareLink, error) {
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expires_at must be in the future")
+	}
+
+	link := &models.ShareLink{
+		UserID:    userID,
+		Target:    request.Target,
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	switch request.Target {
+	case models.ShareOutfit:
+		if request.OutfitID == nil {
+			return nil, fmt.Errorf("outfit_id is required")
+		}
+
+		outfit, err := s.outfits.GetOutfitByID(ctx, *request.OutfitID)
+		if err != nil {
+			return nil, err
+		}
+		if outfit.UserID != userID {
+			return nil, gorm.ErrRecordNotFound
+		}
+		link.OutfitID = &outfit.ID
+
+	case models.ShareCloset:
+		filters := request.Filters
+		filters.Color = strings.TrimSpace(filters.Color)
+		if filters.Category != "" && !containsCategory(models.GarmentCategories, filters.Category) {
+			return nil, fmt.Errorf("unknown category %q", filters.Category)
+		}
+		link.Filters = filters
+
+	default:
+		return nil, fmt.Errorf("target must be %q or %q", models.ShareOutfit, models.ShareCloset)
+	}
+
+	created, err := s.repository.CreateShare(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setURL(created)
+	return created, nil
+}
+
+func (s *ShareService) ListShares(ctx context.Context, userID uuid.UUID) ([]*models.ShareLink, error) {
+	links, err := s.repository.ListShares(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		if link.IsActive(time.Now()) {
+			s.setURL(link)
+		}
+	}
+
+	return links, nil
+}
+
+func (s *ShareService) RevokeShare(ctx context.Context, userID, linkID uuid.UUID) error {
+	return s.repository.RevokeShare(ctx, userID, linkID)
+}
+
+func (s *ShareService) ViewShare(ctx context.Context, token string, countView bool) (*models.SharedContent, error) {
+	linkID, err := parseShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := s.repository.GetShare(ctx, linkID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !link.IsActive(time.Now()) {
+		return nil, models.ErrShareExpired
+	}
+
+	// Las cuentas suspendidas no muestran nada
+	user, err := s.users.GetUser(ctx, "id = ?", link.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && user.SuspendedAt != nil) {
+		return nil, models.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owner, _, err := s.profiles.GetPublicProfile(ctx, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &models.SharedContent{
+		Target: link.Target,
+		Owner:  owner,
+		URL:    s.baseURL + ShareLinkPath + token,
+	}
+
+	switch link.Target {
+	case models.ShareOutfit:
+		if content.Outfit, err = s.sharedOutfit(ctx, link); err != nil {
+			return nil, err
+		}
+
+	case models.ShareCloset:
+		filters := map[string]interface{}{}
+		if link.Filters.Category != "" {
+			filters["category"] = link.Filters.Category
+		}
+		if link.Filters.Color != "" {
+			filters["color"] = link.Filters.Color
+		}
+
+		garments, err := s.garments.FilterGarments(ctx, link.UserID, filters, "created_at", maxSharedGarments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		content.Garments = make([]*models.SharedGarment, len(garments))
+		for i, garment := range garments {
+			content.Garments[i] = sharedGarment(garment)
+		}
+		content.Filters = &link.Filters
+	}
+
+	if countView {
+		if err := s.repository.RecordView(ctx, link.ID); err != nil {
+			log.Warnf("share link %s: failed to count view: %v", link.ID, err)
+		}
+	}
+
+	return content, nil
+}
+
+func (s *ShareService) sharedOutfit(ctx context.Context, link *models.ShareLink) (*models.SharedOutfit, error) {
+	outfit, err := s.outfits.GetOutfitByID(ctx, *link.OutfitID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if outfit.UserID != link.UserID {
+		return nil, models.ErrShareNotFound
+	}
+
+	found, err := s.garments.GetGarmentsByIDs(ctx, link.UserID, outfit.GarmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Garment, len(found))
+	for _, garment := range found {
+		byID[garment.ID.String()] = garment
+	}
+
+	shared := &models.SharedOutfit{
+		Name:     outfit.Name,
+		Tags:     outfit.Tags,
+		Occasion: outfit.Occasion,
+		Season:   outfit.Season,
+		ImageURL: outfit.ImageURL,
+		Garments: []*models.SharedGarment{},
+	}
+	for _, garmentID := range outfit.GarmentIDs {
+		if garment, ok := byID[strings.ToLower(garmentID)]; ok {
+			shared.Garments = append(shared.Garments, sharedGarment(garment))
+		}
+	}
+
+	return shared, nil
+}
+
+func (s *ShareService) setURL(link *models.ShareLink) {
+	link.Token = shareToken(link.ID)
+	link.URL = s.baseURL + ShareLinkPath + link.Token
+}
+
+func containsCategory(categories []models.GarmentCategory, category models.GarmentCategory) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func NewShareService(repository models.ShareRepository, outfits models.OutfitRepository, garments models.GarmentRepository, users models.AuthRepository, profiles models.ProfileService, baseURL string) models.ShareService {
+	return &ShareService{
+		repository: repository,
+		outfits:    outfits,
+		garments:   garments,
+		users:      users,
+		profiles:   profiles,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+func TestParseShareToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	linkID := uuid.New()
+	token := shareToken(linkID)
+	encodedID, signature, _ := strings.Cut(token, ".")
+	otherID := uuid.New()
+
+	got, err := parseShareToken(token)
+	if err != nil {
+		t.Fatalf("parseShareToken(valid token): %v", err)
+	}
+	if got != linkID {
+		t.Fatalf("parseShareToken = %s, want %s", got, linkID)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", encodedID},
+		{"empty signature", encodedID + "."},
+		{"tampered signature", encodedID + "." + strings.Repeat("A", len(signature))},
+		{"signature of another link", base64.RawURLEncoding.EncodeToString(otherID[:]) + "." + signature},
+		{"invalid base64", "not*base64." + signature},
+		{"short ID", base64.RawURLEncoding.EncodeToString(linkID[:8]) + "." + signature},
+		{"extra part", token + ".extra"},
+		{"plain UUID", linkID.String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseShareToken(tt.token); !errors.Is(err, models.ErrShareNotFound) {
+				t.Errorf("parseShareToken(%q) error = %v, want ErrShareNotFound", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestShareTokenDependsOnSecret(t *testing.T) {
+	linkID := uuid.New()
+
+	t.Setenv("JWT_SECRET", "old-secret")
+	token := shareToken(linkID)
+
+	t.Setenv("JWT_SECRET", "new-secret")
+	if _, err := parseShareToken(token); !errors.Is(err, models.ErrShareNotFound) {
+		t.Errorf("a token signed with another secret was accepted: %v", err)
+	}
+}
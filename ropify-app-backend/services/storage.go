@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// StoredObject describes an object kept in the storage.
+type StoredObject struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// ObjectStorage is where user images live. Keys are paths like
+// "garments/users/<user id>/<file>".
+type ObjectStorage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Open streams the object, for callers that must not load it whole.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*StoredObject, error)
+	List(ctx context.Context, prefix string) ([]StoredObject, error)
+	Delete(ctx context.Context, keys ...string) error
+	// PresignPut returns an URL the client can PUT the object to, with the
+	// given content type, until it expires.
+	PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+	// KeyFromURL returns the key of an URL returned by Put, false when the
+	// URL does not point to this storage.
+	KeyFromURL(rawURL string) (string, bool)
+}
+
+// ManagedStoragePrefixes hold the images the API creates, the garbage
+// collector only looks at objects under them.
+var ManagedStoragePrefixes = []string{"garments/", "avatars/", "uploads/", "outfits/"}
+
+// NewObjectStorage builds the storage selected by STORAGE_DRIVER: "local"
+// keeps the files in localDir and serves them under publicURL, anything else
+// uses S3.
+func NewObjectStorage(driver, localDir, publicURL string) (ObjectStorage, error) {
+	if driver == "local" {
+		return NewLocalStorage(localDir, publicURL)
+	}
+	return NewS3Storage()
+}
+
+// UserStoragePrefixes are the prefixes under which the images of a user are
+// stored (garments, avatars, outfit collages and the old per garment
+// uploads).
+func UserStoragePrefixes(userID string) []string {
+	return []string{
+		fmt.Sprintf("garments/users/%s/", userID),
+		fmt.Sprintf("garments/%s/", userID),
+		fmt.Sprintf("avatars/users/%s/", userID),
+		fmt.Sprintf("uploads/users/%s/", userID),
+		fmt.Sprintf("outfits/users/%s/", userID),
+	}
+}
+
+type S3Storage struct {
+	client *s3.S3
+	bucket string
+	region string
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	return s.objectURL(key), nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from S3: %v", key, err)
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from S3: %v", key, err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*StoredObject, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s in S3: %v", key, err)
+	}
+
+	return &StoredObject{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		ContentType:  aws.StringValue(out.ContentType),
+		LastModified: aws.TimeValue(out.LastModified),
+	}, nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]StoredObject, error) {
+	objects := []StoredObject{}
+
+	err := s.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			objects = append(objects, StoredObject{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list S3 objects: %v", err)
+	}
+
+	return objects, nil
+}
+
+// Delete removes the keys in batches of 1000, the S3 maximum.
+func (s *S3Storage) Delete(ctx context.Context, keys ...string) error {
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		objects := make([]*s3.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		out, err := s.client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete S3 objects: %v", err)
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("failed to delete %s: %s", aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Storage) PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error) {
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	})
+	req.SetContext(ctx)
+
+	signed, err := req.Presign(expires)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign S3 upload: %v", err)
+	}
+
+	return signed, nil
+}
+
+// KeyFromURL understands both virtual-hosted and path style S3 URLs.
+func (s *S3Storage) KeyFromURL(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.HasSuffix(u.Host, "amazonaws.com") {
+		return "", false
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+
+	if strings.HasPrefix(u.Host, s.bucket+".") {
+		return path, path != ""
+	}
+
+	if key, ok := strings.CutPrefix(path, s.bucket+"/"); ok {
+		return key, key != ""
+	}
+
+	return "", false
+}
+
+func (s *S3Storage) objectURL(key string) string {
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, escaped)
+}
+
+// NewS3Storage stores the objects in the AWS_BUCKET_NAME bucket of AWS_REGION.
+func NewS3Storage() (*S3Storage, error) {
+	region := os.Getenv("AWS_REGION")
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+
+	return &S3Storage{
+		client: s3.New(sess),
+		bucket: os.Getenv("AWS_BUCKET_NAME"),
+		region: region,
+	}, nil
+}
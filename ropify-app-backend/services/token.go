@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const maxTokensPerUser = 20
+
+var tokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateToken returns a token like rpf_<8 chars>_<32 chars> and its prefix
+// (everything up to the secret part).
+func generateToken() (string, string, error) {
+	random := make([]byte, 25)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	encoded := strings.ToLower(tokenEncoding.EncodeToString(random))
+	prefix := models.PersonalAccessTokenPrefix + encoded[:8]
+
+	return prefix + "_" + encoded[8:], prefix, nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !containsString(models.TokenScopes, scope) {
+			return fmt.Errorf("unknown scope %q, valid scopes: %s", scope, strings.Join(models.TokenScopes, ", "))
+		}
+	}
+
+	return nil
+}
+
+type TokenService struct {
+	repository models.TokenRepository
+}
+
+// CreateToken stores a new token and returns it together with its plain
+// value, which is not kept anywhere and cannot be shown again.
+func (s *TokenService) CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.PersonalAccessToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("expires_at must be in the future")
+	}
+
+	existing, err := s.repository.ListTokens(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	active := 0
+	for _, token := range existing {
+		if token.IsActive(time.Now()) {
+			active++
+		}
+	}
+	if active >= maxTokensPerUser {
+		return nil, "", fmt.Errorf("you can have at most %d active tokens", maxTokensPerUser)
+	}
+
+	plain, prefix, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.repository.CreateToken(ctx, &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: models.HashToken(plain),
+		Scopes:    pq.StringArray(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, plain, nil
+}
+
+func (s *TokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	return s.repository.ListTokens(ctx, userID)
+}
+
+func (s *TokenService) RevokeToken(ctx context.Context, userID, tokenID uuid.UUID) error {
+	return s.repository.RevokeToken(ctx, userID, tokenID)
+}
+
+func NewTokenService(repository models.TokenRepository) models.TokenService {
+	return &TokenService{
+		repository: repository,
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+// fakeTokenRepository keeps the tokens of a single user.
+type fakeTokenRepository struct {
+	models.TokenRepository
+
+	tokens []*models.PersonalAccessToken
+}
+
+func (r *fakeTokenRepository) ListTokens(ctx context.Context, userID uuid.UUID) ([]*models.PersonalAccessToken, error) {
+	return r.tokens, nil
+}
+
+func (r *fakeTokenRepository) CreateToken(ctx context.Context, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	token.ID = uuid.New()
+	r.tokens = append(r.tokens, token)
+	return token, nil
+}
+
+func TestCreateTokenValidation(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	activeTokens := make([]*models.PersonalAccessToken, maxTokensPerUser)
+	for i := range activeTokens {
+		activeTokens[i] = &models.PersonalAccessToken{}
+	}
+	revokedTokens := make([]*models.PersonalAccessToken, maxTokensPerUser)
+	for i := range revokedTokens {
+		revokedTokens[i] = &models.PersonalAccessToken{RevokedAt: &past}
+	}
+
+	tests := []struct {
+		name      string
+		tokenName string
+		scopes    []string
+		expiresAt *time.Time
+		existing  []*models.PersonalAccessToken
+		wantErr   bool
+	}{
+		{name: "valid", tokenName: "backup", scopes: []string{models.ScopeGarmentsRead}},
+		{name: "expires later", tokenName: "ci", scopes: []string{models.ScopeOutfitsWrite}, expiresAt: &future},
+		{name: "revoked tokens do not count", tokenName: "ci", scopes: []string{models.ScopeGarmentsRead}, existing: revokedTokens},
+		{name: "blank name", tokenName: "  ", scopes: []string{models.ScopeGarmentsRead}, wantErr: true},
+		{name: "no scopes", tokenName: "ci", wantErr: true},
+		{name: "unknown scope", tokenName: "ci", scopes: []string{"admin"}, wantErr: true},
+		{name: "resource without access", tokenName: "ci", scopes: []string{"garments"}, wantErr: true},
+		{name: "one unknown scope among valid ones", tokenName: "ci", scopes: []string{models.ScopeGarmentsRead, "garments:delete"}, wantErr: true},
+		{name: "already expired", tokenName: "ci", scopes: []string{models.ScopeGarmentsRead}, expiresAt: &past, wantErr: true},
+		{name: "too many active tokens", tokenName: "ci", scopes: []string{models.ScopeGarmentsRead}, existing: activeTokens, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeTokenRepository{tokens: tt.existing}
+			service := NewTokenService(repository)
+
+			token, plain, err := service.CreateToken(context.Background(), uuid.New(), tt.tokenName, tt.scopes, tt.expiresAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateToken error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(repository.tokens) != len(tt.existing) {
+					t.Errorf("a rejected token was stored")
+				}
+				return
+			}
+
+			if !strings.HasPrefix(plain, token.Prefix+"_") || !strings.HasPrefix(token.Prefix, models.PersonalAccessTokenPrefix) {
+				t.Errorf("token %q does not start with its prefix %q", plain, token.Prefix)
+			}
+			if token.TokenHash != models.HashToken(plain) || strings.Contains(token.TokenHash, plain) {
+				t.Errorf("the stored hash does not match the token")
+			}
+		})
+	}
+}
+
+func TestGenerateTokenIsUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		plain, prefix, err := generateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[plain] || seen[prefix] {
+			t.Fatalf("generateToken repeated %q", plain)
+		}
+		seen[plain], seen[prefix] = true, true
+
+		if len(plain) != len(models.PersonalAccessTokenPrefix)+8+1+32 {
+			t.Errorf("token %q has length %d", plain, len(plain))
+		}
+	}
+}
+
+func TestPersonalAccessTokenIsActive(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		token  models.PersonalAccessToken
+		active bool
+	}{
+		{"no expiry", models.PersonalAccessToken{}, true},
+		{"expires later", models.PersonalAccessToken{ExpiresAt: &future}, true},
+		{"expired", models.PersonalAccessToken{ExpiresAt: &past}, false},
+		{"expires now", models.PersonalAccessToken{ExpiresAt: &now}, false},
+		{"revoked", models.PersonalAccessToken{RevokedAt: &past}, false},
+		{"revoked before expiring", models.PersonalAccessToken{RevokedAt: &past, ExpiresAt: &future}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.token.IsActive(now); got != tt.active {
+			t.Errorf("%s: IsActive = %v, want %v", tt.name, got, tt.active)
+		}
+	}
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	session := &models.Principal{}
+	token := &models.Principal{Scopes: []string{models.ScopeGarmentsRead}}
+	noScopes := &models.Principal{Scopes: []string{}}
+
+	tests := []struct {
+		name      string
+		principal *models.Principal
+		scope     string
+		want      bool
+	}{
+		{"session has every scope", session, models.ScopeOutfitsWrite, true},
+		{"token scope", token, models.ScopeGarmentsRead, true},
+		{"read does not grant write", token, models.ScopeGarmentsWrite, false},
+		{"other resource", token, models.ScopeOutfitsRead, false},
+		{"token without scopes is not a session", noScopes, models.ScopeGarmentsRead, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.principal.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%s: HasScope(%q) = %v, want %v", tt.name, tt.scope, got, tt.want)
+		}
+	}
+}
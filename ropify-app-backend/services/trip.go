@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+type TripService struct {
+	repository models.TripRepository
+	outfits    models.OutfitRepository
+	garments   models.GarmentRepository
+}
+
+// tripFromRequest validates a trip request and copies it into trip.
+func tripFromRequest(trip *models.Trip, request *models.TripRequest) error {
+	start, err := time.Parse(models.TripDateLayout, request.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be a date like 2006-01-02")
+	}
+	end, err := time.Parse(models.TripDateLayout, request.EndDate)
+	if err != nil {
+		return fmt.Errorf("end_date must be a date like 2006-01-02")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end_date can't be before start_date")
+	}
+
+	activities := pq.StringArray{}
+	for _, activity := range request.Activities {
+		if activity = strings.TrimSpace(activity); activity != "" {
+			activities = append(activities, activity)
+		}
+	}
+
+	trip.Name = strings.TrimSpace(request.Name)
+	trip.Destination = strings.TrimSpace(request.Destination)
+	trip.StartDate = start
+	trip.EndDate = end
+	trip.Activities = activities
+
+	if trip.Days() > models.MaxTripDays {
+		return fmt.Errorf("a trip can't be longer than %d days", models.MaxTripDays)
+	}
+	return nil
+}
+
+func tripRef(trip *models.Trip) *models.TripRef {
+	return &models.TripRef{
+		ID:        trip.ID,
+		Name:      trip.Name,
+		StartDate: trip.StartDate.Format(models.TripDateLayout),
+		EndDate:   trip.EndDate.Format(models.TripDateLayout),
+	}
+}
+
+func (s *TripService) CreateTrip(ctx context.Context, userID uuid.UUID, request *models.TripRequest) (*models.Trip, error) {
+	trip := &models.Trip{UserID: userID}
+	if err := tripFromRequest(trip, request); err != nil {
+		return nil, err
+	}
+	return s.repository.CreateTrip(ctx, trip)
+}
+
+func (s *TripService) GetTrip(ctx context.Context, userID, tripID uuid.UUID) (*models.TripDetail, error) {
+	trip, err := s.repository.GetTrip(ctx, userID, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	tripOutfits, err := s.repository.ListTripOutfits(ctx, []uuid.UUID{trip.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	outfitIDs := make([]uuid.UUID, len(tripOutfits))
+	for i, tripOutfit := range tripOutfits {
+		outfitIDs[i] = tripOutfit.OutfitID
+	}
+	outfits, err := s.outfits.GetOutfitsByIDs(ctx, userID, outfitIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Outfit, len(outfits))
+	for _, outfit := range outfits {
+		byID[outfit.ID] = outfit
+	}
+
+	detail := &models.TripDetail{Trip: trip, Days: make([]*models.TripDay, trip.Days())}
+	for i := range detail.Days {
+		detail.Days[i] = &models.TripDay{
+			Date:    trip.StartDate.AddDate(0, 0, i).Format(models.TripDateLayout),
+			Outfits: []*models.TripOutfit{},
+		}
+	}
+	for _, tripOutfit := range tripOutfits {
+		day := int(tripOutfit.Date.Sub(trip.StartDate).Hours() / 24)
+		if day < 0 || day >= len(detail.Days) {
+			continue
+		}
+		tripOutfit.Outfit = byID[tripOutfit.OutfitID]
+		detail.Days[day].Outfits = append(detail.Days[day].Outfits, tripOutfit)
+	}
+
+	return detail, nil
+}
+
+func (s *TripService) ListTrips(ctx context.Context, userID uuid.UUID) ([]*models.Trip, error) {
+	return s.repository.ListTrips(ctx, userID)
+}
+
+func (s *TripService) UpdateTrip(ctx context.Context, userID, tripID uuid.UUID, request *models.TripRequest) (*models.Trip, error) {
+	trip, err := s.repository.GetTrip(ctx, userID, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tripFromRequest(trip, request); err != nil {
+		return nil, err
+	}
+
+	// Los outfits ya planeados tienen que seguir dentro del viaje
+	tripOutfits, err := s.repository.ListTripOutfits(ctx, []uuid.UUID{trip.ID})
+	if err != nil {
+		return nil, err
+	}
+	for _, tripOutfit := range tripOutfits {
+		if tripOutfit.Date.Before(trip.StartDate) || tripOutfit.Date.After(trip.EndDate) {
+			return nil, fmt.Errorf("an outfit is planned on %s, outside the new dates", tripOutfit.Date.Format(models.TripDateLayout))
+		}
+	}
+
+	trip.UpdatedAt = time.Now()
+	return s.repository.UpdateTrip(ctx, trip)
+}
+
+func (s *TripService) DeleteTrip(ctx context.Context, userID, tripID uuid.UUID) error {
+	return s.repository.DeleteTrip(ctx, userID, tripID)
+}
+
+func (s *TripService) AddOutfit(ctx context.Context, userID, tripID uuid.UUID, request *models.TripOutfitRequest) (*models.TripOutfit, error) {
+	trip, err := s.repository.GetTrip(ctx, userID, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.Parse(models.TripDateLayout, request.Date)
+	if err != nil {
+		return nil, fmt.Errorf("date must be a date like 2006-01-02")
+	}
+	if date.Before(trip.StartDate) || date.After(trip.EndDate) {
+		return nil, models.ErrTripOutOfRange
+	}
+
+	// Solo outfits propios
+	outfits, err := s.outfits.GetOutfitsByIDs(ctx, userID, []uuid.UUID{request.OutfitID})
+	if err != nil {
+		return nil, err
+	}
+	if len(outfits) == 0 {
+		return nil, models.ErrTripOutfitMissing
+	}
+
+	planned, err := s.repository.ListTripOutfits(ctx, []uuid.UUID{trip.ID})
+	if err != nil {
+		return nil, err
+	}
+	for _, tripOutfit := range planned {
+		if tripOutfit.OutfitID == request.OutfitID && tripOutfit.Date.Equal(date) {
+			return nil, models.ErrTripOutfitPlanned
+		}
+	}
+
+	tripOutfit, err := s.repository.AddTripOutfit(ctx, &models.TripOutfit{
+		TripID:   trip.ID,
+		Date:     date,
+		OutfitID: request.OutfitID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tripOutfit.Outfit = outfits[0]
+	return tripOutfit, nil
+}
+
+func (s *TripService) RemoveOutfit(ctx context.Context, userID, tripID, tripOutfitID uuid.UUID) error {
+	if _, err := s.repository.GetTrip(ctx, userID, tripID); err != nil {
+		return err
+	}
+	err := s.repository.RemoveTripOutfit(ctx, tripID, tripOutfitID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ErrTripOutfitMissing
+	}
+	return err
+}
+
+// tripGarments maps each garment used by the outfits of the trips to the
+// days it is used, per trip.
+func (s *TripService) tripGarments(ctx context.Context, userID uuid.UUID, tripIDs []uuid.UUID) (map[uuid.UUID]map[string][]string, error) {
+	tripOutfits, err := s.repository.ListTripOutfits(ctx, tripIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	outfitIDs := make([]uuid.UUID, len(tripOutfits))
+	for i, tripOutfit := range tripOutfits {
+		outfitIDs[i] = tripOutfit.OutfitID
+	}
+	outfits, err := s.outfits.GetOutfitsByIDs(ctx, userID, outfitIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Outfit, len(outfits))
+	for _, outfit := range outfits {
+		byID[outfit.ID] = outfit
+	}
+
+	garments := map[uuid.UUID]map[string][]string{}
+	for _, tripOutfit := range tripOutfits {
+		outfit, ok := byID[tripOutfit.OutfitID]
+		if !ok {
+			continue
+		}
+		if garments[tripOutfit.TripID] == nil {
+			garments[tripOutfit.TripID] = map[string][]string{}
+		}
+		day := tripOutfit.Date.Format(models.TripDateLayout)
+		for _, garmentID := range outfit.GarmentIDs {
+			garmentID = strings.ToLower(garmentID)
+			garments[tripOutfit.TripID][garmentID] = append(garments[tripOutfit.TripID][garmentID], day)
+		}
+	}
+	return garments, nil
+}
+
+func (s *TripService) PackingList(ctx context.Context, userID, tripID uuid.UUID) (*models.PackingList, error) {
+	trip, err := s.repository.GetTrip(ctx, userID, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlapping, err := s.repository.ListOverlappingTrips(ctx, userID, trip.ID, trip.StartDate, trip.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	tripIDs := []uuid.UUID{trip.ID}
+	for _, other := range overlapping {
+		tripIDs = append(tripIDs, other.ID)
+	}
+
+	usage, err := s.tripGarments(ctx, userID, tripIDs)
+	if err != nil {
+		return nil, err
+	}
+	days := usage[trip.ID]
+
+	garmentIDs := make([]string, 0, len(days))
+	for garmentID := range days {
+		garmentIDs = append(garmentIDs, garmentID)
+	}
+	garments, err := s.garments.GetGarmentsByIDs(ctx, userID, garmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	checks, err := s.repository.ListPackingChecks(ctx, trip.ID)
+	if err != nil {
+		return nil, err
+	}
+	checked := make(map[uuid.UUID]bool, len(checks))
+	for _, check := range checks {
+		checked[check.GarmentID] = true
+	}
+
+	list := &models.PackingList{
+		Trip:           trip,
+		Items:          make([]*models.PackingItem, 0, len(garments)),
+		CategoryTotals: map[models.GarmentCategory]int{},
+	}
+	for _, garment := range garments {
+		garmentID := garment.ID.String()
+		item := &models.PackingItem{
+			GarmentID:        garment.ID,
+			Category:         garment.Category,
+			Color:            garment.Color,
+			Brand:            garment.Brand,
+			ThumbnailURL:     garment.ThumbnailURL,
+			Count:            len(days[garmentID]),
+			Days:             uniqueDays(days[garmentID]),
+			Checked:          checked[garment.ID],
+			OverlappingTrips: []*models.TripRef{},
+		}
+		for _, other := range overlapping {
+			if _, ok := usage[other.ID][garmentID]; ok {
+				item.OverlappingTrips = append(item.OverlappingTrips, tripRef(other))
+			}
+		}
+
+		list.Items = append(list.Items, item)
+		list.CategoryTotals[garment.Category]++
+		if item.Checked {
+			list.Checked++
+		}
+	}
+	list.TotalGarments = len(list.Items)
+
+	// Por categoría en el orden habitual y luego por uso
+	order := make(map[models.GarmentCategory]int, len(models.GarmentCategories))
+	for i, category := range models.GarmentCategories {
+		order[category] = i
+	}
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		a, b := list.Items[i], list.Items[j]
+		if order[a.Category] != order[b.Category] {
+			return order[a.Category] < order[b.Category]
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.GarmentID.String() < b.GarmentID.String()
+	})
+
+	return list, nil
+}
+
+// uniqueDays drops repeated days, an outfit can be worn twice the same day.
+func uniqueDays(days []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, day := range days {
+		if !seen[day] {
+			seen[day] = true
+			unique = append(unique, day)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func (s *TripService) CheckItem(ctx context.Context, userID, tripID, garmentID uuid.UUID, checked bool) error {
+	list, err := s.PackingList(ctx, userID, tripID)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range list.Items {
+		if item.GarmentID == garmentID {
+			return s.repository.SetPackingCheck(ctx, tripID, garmentID, checked)
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (s *TripService) WriteChecklist(list *models.PackingList, format string, w io.Writer) error {
+	switch format {
+	case models.ChecklistJSON:
+		return json.NewEncoder(w).Encode(list)
+	case models.ChecklistText:
+		return writeTextChecklist(list, w)
+	default:
+		return fmt.Errorf("unsupported checklist format %q", format)
+	}
+}
+
+func writeTextChecklist(list *models.PackingList, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	trip := list.Trip
+
+	fmt.Fprintf(out, "%s - %s\n", trip.Name, trip.Destination)
+	fmt.Fprintf(out, "%s to %s (%d days)\n", trip.StartDate.Format(models.TripDateLayout), trip.EndDate.Format(models.TripDateLayout), trip.Days())
+	if len(trip.Activities) > 0 {
+		fmt.Fprintf(out, "Activities: %s\n", strings.Join(trip.Activities, ", "))
+	}
+	fmt.Fprintf(out, "Packed %d of %d garments\n", list.Checked, list.TotalGarments)
+
+	var category models.GarmentCategory
+	for i, item := range list.Items {
+		if i == 0 || item.Category != category {
+			category = item.Category
+			fmt.Fprintf(out, "\n%s (%d)\n", strings.ToUpper(string(category)), list.CategoryTotals[category])
+		}
+
+		mark := " "
+		if item.Checked {
+			mark = "x"
+		}
+		name := item.Color
+		if item.Brand != "" {
+			name += " " + item.Brand
+		}
+		if name == "" {
+			name = item.GarmentID.String()
+		}
+		fmt.Fprintf(out, "[%s] %s x%d", mark, name, item.Count)
+		for _, other := range item.OverlappingTrips {
+			fmt.Fprintf(out, " (also in %s, %s to %s)", other.Name, other.StartDate, other.EndDate)
+		}
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
+
+func NewTripService(repository models.TripRepository, outfits models.OutfitRepository, garments models.GarmentRepository) models.TripService {
+	return &TripService{
+		repository: repository,
+		outfits:    outfits,
+		garments:   garments,
+	}
+}
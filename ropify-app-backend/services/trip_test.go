@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type fakeTripRepository struct {
+	models.TripRepository
+
+	trip        *models.Trip
+	overlapping []*models.Trip
+	outfits     []*models.TripOutfit
+	checks      []*models.TripPackingCheck
+}
+
+func (r *fakeTripRepository) GetTrip(ctx context.Context, userID, tripID uuid.UUID) (*models.Trip, error) {
+	return r.trip, nil
+}
+
+func (r *fakeTripRepository) ListOverlappingTrips(ctx context.Context, userID, tripID uuid.UUID, start, end time.Time) ([]*models.Trip, error) {
+	return r.overlapping, nil
+}
+
+func (r *fakeTripRepository) ListTripOutfits(ctx context.Context, tripIDs []uuid.UUID) ([]*models.TripOutfit, error) {
+	outfits := []*models.TripOutfit{}
+	for _, outfit := range r.outfits {
+		for _, tripID := range tripIDs {
+			if outfit.TripID == tripID {
+				outfits = append(outfits, outfit)
+			}
+		}
+	}
+	return outfits, nil
+}
+
+func (r *fakeTripRepository) ListPackingChecks(ctx context.Context, tripID uuid.UUID) ([]*models.TripPackingCheck, error) {
+	return r.checks, nil
+}
+
+type fakeOutfitRepository struct {
+	models.OutfitRepository
+
+	outfits []*models.Outfit
+}
+
+func (r *fakeOutfitRepository) GetOutfitsByIDs(ctx context.Context, userID uuid.UUID, outfitIDs []uuid.UUID) ([]*models.Outfit, error) {
+	outfits := []*models.Outfit{}
+	for _, outfit := range r.outfits {
+		for _, outfitID := range outfitIDs {
+			if outfit.ID == outfitID {
+				outfits = append(outfits, outfit)
+				break
+			}
+		}
+	}
+	return outfits, nil
+}
+
+func (r *fakeGarmentRepository) GetGarmentsByIDs(ctx context.Context, userID uuid.UUID, garmentIDs []string) ([]*models.Garment, error) {
+	garments := []*models.Garment{}
+	for _, garment := range r.garments {
+		for _, garmentID := range garmentIDs {
+			if garment.ID.String() == garmentID {
+				garments = append(garments, garment)
+			}
+		}
+	}
+	return garments, nil
+}
+
+func tripDay(day string) time.Time {
+	date, err := time.Parse(models.TripDateLayout, day)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func TestPackingList(t *testing.T) {
+	shirt := &models.Garment{ID: uuid.New(), Category: models.Top, Color: "white"}
+	tee := &models.Garment{ID: uuid.New(), Category: models.Top, Color: "black"}
+	jeans := &models.Garment{ID: uuid.New(), Category: models.Bottoms, Color: "blue"}
+	sneakers := &models.Garment{ID: uuid.New(), Category: models.Sneakers, Color: "white"}
+
+	trip := &models.Trip{ID: uuid.New(), Name: "Lisbon", StartDate: tripDay("2025-07-01"), EndDate: tripDay("2025-07-05")}
+	other := &models.Trip{ID: uuid.New(), Name: "Porto", StartDate: tripDay("2025-07-05"), EndDate: tripDay("2025-07-08")}
+
+	day := &models.Outfit{ID: uuid.New(), GarmentIDs: pq.StringArray{shirt.ID.String(), jeans.ID.String()}}
+	// Upper case IDs are the same garments
+	night := &models.Outfit{ID: uuid.New(), GarmentIDs: pq.StringArray{strings.ToUpper(tee.ID.String()), strings.ToUpper(jeans.ID.String())}}
+	porto := &models.Outfit{ID: uuid.New(), GarmentIDs: pq.StringArray{jeans.ID.String(), sneakers.ID.String()}}
+	deleted := uuid.New()
+
+	repository := &fakeTripRepository{
+		trip:        trip,
+		overlapping: []*models.Trip{other},
+		outfits: []*models.TripOutfit{
+			{TripID: trip.ID, OutfitID: day.ID, Date: tripDay("2025-07-02")},
+			{TripID: trip.ID, OutfitID: night.ID, Date: tripDay("2025-07-02")},
+			{TripID: trip.ID, OutfitID: day.ID, Date: tripDay("2025-07-01")},
+			{TripID: trip.ID, OutfitID: deleted, Date: tripDay("2025-07-03")},
+			{TripID: other.ID, OutfitID: porto.ID, Date: tripDay("2025-07-05")},
+		},
+		checks: []*models.TripPackingCheck{{TripID: trip.ID, GarmentID: jeans.ID}},
+	}
+	service := NewTripService(
+		repository,
+		&fakeOutfitRepository{outfits: []*models.Outfit{day, night, porto}},
+		&fakeGarmentRepository{garments: []*models.Garment{shirt, tee, jeans, sneakers}},
+	)
+
+	list, err := service.PackingList(context.Background(), uuid.New(), trip.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct {
+		garment     uuid.UUID
+		count       int
+		days        []string
+		checked     bool
+		overlapping int
+	}
+	// By category, then by use
+	want := []item{
+		{shirt.ID, 2, []string{"2025-07-01", "2025-07-02"}, false, 0},
+		{tee.ID, 1, []string{"2025-07-02"}, false, 0},
+		// Worn by two outfits the same day, listed once
+		{jeans.ID, 3, []string{"2025-07-01", "2025-07-02"}, true, 1},
+	}
+	if len(list.Items) != len(want) {
+		t.Fatalf("packing list has %d items, want %d", len(list.Items), len(want))
+	}
+	for i, w := range want {
+		got := list.Items[i]
+		if got.GarmentID != w.garment || got.Count != w.count || !reflect.DeepEqual(got.Days, w.days) || got.Checked != w.checked || len(got.OverlappingTrips) != w.overlapping {
+			t.Errorf("item %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if refs := list.Items[2].OverlappingTrips; len(refs) == 1 && refs[0].ID != other.ID {
+		t.Errorf("overlapping trip = %s, want %s", refs[0].ID, other.ID)
+	}
+
+	if list.TotalGarments != 3 || list.Checked != 1 {
+		t.Errorf("totals = %d garments, %d checked, want 3 and 1", list.TotalGarments, list.Checked)
+	}
+	wantTotals := map[models.GarmentCategory]int{models.Top: 2, models.Bottoms: 1}
+	if !reflect.DeepEqual(list.CategoryTotals, wantTotals) {
+		t.Errorf("category totals = %v, want %v", list.CategoryTotals, wantTotals)
+	}
+}
+
+func TestUniqueDays(t *testing.T) {
+	got := uniqueDays([]string{"2025-07-03", "2025-07-01", "2025-07-03", "2025-07-01"})
+	want := []string{"2025-07-01", "2025-07-03"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("uniqueDays = %v, want %v", got, want)
+	}
+	if got := uniqueDays(nil); len(got) != 0 {
+		t.Errorf("uniqueDays(nil) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// uploadCompletionGrace lets clients complete an upload they started right
+// before the URL expired. Intents older than that are cleaned up.
+const uploadCompletionGrace = time.Hour
+
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type UploadService struct {
+	repository models.UploadRepository
+	garments   models.GarmentRepository
+	images     models.GarmentImageService
+	storage    ObjectStorage
+	expiry     time.Duration
+	maxSize    int64
+}
+
+// CreateUpload registers the intent and presigns the URL the client uploads
+// the photo of the garment to, so the file never goes through the API.
+func (s *UploadService) CreateUpload(ctx context.Context, userID, garmentID uuid.UUID, contentType string, size int64) (*models.PresignedUpload, error) {
+	if !uploadContentTypes[contentType] {
+		return nil, fmt.Errorf("%w: %s is not supported, use JPEG, PNG or GIF", ErrInvalidImage, contentType)
+	}
+	if size <= 0 || size > s.maxSize {
+		return nil, fmt.Errorf("%w: the maximum size is %d bytes", models.ErrUploadTooLarge, s.maxSize)
+	}
+
+	if _, err := s.garments.GetGarmentByID(ctx, userID, garmentID); err != nil {
+		return nil, err
+	}
+
+	intent := &models.UploadIntent{
+		UserID:      userID,
+		GarmentID:   garmentID,
+		Key:         fmt.Sprintf("uploads/users/%s/%s", userID, uuid.NewString()),
+		ContentType: contentType,
+		MaxSize:     size,
+		Status:      models.UploadPending,
+		ExpiresAt:   time.Now().Add(s.expiry),
+	}
+
+	url, err := s.storage.PresignPut(ctx, intent.Key, contentType, s.expiry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.CreateIntent(ctx, intent); err != nil {
+		return nil, err
+	}
+
+	return &models.PresignedUpload{
+		UploadID:  intent.ID,
+		Method:    http.MethodPut,
+		URL:       url,
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: intent.ExpiresAt,
+	}, nil
+}
+
+// CompleteUpload checks the uploaded object against the intent, runs it
+// through the image pipeline and attaches the renditions to the garment.
+func (s *UploadService) CompleteUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.GarmentImages, error) {
+	intent, err := s.repository.GetIntent(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case intent.Status == models.UploadCompleted:
+		return nil, models.ErrUploadDone
+	case intent.Status == models.UploadExpired || time.Now().After(intent.ExpiresAt.Add(uploadCompletionGrace)):
+		return nil, models.ErrUploadExpired
+	}
+
+	object, err := s.storage.Stat(ctx, intent.Key)
+	if err != nil {
+		log.Warnf("upload %s not found in storage: %v", intent.ID, err)
+		return nil, models.ErrUploadMissing
+	}
+	if object.Size > intent.MaxSize {
+		s.discard(ctx, intent)
+		return nil, fmt.Errorf("%w: %d bytes declared, %d uploaded", models.ErrUploadTooLarge, intent.MaxSize, object.Size)
+	}
+	if !uploadContentTypes[object.ContentType] {
+		s.discard(ctx, intent)
+		return nil, fmt.Errorf("%w: %s is not supported, use JPEG, PNG or GIF", ErrInvalidImage, object.ContentType)
+	}
+
+	data, err := s.readUpload(ctx, intent)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pipeline sniffs the real type, whatever the client declared
+	images, err := s.images.StoreGarmentImage(ctx, userID, data)
+	if err != nil {
+		if errors.Is(err, ErrInvalidImage) {
+			s.discard(ctx, intent)
+		}
+		return nil, err
+	}
+
+	if err := s.garments.UpdateGarmentImage(userID, images, intent.GarmentID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.repository.UpdateIntentStatus(ctx, intent.ID, models.UploadCompleted, &now); err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Delete(ctx, intent.Key); err != nil {
+		log.Warnf("failed to delete the original of upload %s: %v", intent.ID, err)
+	}
+
+	return images, nil
+}
+
+// discard expires an intent whose file can not be used.
+// readUpload downloads the upload without reading past the declared size,
+// in case the object grew after Stat.
+func (s *UploadService) readUpload(ctx context.Context, intent *models.UploadIntent) ([]byte, error) {
+	body, err := s.storage.Open(ctx, intent.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, intent.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > intent.MaxSize {
+		s.discard(ctx, intent)
+		return nil, fmt.Errorf("%w: the maximum size is %d bytes", models.ErrUploadTooLarge, intent.MaxSize)
+	}
+	return data, nil
+}
+
+func (s *UploadService) discard(ctx context.Context, intent *models.UploadIntent) {
+	if err := s.storage.Delete(ctx, intent.Key); err != nil {
+		log.Warnf("failed to delete upload %s: %v", intent.ID, err)
+	}
+	if err := s.repository.UpdateIntentStatus(ctx, intent.ID, models.UploadExpired, nil); err != nil {
+		log.Warnf("failed to expire upload %s: %v", intent.ID, err)
+	}
+}
+
+func (s *UploadService) CleanupExpired(ctx context.Context) (int, error) {
+	expired := 0
+
+	for {
+		intents, err := s.repository.ListExpiredIntents(ctx, time.Now().Add(-uploadCompletionGrace), 100)
+		if err != nil {
+			return expired, err
+		}
+		if len(intents) == 0 {
+			return expired, nil
+		}
+
+		keys := make([]string, 0, len(intents))
+		for _, intent := range intents {
+			keys = append(keys, intent.Key)
+		}
+		if err := s.storage.Delete(ctx, keys...); err != nil {
+			return expired, err
+		}
+
+		for _, intent := range intents {
+			// Not found means it was completed meanwhile
+			err := s.repository.UpdateIntentStatus(ctx, intent.ID, models.UploadExpired, nil)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return expired, err
+			}
+			expired++
+		}
+	}
+}
+
+func RunUploadCleanup(ctx context.Context, service models.UploadService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if expired, err := service.CleanupExpired(ctx); err != nil {
+			log.Errorf("upload cleanup: %v", err)
+		} else if expired > 0 {
+			log.Infof("upload cleanup: %d abandoned uploads removed", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func NewUploadService(repository models.UploadRepository, garments models.GarmentRepository, images models.GarmentImageService, storage ObjectStorage, expiry time.Duration, maxSize int64) models.UploadService {
+	return &UploadService{
+		repository: repository,
+		garments:   garments,
+		images:     images,
+		storage:    storage,
+		expiry:     expiry,
+		maxSize:    maxSize,
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+)
+
+type fakeUploadRepository struct {
+	models.UploadRepository
+
+	intent *models.UploadIntent
+}
+
+func (r *fakeUploadRepository) GetIntent(ctx context.Context, userID, uploadID uuid.UUID) (*models.UploadIntent, error) {
+	return r.intent, nil
+}
+
+func (r *fakeUploadRepository) UpdateIntentStatus(ctx context.Context, uploadID uuid.UUID, status models.UploadStatus, completedAt *time.Time) error {
+	r.intent.Status = status
+	return nil
+}
+
+func TestCompleteUploadRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+		want    error
+	}{
+		{"not an image", []byte("#!/bin/sh\necho hello\n"), 1024, ErrInvalidImage},
+		{"larger than declared", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 2048)...), 1024, models.ErrUploadTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newTestLocalStorage(t)
+			intent := &models.UploadIntent{
+				ID:        uuid.New(),
+				UserID:    uuid.New(),
+				GarmentID: uuid.New(),
+				Key:       "uploads/users/test/photo",
+				MaxSize:   tt.maxSize,
+				Status:    models.UploadPending,
+				ExpiresAt: time.Now().Add(time.Minute),
+			}
+			if _, err := storage.Put(context.Background(), intent.Key, tt.data, "image/png"); err != nil {
+				t.Fatal(err)
+			}
+
+			repository := &fakeUploadRepository{intent: intent}
+			service := NewUploadService(repository, &fakeGarmentRepository{}, NewGarmentImageService(storage), storage, time.Minute, tt.maxSize)
+
+			_, err := service.CompleteUpload(context.Background(), intent.UserID, intent.ID)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("CompleteUpload error = %v, want %v", err, tt.want)
+			}
+			if intent.Status != models.UploadExpired {
+				t.Errorf("intent status = %s, want %s", intent.Status, models.UploadExpired)
+			}
+			if _, err := storage.Stat(context.Background(), intent.Key); err == nil {
+				t.Error("the rejected upload was kept in the storage")
+			}
+		})
+	}
+}
+
+func TestReadUploadIsBounded(t *testing.T) {
+	storage := newTestLocalStorage(t)
+	intent := &models.UploadIntent{ID: uuid.New(), Key: "uploads/users/test/photo", MaxSize: 16}
+	service := &UploadService{repository: &fakeUploadRepository{intent: intent}, storage: storage}
+
+	if _, err := storage.Put(context.Background(), intent.Key, bytes.Repeat([]byte("a"), 16), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := service.readUpload(context.Background(), intent); err != nil || len(data) != 16 {
+		t.Fatalf("readUpload of the declared size = %d bytes, %v", len(data), err)
+	}
+
+	// The object grew after it was checked
+	if _, err := storage.Put(context.Background(), intent.Key, bytes.Repeat([]byte("a"), 1<<20), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.readUpload(context.Background(), intent); !errors.Is(err, models.ErrUploadTooLarge) {
+		t.Fatalf("readUpload error = %v, want %v", err, models.ErrUploadTooLarge)
+	}
+}
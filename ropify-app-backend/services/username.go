@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+const (
+	UsernameMinLength      = 3
+	UsernameMaxLength      = 30
+	UsernameChangeCooldown = 30 * 24 * time.Hour
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9._]*[a-z0-9])?$`)
+
+// Nombres que chocan con rutas o que se prestan a suplantación
+var reservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "api": true, "auth": true, "help": true,
+	"login": true, "logout": true, "me": true, "moderator": true, "null": true,
+	"oauth": true, "register": true, "root": true, "ropify": true, "settings": true,
+	"signup": true, "staff": true, "support": true, "system": true, "undefined": true,
+	"user": true, "users": true, "garment": true, "garments": true, "outfit": true,
+	"outfits": true, "share": true, "www": true,
+}
+
+// NormalizeUsername lowercases and trims a username before it is validated
+// or stored, usernames are case insensitive.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateUsername checks length, allowed characters (a-z, 0-9, '.' and '_',
+// not at the ends nor repeated) and the reserved list.
+func ValidateUsername(username string) error {
+	if len(username) < UsernameMinLength || len(username) > UsernameMaxLength {
+		return fmt.Errorf("the username must have between %d and %d characters", UsernameMinLength, UsernameMaxLength)
+	}
+
+	if !usernamePattern.MatchString(username) || strings.Contains(username, "..") || strings.Contains(username, "__") {
+		return fmt.Errorf("the username can only contain letters, numbers, '.' and '_' and must start and end with a letter or number")
+	}
+
+	if reservedUsernames[username] {
+		return fmt.Errorf("the username %q is reserved", username)
+	}
+
+	return nil
+}
+
+// SlugifyUsername derives a username candidate from a display name, e.g.
+// "José María Pérez" becomes "jose.maria.perez".
+func SlugifyUsername(name string) string {
+	var b strings.Builder
+	lastSeparator := true
+
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop the accents left by the decomposition
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			lastSeparator = false
+		case !lastSeparator:
+			b.WriteByte('.')
+			lastSeparator = true
+		}
+	}
+
+	slug := strings.Trim(b.String(), ".")
+	if len(slug) > UsernameMaxLength-5 {
+		slug = strings.Trim(slug[:UsernameMaxLength-5], ".")
+	}
+
+	return slug
+}
+
+type UsernameService struct {
+	repository models.UsernameRepository
+	users      models.AuthRepository
+}
+
+// Generate returns an available username derived from the name, adding a
+// numeric suffix when the plain slug is taken.
+func (s *UsernameService) Generate(ctx context.Context, name string) (string, error) {
+	base := SlugifyUsername(name)
+	if ValidateUsername(base) != nil {
+		base = "user"
+	}
+
+	candidates := []string{}
+	if !reservedUsernames[base] {
+		candidates = append(candidates, base)
+	}
+	for i := 2; i <= 9; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s%d", base, i))
+	}
+	for i := 0; i < 10; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s%d", base, 1000+rand.Intn(9000)))
+	}
+
+	for _, candidate := range candidates {
+		taken, err := s.repository.IsUsernameTaken(ctx, candidate, uuid.Nil)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+
+	return fmt.Sprintf("%s%s", base, strings.ReplaceAll(uuid.NewString(), "-", "")[:8]), nil
+}
+
+// IsAvailable reports whether the username is valid and free for userID.
+func (s *UsernameService) IsAvailable(ctx context.Context, username string, userID uuid.UUID) (bool, error) {
+	username = NormalizeUsername(username)
+	if err := ValidateUsername(username); err != nil {
+		return false, err
+	}
+
+	taken, err := s.repository.IsUsernameTaken(ctx, username, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return !taken, nil
+}
+
+// ChangeUsername renames the user, at most once per cooldown period. The old
+// username keeps redirecting to the user.
+func (s *UsernameService) ChangeUsername(ctx context.Context, userID uuid.UUID, username string) (*models.User, error) {
+	username = NormalizeUsername(username)
+
+	user, err := s.users.GetUser(ctx, "id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Username == username {
+		return user, nil
+	}
+
+	if user.UsernameChangedAt != nil && time.Since(*user.UsernameChangedAt) < UsernameChangeCooldown {
+		return nil, models.ErrUsernameCooldown
+	}
+
+	available, err := s.IsAvailable(ctx, username, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, models.ErrUsernameTaken
+	}
+
+	if err := s.repository.ChangeUsername(ctx, userID, username, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.users.GetUser(ctx, "id = ?", userID)
+}
+
+// Resolve finds the user currently or formerly known by username. The bool
+// is true when the username is an old one and clients should redirect.
+func (s *UsernameService) Resolve(ctx context.Context, username string) (*models.User, bool, error) {
+	username = NormalizeUsername(username)
+	// Emails are never usernames, resolving them would tell who owns them
+	if strings.Contains(username, "@") {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+
+	user, err := s.users.GetUser(ctx, "username = ?", username)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	redirect, err := s.repository.FindRedirect(ctx, username)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err = s.users.GetUser(ctx, "id = ?", redirect.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return user, true, nil
+}
+
+func NewUsernameService(repository models.UsernameRepository, users models.AuthRepository) models.UsernameService {
+	return &UsernameService{
+		repository: repository,
+		users:      users,
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// fakeUsernameRepository treats the usernames in taken as used, by a user or
+// as a redirect.
+type fakeUsernameRepository struct {
+	models.UsernameRepository
+
+	taken map[string]bool
+}
+
+func (r *fakeUsernameRepository) IsUsernameTaken(ctx context.Context, username string, exceptUserID uuid.UUID) (bool, error) {
+	return r.taken[username], nil
+}
+
+func TestSlugifyUsername(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"José María Pérez", "jose.maria.perez"},
+		{"  Ana  ", "ana"},
+		{"Zoë O'Brien-Smith", "zoe.o.brien.smith"},
+		{"user_42", "user.42"},
+		{"Ñandú", "nandu"},
+		{"李小龍", ""},
+		{"!!!", ""},
+		{"Maximiliano Alejandro de la Santísima Trinidad", "maximiliano.alejandro.de"},
+	}
+
+	for _, tt := range tests {
+		got := SlugifyUsername(tt.name)
+		if got != tt.want {
+			t.Errorf("SlugifyUsername(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > UsernameMaxLength-5 {
+			t.Errorf("SlugifyUsername(%q) = %q leaves no room for a suffix", tt.name, got)
+		}
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		valid    bool
+	}{
+		{"ana", true},
+		{"jose.maria", true},
+		{"ana_2024", true},
+		{"a1", false},
+		{strings.Repeat("a", UsernameMaxLength+1), false},
+		{".ana", false},
+		{"ana.", false},
+		{"ana..perez", false},
+		{"ana__perez", false},
+		{"ana perez", false},
+		{"ana@example.com", false},
+		{"Ana", false},
+		{"admin", false},
+		{"settings", false},
+		{"outfit", false},
+		{"share", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateUsername(tt.username)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateUsername(%q) = %v, want valid %v", tt.username, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateUsernameRejectsReservedVariants(t *testing.T) {
+	for username := range reservedUsernames {
+		if err := ValidateUsername(NormalizeUsername(" " + strings.ToUpper(username) + " ")); err == nil {
+			t.Errorf("the reserved username %q was accepted", username)
+		}
+	}
+}
+
+func TestGenerateUsername(t *testing.T) {
+	tests := []struct {
+		name       string
+		display    string
+		taken      []string
+		want       string
+		wantPrefix string
+	}{
+		{name: "free slug", display: "Ana Pérez", want: "ana.perez"},
+		{name: "numbered suffix", display: "Ana Pérez", taken: []string{"ana.perez", "ana.perez2"}, want: "ana.perez3"},
+		{name: "reserved slug falls back to user", display: "Admin", want: "user2"},
+		{name: "empty name", display: "李小龍", want: "user2"},
+		{name: "random suffix", display: "Ana", taken: []string{"ana", "ana2", "ana3", "ana4", "ana5", "ana6", "ana7", "ana8", "ana9"}, wantPrefix: "ana"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := map[string]bool{}
+			for _, username := range tt.taken {
+				taken[username] = true
+			}
+			service := NewUsernameService(&fakeUsernameRepository{taken: taken}, &fakeAuthRepository{})
+
+			got, err := service.Generate(context.Background(), tt.display)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("Generate(%q) = %q, want %q", tt.display, got, tt.want)
+			}
+			if tt.wantPrefix != "" && (!strings.HasPrefix(got, tt.wantPrefix) || taken[got]) {
+				t.Errorf("Generate(%q) = %q, want a free %q username", tt.display, got, tt.wantPrefix)
+			}
+			if err := ValidateUsername(got); err != nil {
+				t.Errorf("Generate(%q) = %q, which is not valid: %v", tt.display, got, err)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsRedirectedUsername(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	// "ana.perez" is held as the redirect of a recent rename
+	usernames := &fakeUsernameRepository{taken: map[string]bool{"ana.perez": true}}
+	service := NewAuthService(&fakeAuthRepository{}, usernames)
+
+	_, _, err := service.Register(context.Background(), &models.AuthCredentials{
+		Email:    "new@example.com",
+		Username: "Ana.Perez",
+		Password: "secret-password",
+	})
+	if !errors.Is(err, models.ErrUsernameTaken) {
+		t.Fatalf("Register error = %v, want ErrUsernameTaken", err)
+	}
+}
+
+func TestUsernameChangeCooldown(t *testing.T) {
+	changedAt := time.Now().Add(-time.Hour)
+	users := &fakeUserLookup{user: &models.User{ID: uuid.New(), Username: "ana", UsernameChangedAt: &changedAt}}
+	service := NewUsernameService(&fakeUsernameRepository{}, users)
+
+	if _, err := service.ChangeUsername(context.Background(), users.user.ID, "ana.perez"); !errors.Is(err, models.ErrUsernameCooldown) {
+		t.Fatalf("ChangeUsername error = %v, want ErrUsernameCooldown", err)
+	}
+}
+
+func TestResolveRejectsEmails(t *testing.T) {
+	users := &fakeUserLookup{user: &models.User{ID: uuid.New(), Username: "ana"}}
+	service := NewUsernameService(&fakeUsernameRepository{}, users)
+
+	for _, username := range []string{"ana@example.com", " Ana@Example.com ", "@ana"} {
+		if _, _, err := service.Resolve(context.Background(), username); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Resolve(%q) error = %v, want not found", username, err)
+		}
+	}
+	if _, _, err := service.Resolve(context.Background(), "ana"); err != nil {
+		t.Errorf("Resolve(\"ana\"): %v", err)
+	}
+}
+
+// fakeUserLookup always finds the same user.
+type fakeUserLookup struct {
+	models.AuthRepository
+
+	user *models.User
+}
+
+func (r *fakeUserLookup) GetUser(ctx context.Context, query interface{}, args ...interface{}) (*models.User, error) {
+	return r.user, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gaelzamora/ropify-app/models"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/google/uuid"
+)
+
+// ParseTargetDistribution reads a distribution of the closet written as
+// category:weight entries, like "top:30". Category aliases are accepted.
+func ParseTargetDistribution(entries []string) (map[models.GarmentCategory]float64, error) {
+	target := map[models.GarmentCategory]float64{}
+	total := 0.0
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("target %q must be category:weight", entry)
+		}
+		category, ok := NormalizeCategory(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown category %q", name)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+			return nil, fmt.Errorf("weight of %q must be a positive number", name)
+		}
+
+		target[category] += weight
+		total += weight
+	}
+
+	if total == 0 {
+		return nil, fmt.Errorf("target distribution is empty")
+	}
+	return target, nil
+}
+
+type WishlistService struct {
+	repository models.WishlistRepository
+	images     models.GarmentImageService
+	fetcher    *RemoteImageFetcher
+	target     map[models.GarmentCategory]float64
+}
+
+// itemFromRequest validates a wishlist request and copies it into item.
+func itemFromRequest(item *models.WishlistItem, request *models.WishlistItemRequest) error {
+	category, ok := NormalizeCategory(string(request.Category))
+	if !ok {
+		return fmt.Errorf("%w: unknown category %q", models.ErrInvalidWishlistItem, request.Category)
+	}
+
+	item.Name = strings.TrimSpace(request.Name)
+	item.Link = strings.TrimSpace(request.Link)
+	item.Price = request.Price
+	item.Currency = strings.ToUpper(request.Currency)
+	item.Category = category
+	item.Color = strings.TrimSpace(request.Color)
+	item.ImageURL = strings.TrimSpace(request.ImageURL)
+	return nil
+}
+
+func (s *WishlistService) CreateItem(ctx context.Context, userID uuid.UUID, request *models.WishlistItemRequest) (*models.WishlistItem, error) {
+	item := &models.WishlistItem{UserID: userID}
+	if err := itemFromRequest(item, request); err != nil {
+		return nil, err
+	}
+	return s.repository.CreateItem(ctx, item)
+}
+
+func (s *WishlistService) GetItem(ctx context.Context, userID, itemID uuid.UUID) (*models.WishlistItem, error) {
+	return s.repository.GetItem(ctx, userID, itemID)
+}
+
+func (s *WishlistService) ListItems(ctx context.Context, userID uuid.UUID, purchased *bool) ([]*models.WishlistItem, error) {
+	return s.repository.ListItems(ctx, userID, purchased)
+}
+
+func (s *WishlistService) UpdateItem(ctx context.Context, userID, itemID uuid.UUID, request *models.WishlistItemRequest) (*models.WishlistItem, error) {
+	item, err := s.repository.GetItem(ctx, userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := itemFromRequest(item, request); err != nil {
+		return nil, err
+	}
+
+	item.UpdatedAt = time.Now()
+	return s.repository.UpdateItem(ctx, item)
+}
+
+func (s *WishlistService) DeleteItem(ctx context.Context, userID, itemID uuid.UUID) error {
+	return s.repository.DeleteItem(ctx, userID, itemID)
+}
+
+func (s *WishlistService) Purchase(ctx context.Context, userID, itemID uuid.UUID, request *models.PurchaseRequest) (*models.Garment, error) {
+	item, err := s.repository.GetItem(ctx, userID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.PurchasedAt != nil {
+		return nil, models.ErrWishlistItemPurchased
+	}
+
+	garment := &models.Garment{
+		UserID:   userID,
+		Category: item.Category,
+		Color:    item.Color,
+		Brand:    strings.TrimSpace(request.Brand),
+	}
+	if color := strings.TrimSpace(request.Color); color != "" {
+		garment.Color = color
+	}
+
+	// Sin la foto la prenda se crea igual, se puede subir después
+	if item.ImageURL != "" {
+		data, err := s.fetcher.Fetch(ctx, item.ImageURL)
+		if err == nil {
+			var images *models.GarmentImages
+			if images, err = s.images.StoreGarmentImage(ctx, userID, data); err == nil {
+				garment.SetImages(images)
+			}
+		}
+		if err != nil {
+			log.Warnf("wishlist item %s: image not copied to the garment: %v", item.ID, err)
+		}
+	}
+
+	return s.repository.MarkPurchased(ctx, item, garment)
+}
+
+func (s *WishlistService) GapAnalysis(ctx context.Context, userID uuid.UUID, target map[models.GarmentCategory]float64) (*models.GapAnalysis, error) {
+	if target == nil {
+		target = s.target
+	}
+
+	counts, err := s.repository.CountGarmentsByCategory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	pending := false
+	items, err := s.repository.ListItems(ctx, userID, &pending)
+	if err != nil {
+		return nil, err
+	}
+	wishlisted := map[models.GarmentCategory]int{}
+	for _, item := range items {
+		wishlisted[item.Category]++
+	}
+
+	analysis := &models.GapAnalysis{Categories: []*models.CategoryGap{}}
+	for _, count := range counts {
+		analysis.TotalGarments += count
+	}
+	weights := 0.0
+	for _, weight := range target {
+		weights += weight
+	}
+
+	order := make(map[models.GarmentCategory]int, len(models.GarmentCategories))
+	for i, category := range models.GarmentCategories {
+		order[category] = i
+	}
+
+	for _, category := range models.GarmentCategories {
+		weight, targeted := target[category]
+		if !targeted && counts[category] == 0 {
+			continue
+		}
+
+		gap := &models.CategoryGap{
+			Category:    category,
+			Garments:    counts[category],
+			TargetShare: weight / weights,
+			Wishlisted:  wishlisted[category],
+		}
+		if analysis.TotalGarments > 0 {
+			gap.Share = float64(gap.Garments) / float64(analysis.TotalGarments)
+		}
+		gap.Missing = missingGarments(gap.Garments, analysis.TotalGarments, gap.TargetShare)
+		gap.Underrepresented = gap.Missing > 0
+
+		analysis.Categories = append(analysis.Categories, gap)
+	}
+
+	sort.SliceStable(analysis.Categories, func(i, j int) bool {
+		a, b := analysis.Categories[i], analysis.Categories[j]
+		if a.Missing != b.Missing {
+			return a.Missing > b.Missing
+		}
+		if a.TargetShare != b.TargetShare {
+			return a.TargetShare > b.TargetShare
+		}
+		return order[a.Category] < order[b.Category]
+	})
+
+	return analysis, nil
+}
+
+// missingGarments is how many garments of a category have to be added to a
+// closet of total garments, count of them in the category, for the category
+// to reach share. Adding garments also grows the closet, so it solves
+// (count + x) / (total + x) >= share.
+func missingGarments(count, total int, share float64) int {
+	if share <= 0 {
+		return 0
+	}
+
+	missing := int(math.Ceil((share*float64(total)-float64(count))/(1-math.Min(share, 0.99)) - 1e-9))
+	// Una categoría vacía siempre necesita al menos una prenda
+	if count == 0 && missing < 1 {
+		return 1
+	}
+	if missing < 0 {
+		return 0
+	}
+	return missing
+}
+
+func NewWishlistService(repository models.WishlistRepository, images models.GarmentImageService, fetcher *RemoteImageFetcher, target map[models.GarmentCategory]float64) models.WishlistService {
+	return &WishlistService{
+		repository: repository,
+		images:     images,
+		fetcher:    fetcher,
+		target:     target,
+	}
+}
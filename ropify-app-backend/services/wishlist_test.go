@@ -0,0 +1,103 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/gaelzamora/ropify-app/models"
+)
+
+func TestParseTargetDistribution(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[models.GarmentCategory]float64
+		wantErr bool
+	}{
+		{
+			name:    "categories and aliases",
+			entries: []string{"top:30", " Pants : 25 ", "shoes:15", "dress:0"},
+			want:    map[models.GarmentCategory]float64{models.Top: 30, models.Bottoms: 25, models.Sneakers: 15, models.Dress: 0},
+		},
+		{
+			name:    "repeated categories add up",
+			entries: []string{"jeans:10", "shorts:5", ""},
+			want:    map[models.GarmentCategory]float64{models.Bottoms: 15},
+		},
+		{name: "no weight", entries: []string{"top"}, wantErr: true},
+		{name: "unknown category", entries: []string{"hats:10"}, wantErr: true},
+		{name: "negative weight", entries: []string{"top:-1"}, wantErr: true},
+		{name: "not a number", entries: []string{"top:many"}, wantErr: true},
+		{name: "infinite weight", entries: []string{"top:Inf"}, wantErr: true},
+		{name: "NaN weight", entries: []string{"top:NaN"}, wantErr: true},
+		{name: "every weight zero", entries: []string{"top:0", "bottom:0"}, wantErr: true},
+		{name: "empty", entries: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTargetDistribution(tt.entries)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTargetDistribution(%q) error = %v, want error %v", tt.entries, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTargetDistribution(%q) = %v, want %v", tt.entries, got, tt.want)
+			}
+			for category, weight := range tt.want {
+				if w, ok := got[category]; !ok || w != weight {
+					t.Errorf("weight of %s = %v, want %v", category, got[category], weight)
+				}
+			}
+		})
+	}
+}
+
+func TestMissingGarments(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		total int
+		share float64
+		want  int
+	}{
+		{name: "no target", count: 0, total: 10, share: 0, want: 0},
+		{name: "empty closet", count: 0, total: 0, share: 0.3, want: 1},
+		{name: "empty category, tiny share", count: 0, total: 10, share: 0.01, want: 1},
+		{name: "half the closet", count: 2, total: 10, share: 0.5, want: 6},
+		{name: "rounds up", count: 1, total: 10, share: 0.2, want: 2},
+		{name: "exactly on target", count: 5, total: 10, share: 0.5, want: 0},
+		{name: "over the target", count: 8, total: 10, share: 0.5, want: 0},
+		{name: "whole closet is capped", count: 3, total: 10, share: 1, want: 700},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingGarments(tt.count, tt.total, tt.share); got != tt.want {
+				t.Errorf("missingGarments(%d, %d, %v) = %d, want %d", tt.count, tt.total, tt.share, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingGarmentsIsTheSmallestSolution(t *testing.T) {
+	reaches := func(count, total, added int, share float64) bool {
+		return float64(count+added) >= share*float64(total+added)-1e-9
+	}
+
+	for total := 1; total <= 40; total++ {
+		for count := 0; count <= total; count++ {
+			for _, share := range []float64{0.05, 0.1, 0.2, 0.25, 1.0 / 3, 0.5, 0.75} {
+				missing := missingGarments(count, total, share)
+				if !reaches(count, total, missing, share) {
+					t.Fatalf("missingGarments(%d, %d, %v) = %d does not reach the share", count, total, share, missing)
+				}
+				// An empty category always gets at least one garment
+				if missing > 0 && !(count == 0 && missing == 1) && reaches(count, total, missing-1, share) {
+					t.Fatalf("missingGarments(%d, %d, %v) = %d, %d is enough", count, total, share, missing, missing-1)
+				}
+			}
+		}
+	}
+}